@@ -0,0 +1,17 @@
+//go:build lambda
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gastrader/go_ffmpeg/lambda"
+)
+
+func main() {
+	if err := lambda.Start(lambda.Handler); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// withRegion points a client at the region of target when it is an ARN, so
+// a topic or bus in another region works with the upload's configuration.
+func withRegion(cfg aws.Config, target string) (func(*string), error) {
+	if !arn.IsARN(target) {
+		if cfg.Region == "" {
+			return nil, fmt.Errorf("no AWS region for %s", target)
+		}
+		return func(*string) {}, nil
+	}
+	parsed, err := arn.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	return func(region *string) { *region = parsed.Region }, nil
+}
+
+// SNS publishes events to a topic. The event type is sent as the "type"
+// message attribute, so subscriptions can filter on it.
+type SNS struct {
+	client   *sns.Client
+	TopicARN string
+}
+
+func NewSNS(cfg aws.Config, topicARN string) (*SNS, error) {
+	if !arn.IsARN(topicARN) {
+		return nil, fmt.Errorf("invalid SNS topic ARN %q", topicARN)
+	}
+	setRegion, err := withRegion(cfg, topicARN)
+	if err != nil {
+		return nil, err
+	}
+	client := sns.NewFromConfig(cfg, func(o *sns.Options) { setRegion(&o.Region) })
+	return &SNS{client: client, TopicARN: topicARN}, nil
+}
+
+func (s *SNS) Publish(ctx context.Context, event Event) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	input := &sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+		},
+	}
+	if strings.HasSuffix(s.TopicARN, ".fifo") {
+		input.MessageGroupId = aws.String(event.Subject)
+		input.MessageDeduplicationId = aws.String(event.ID)
+	}
+	_, err = s.client.Publish(ctx, input)
+	return err
+}
+
+func (s *SNS) String() string {
+	return "sns:" + s.TopicARN
+}
+
+// EventBridge puts events on a bus, with the event type as the detail type
+// and the envelope as the detail.
+type EventBridge struct {
+	client *eventbridge.Client
+	Bus    string
+}
+
+func NewEventBridge(cfg aws.Config, bus string) (*EventBridge, error) {
+	setRegion, err := withRegion(cfg, bus)
+	if err != nil {
+		return nil, err
+	}
+	client := eventbridge.NewFromConfig(cfg, func(o *eventbridge.Options) { setRegion(&o.Region) })
+	return &EventBridge{client: client, Bus: bus}, nil
+}
+
+func (b *EventBridge) Publish(ctx context.Context, event Event) error {
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	result, err := b.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(b.Bus),
+			Source:       aws.String(event.Source),
+			DetailType:   aws.String(event.Type),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(event.Time),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.FailedEntryCount > 0 && len(result.Entries) > 0 {
+		entry := result.Entries[0]
+		return fmt.Errorf("event rejected: %s: %s", aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+	}
+	return nil
+}
+
+func (b *EventBridge) String() string {
+	return "eventbridge:" + b.Bus
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Kafka produces events to a topic through a Kafka REST Proxy, keyed by the
+// event subject so a video's events stay in order on one partition. URL is
+// the topic's endpoint, e.g. http://rest-proxy:8082/topics/video-jobs.
+type Kafka struct {
+	URL string
+}
+
+func NewKafka(topicURL string) (*Kafka, error) {
+	u, err := url.Parse(topicURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || !strings.Contains(u.Path, "/topics/") {
+		return nil, fmt.Errorf("invalid Kafka REST Proxy topic URL %q (expected http(s)://host/topics/name)", topicURL)
+	}
+	return &Kafka{URL: topicURL}, nil
+}
+
+func (k *Kafka) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]any{
+		"records": []map[string]any{{"key": event.Subject, "value": event}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("kafka REST proxy returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int    `json:"error_code"`
+			Error     *string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(data, &result); err == nil {
+		for _, offset := range result.Offsets {
+			if offset.Error != nil {
+				return fmt.Errorf("kafka rejected event: %s", *offset.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func (k *Kafka) String() string {
+	return "kafka:" + k.URL
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const natsTimeout = 10 * time.Second
+
+// NATS publishes events to a subject on a NATS server using the core
+// protocol. Each publish opens its own connection, which is plenty for a
+// handful of events per job.
+type NATS struct {
+	Addr     string
+	Subject  string
+	User     string
+	Password string
+	Token    string
+}
+
+// NewNATS parses nats://[user:password@|token@]host[:port]/subject.
+func NewNATS(target string) (*NATS, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q (expected nats://host:port/subject)", target)
+	}
+	subject := strings.Trim(u.Path, "/")
+	if subject == "" || strings.ContainsAny(subject, " \t*>") {
+		return nil, fmt.Errorf("invalid NATS subject %q in %s", subject, target)
+	}
+	n := &NATS{Addr: u.Host, Subject: subject}
+	if u.Port() == "" {
+		n.Addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			n.User, n.Password = u.User.Username(), password
+		} else {
+			n.Token = u.User.Username()
+		}
+	}
+	return n, nil
+}
+
+func (n *NATS) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	dialer := net.Dialer{Timeout: natsTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(natsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	r := bufio.NewReader(conn)
+	info, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read server info: %w", err)
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		return fmt.Errorf("unexpected greeting from %s: %q", n.Addr, strings.TrimSpace(info))
+	}
+
+	connect, err := json.Marshal(map[string]any{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       DefaultSource,
+		"lang":       "go",
+		"version":    "1",
+		"user":       n.User,
+		"pass":       n.Password,
+		"auth_token": n.Token,
+	})
+	if err != nil {
+		return err
+	}
+	// PING after PUB makes the server answer once it has processed
+	// everything before it, including any authorization error.
+	msg := fmt.Sprintf("CONNECT %s\r\nPUB %s %d\r\n%s\r\nPING\r\n", connect, n.Subject, len(payload), payload)
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("no acknowledgement from %s: %w", n.Addr, err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		}
+	}
+}
+
+func (n *NATS) String() string {
+	return "nats:" + n.Addr + "/" + n.Subject
+}
@@ -0,0 +1,106 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	AudioDescriptionAuto = "auto"
+
+	describedOutput          = "audio_description"
+	describesVideo           = "public.accessibility.describes-video"
+	dashRoleScheme           = "urn:mpeg:dash:role:2011"
+	dashAudioPurposeScheme   = "urn:tva:metadata:cs:AudioPurposeCS:2007"
+	dashAudioPurposeDescribe = "1"
+)
+
+func audioStreams(info *types.ProbeInfo) []*types.ProbeStream {
+	var streams []*types.ProbeStream
+	for i := range info.Streams {
+		if info.Streams[i].CodecType == "audio" {
+			streams = append(streams, &info.Streams[i])
+		}
+	}
+	return streams
+}
+
+// selectDescribedAudio finds the source's audio description track. The first
+// audio stream is always the main audio, so it is never picked.
+func selectDescribedAudio(info *types.ProbeInfo, spec string) (*types.ProbeStream, error) {
+	streams := audioStreams(info)
+	if spec == AudioDescriptionAuto {
+		for _, stream := range streams[min(1, len(streams)):] {
+			if stream.Disposition["visual_impaired"] == 1 {
+				return stream, nil
+			}
+		}
+		return nil, nil
+	}
+	n, err := strconv.Atoi(spec)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid audio description stream %q (expected an index above 0 or %s)", spec, AudioDescriptionAuto)
+	}
+	if n >= len(streams) {
+		return nil, fmt.Errorf("audio description stream %d not found (input has %d audio streams)", n, len(streams))
+	}
+	return streams[n], nil
+}
+
+func ValidateAudioDescription(spec string) error {
+	if spec == "" || spec == AudioDescriptionAuto {
+		return nil
+	}
+	if n, err := strconv.Atoi(spec); err != nil || n < 1 {
+		return fmt.Errorf("%q is not an audio stream index above 0 or %s", spec, AudioDescriptionAuto)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) audioMap(outputName string) string {
+	if outputName == describedOutput && vp.described != nil {
+		return "0:" + strconv.Itoa(vp.described.Index)
+	}
+	return "0:a:0"
+}
+
+func (vp *VideoProcessor) encodeDescribedAudio(checkpoint *Checkpoint) error {
+	vp.Logger.Info("Encoding audio description track", "stream", vp.described.Index)
+	return vp.encodeAudioOnly(checkpoint, describedOutput,
+		[]string{"-c:a", "aac", "-profile:a", "aac_low", "-b:a", vp.Config.Renditions[0].AudioBitrate, "-ac", "2"})
+}
+
+func (vp *VideoProcessor) describedLanguage() string {
+	if language := normalizeLanguage(vp.described.Tags["language"]); language != "" {
+		return language
+	}
+	return vp.audioLanguage()
+}
+
+// describedMedia returns the audio description entry for an audio group.
+// Players offer it as an alternative to the group's main track, so it is
+// never the default.
+func (vp *VideoProcessor) describedMedia(group string) hls.MediaRendition {
+	language := vp.describedLanguage()
+	name := strings.TrimSpace(vp.described.Tags["title"])
+	if name == "" {
+		name = "Audio Description"
+		if prefix := languageName(language); prefix != "" {
+			name = prefix + " (Audio Description)"
+		}
+	}
+	return hls.MediaRendition{
+		Type:            "AUDIO",
+		GroupID:         group,
+		Language:        language,
+		Name:            strings.ReplaceAll(name, "\"", "'"),
+		Autoselect:      true,
+		Characteristics: describesVideo,
+		Channels:        "2",
+		URI:             describedOutput + ".m3u8",
+	}
+}
@@ -0,0 +1,140 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	AudioAAC     = "aac"
+	AudioHEAAC   = "he-aac"
+	AudioHEAACv2 = "he-aacv2"
+	AudioOpus    = "opus"
+
+	NoAudioSkip    = "skip"
+	NoAudioSilence = "silence"
+
+	silentAudioSource = "anullsrc=r=48000:cl=stereo"
+)
+
+var passthroughAudioCodecs = map[string]bool{
+	"aac":  true,
+	"ac3":  true,
+	"eac3": true,
+}
+
+func audioStream(info *types.ProbeInfo) *types.ProbeStream {
+	if info == nil {
+		return nil
+	}
+	for i := range info.Streams {
+		if info.Streams[i].CodecType == "audio" {
+			return &info.Streams[i]
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) silentAudioArgs() []string {
+	return []string{"-f", "lavfi", "-i", silentAudioSource}
+}
+
+func audioCodec(rendition types.Rendition) string {
+	if rendition.AudioCodec != "" {
+		return rendition.AudioCodec
+	}
+	return AudioAAC
+}
+
+func (vp *VideoProcessor) canPassthroughAudio(audioRate string) bool {
+	stream := audioStream(vp.source)
+	if !vp.Config.AudioPassthrough || stream == nil || !passthroughAudioCodecs[stream.CodecName] {
+		return false
+	}
+	sourceRate, err := strconv.Atoi(stream.BitRate)
+	if err != nil || sourceRate <= 0 {
+		return false
+	}
+	kbps, err := utils.ParseBitrate(audioRate)
+	if err != nil {
+		return false
+	}
+	return sourceRate <= kbps*1000*11/10
+}
+
+func (vp *VideoProcessor) encodeAudioOnly(checkpoint *Checkpoint, outputName string, codecArgs []string) error {
+	playlist := filepath.Join(vp.workingDir(), outputName+".m3u8")
+	if checkpoint.rendition(outputName).Completed {
+		vp.Logger.Info("Skipping completed rendition", "output", outputName)
+		return nil
+	}
+
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, vp.Config.ExtraInputArgs...)
+	args = append(args, vp.windowArgs()...)
+	args = append(args, vp.inputArgs()...)
+	switch {
+	case vp.silentAudio:
+		args = append(args, vp.silentAudioArgs()...)
+		args = append(args, "-map", "1:a:0", "-t", strconv.FormatFloat(vp.progressDuration(), 'f', 3, 64))
+	case vp.audioOnly && vp.Config.AudioPoster != "":
+		args = append(args, vp.posterArgs()...)
+	default:
+		args = append(args, "-map", vp.audioMap(outputName), "-vn")
+	}
+	args = append(args, codecArgs...)
+	args = append(args, "-hls_time", strconv.Itoa(vp.Config.SegmentTime), "-hls_list_size", "0", "-hls_flags", vp.hlsFlags())
+	args = append(args, vp.segmentArgs(outputName)...)
+	args = append(args, playlist)
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Debug("Running ffmpeg", "output", outputName, "command", ffmpegCmd.String())
+	done := vp.startStage(StageEncode, outputName)
+	err := vp.runEncoder(ffmpegCmd, outputName)
+	done(err)
+	if cpErr := checkpoint.update(outputName, playlist, err == nil); cpErr != nil {
+		vp.Logger.Error("Failed to write checkpoint", "output", outputName, "error", cpErr)
+	}
+	if err != nil {
+		vp.Logger.Error("Error processing audio rendition", "output", outputName, "error", err)
+		return fmt.Errorf("error processing audio rendition %s: %w", outputName, err)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) audioArgs(rendition types.Rendition) ([]string, error) {
+	if vp.noAudio {
+		return []string{"-an"}, nil
+	}
+	audioRate := rendition.AudioBitrate
+	if vp.canPassthroughAudio(audioRate) {
+		return []string{"-c:a", "copy"}, nil
+	}
+
+	channelArgs, err := vp.channelArgs()
+	if err != nil {
+		return nil, err
+	}
+	switch codec := audioCodec(rendition); codec {
+	case AudioAAC:
+		return append([]string{"-c:a", "aac", "-profile:a", "aac_low", "-b:a", audioRate}, channelArgs...), nil
+	case AudioHEAAC, AudioHEAACv2:
+		if config := vp.Report.FFmpegConfig; config != "" && !strings.Contains(config, "--enable-libfdk-aac") {
+			return nil, fmt.Errorf("audio codec %s requires an ffmpeg built with --enable-libfdk-aac", codec)
+		}
+		profile := "aac_he"
+		if codec == AudioHEAACv2 {
+			profile = "aac_he_v2"
+		}
+		return append([]string{"-c:a", "libfdk_aac", "-profile:a", profile, "-b:a", audioRate}, channelArgs...), nil
+	case AudioOpus:
+		return append([]string{"-c:a", "libopus", "-b:a", audioRate}, channelArgs...), nil
+	default:
+		return nil, fmt.Errorf("unknown audio codec %q (expected %s, %s, %s or %s)", codec, AudioAAC, AudioHEAAC, AudioHEAACv2, AudioOpus)
+	}
+}
@@ -0,0 +1,68 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const posterHeight = 360
+
+var DefaultAudioLadder = []string{"64k", "128k", "256k"}
+
+func audioLadderOutput(bitrate string) string {
+	return "audio_" + bitrate
+}
+
+func (vp *VideoProcessor) audioLadder() []string {
+	if len(vp.Config.AudioLadder) > 0 {
+		return vp.Config.AudioLadder
+	}
+	return DefaultAudioLadder
+}
+
+func (vp *VideoProcessor) posterArgs() []string {
+	gop := strconv.Itoa(vp.Config.SegmentTime)
+	return []string{"-loop", "1", "-framerate", "1", "-i", vp.Config.AudioPoster,
+		"-map", "0:a:0", "-map", "1:v:0", "-shortest",
+		"-c:v", "libx264", "-tune", "stillimage", "-preset", vp.Config.Preset, "-pix_fmt", "yuv420p",
+		"-vf", fmt.Sprintf("scale=-2:%d", posterHeight), "-g", gop, "-keyint_min", gop, "-sc_threshold", "0"}
+}
+
+func (vp *VideoProcessor) encodeAudioLadder() error {
+	if vp.Config.DASH {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("DASH output is not supported for audio-only inputs"))
+	}
+	vp.audioOnly = true
+	vp.Logger.Info("Source has no video stream, encoding an audio-only ladder", "bitrates", vp.audioLadder(), "poster", vp.Config.AudioPoster)
+
+	checkpoint, err := vp.loadCheckpoint()
+	if err != nil {
+		vp.Logger.Error("Failed to load checkpoint", "error", err)
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	for _, bitrate := range vp.audioLadder() {
+		codecArgs, err := vp.audioArgs(types.Rendition{AudioBitrate: bitrate, AudioCodec: vp.Config.Renditions[0].AudioCodec})
+		if err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		if err := vp.encodeAudioOnly(checkpoint, audioLadderOutput(bitrate), codecArgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) audioVariants() []hls.Variant {
+	var variants []hls.Variant
+	for _, bitrate := range vp.audioLadder() {
+		kbps, _ := utils.ParseBitrate(bitrate)
+		output := audioLadderOutput(bitrate)
+		variants = append(variants, vp.variantBandwidth(output, kbps*1000).variant(filepath.Base(output)+".m3u8"))
+	}
+	return variants
+}
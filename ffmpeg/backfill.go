@@ -0,0 +1,201 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gastrader/go_ffmpeg/storage"
+)
+
+var BackfillExtensions = []string{".mp4", ".mov", ".m4v", ".mkv", ".webm", ".avi", ".mxf", ".mpg", ".mpeg"}
+
+func isBackfillSource(key string, extensions []string) bool {
+	ext := strings.ToLower(path.Ext(key))
+	for _, allowed := range extensions {
+		if ext == strings.ToLower(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func backfillStem(prefix, key string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/")
+	return strings.TrimSuffix(rel, path.Ext(rel))
+}
+
+func (vp *VideoProcessor) backfilled(keyPrefix string) (bool, error) {
+	_, err := vp.Storage.Stat(context.Background(), keyPrefix+"/"+UploadManifestName)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (vp *VideoProcessor) Backfill(source storage.Storage, prefix string, extensions []string) error {
+	if vp.Storage == nil || vp.KeyPrefix == "" {
+		return fmt.Errorf("backfill requires a destination bucket and key prefix")
+	}
+	destination := strings.TrimSuffix(vp.KeyPrefix, "/")
+
+	keys, err := source.List(context.Background(), prefix)
+	if err != nil {
+		vp.Logger.Error("Failed to list source objects", "storage", source.String(), "prefix", prefix, "error", err)
+		return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+	}
+
+	var pending []backfillItem
+	skipped, failed := 0, 0
+	for _, key := range keys {
+		if !isBackfillSource(key, extensions) {
+			continue
+		}
+		if source.String() == vp.Storage.String() && strings.HasPrefix(key, destination+"/") {
+			continue
+		}
+		stem := backfillStem(prefix, key)
+		keyPrefix := path.Join(destination, stem)
+
+		done, err := vp.backfilled(keyPrefix)
+		if err != nil {
+			vp.Logger.Error("Failed to check for upload manifest", "key", key, "keyPrefix", keyPrefix, "error", err)
+			failed++
+			continue
+		}
+		if done {
+			vp.Logger.Info("Skipping already processed object", "key", key, "keyPrefix", keyPrefix)
+			skipped++
+			continue
+		}
+		pending = append(pending, backfillItem{key: key, stem: stem, keyPrefix: keyPrefix})
+	}
+
+	processed, pipelineFailed := vp.runBackfillPipeline(source, pending)
+	failed += pipelineFailed
+	vp.Logger.Info("Backfill finished", "source", source.String(), "prefix", prefix, "processed", processed, "skipped", skipped, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects failed to process", failed, processed+skipped+failed)
+	}
+	return nil
+}
+
+type backfillItem struct {
+	key       string
+	stem      string
+	keyPrefix string
+	workDir   string
+	job       *VideoProcessor
+	duplicate bool
+}
+
+func (vp *VideoProcessor) runBackfillPipeline(source storage.Storage, items []backfillItem) (int, int) {
+	var mu sync.Mutex
+	processed, failed := 0, 0
+	finish := func(item backfillItem, err error) {
+		if item.workDir != "" {
+			os.RemoveAll(item.workDir)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			vp.Logger.Error("Failed to process object", "key", item.key, "error", err)
+			failed++
+			return
+		}
+		vp.Logger.Info("Processed object", "key", item.key, "keyPrefix", item.keyPrefix)
+		processed++
+	}
+
+	queue := make(chan backfillItem)
+	encoded := make(chan backfillItem)
+	var encoders, uploaders sync.WaitGroup
+	for i := 0; i < max(vp.EncodeJobs, 1); i++ {
+		encoders.Add(1)
+		go func() {
+			defer encoders.Done()
+			for item := range queue {
+				if err := vp.control.err(); err != nil {
+					finish(item, err)
+					continue
+				}
+				if err := vp.encodeBackfill(source, &item); err != nil || item.duplicate {
+					finish(item, err)
+					continue
+				}
+				encoded <- item
+			}
+		}()
+	}
+	for i := 0; i < max(vp.UploadJobs, 1); i++ {
+		uploaders.Add(1)
+		go func() {
+			defer uploaders.Done()
+			for item := range encoded {
+				finish(item, item.job.uploadJob())
+			}
+		}()
+	}
+
+	for _, item := range items {
+		queue <- item
+	}
+	close(queue)
+	encoders.Wait()
+	close(encoded)
+	uploaders.Wait()
+	return processed, failed
+}
+
+func (vp *VideoProcessor) encodeBackfill(source storage.Storage, item *backfillItem) error {
+	workDir, err := os.MkdirTemp(vp.ScratchDir, "go-ffmpeg-backfill-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	item.workDir = workDir
+
+	inputFile := filepath.Join(workDir, "input"+path.Ext(item.key))
+	if err := downloadObject(source, item.key, inputFile); err != nil {
+		return err
+	}
+	vp.Logger.Info("Downloaded input", "storage", source.String(), "key", item.key)
+
+	outputDir := filepath.Join(workDir, "output")
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	item.job = vp.forJob(Job{
+		InputFile: inputFile,
+		OutputDir: outputDir,
+		S3Bucket:  vp.S3Bucket,
+		KeyPrefix: item.keyPrefix,
+		VideoID:   path.Base(item.stem),
+		Storage:   vp.Storage,
+	})
+	item.duplicate, err = item.job.encodeJob()
+	return err
+}
+
+func downloadObject(source storage.Storage, key, dst string) error {
+	body, err := source.Get(context.Background(), key)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer body.Close()
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return file.Close()
+}
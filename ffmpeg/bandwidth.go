@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+)
+
+type bandwidth struct {
+	peak    int
+	average int
+}
+
+func (b bandwidth) plus(other bandwidth) bandwidth {
+	sum := bandwidth{peak: b.peak + other.peak}
+	if b.average > 0 && other.average > 0 {
+		sum.average = b.average + other.average
+	}
+	return sum
+}
+
+func (b bandwidth) variant(uri string) hls.Variant {
+	return hls.Variant{URI: uri, Bandwidth: b.peak, AverageBandwidth: b.average}
+}
+
+func rangeSize(byteRange string) (int64, error) {
+	start, end, _ := strings.Cut(byteRange, "-")
+	s, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	e, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return e - s + 1, nil
+}
+
+func (vp *VideoProcessor) measureBandwidth(name string) (bandwidth, error) {
+	playlist, err := readMediaPlaylist(filepath.Join(vp.workingDir(), name+".m3u8"))
+	if err != nil {
+		return bandwidth{}, err
+	}
+
+	var totalBytes int64
+	var totalDuration, peak float64
+	for i, segment := range playlist.segments {
+		var size int64
+		if playlist.ranges[i] != "" {
+			if size, err = rangeSize(playlist.ranges[i]); err != nil {
+				return bandwidth{}, fmt.Errorf("invalid byte range for %s: %w", segment, err)
+			}
+		} else {
+			info, err := os.Stat(filepath.Join(vp.workingDir(), segment))
+			if err != nil {
+				return bandwidth{}, err
+			}
+			size = info.Size()
+		}
+		duration := playlist.durations[i]
+		if duration <= 0 {
+			continue
+		}
+		totalBytes += size
+		totalDuration += duration
+		peak = math.Max(peak, float64(size*8)/duration)
+	}
+	if totalDuration == 0 {
+		return bandwidth{}, fmt.Errorf("%s has no segments", name)
+	}
+	return bandwidth{
+		peak:    int(math.Ceil(peak)),
+		average: int(math.Ceil(float64(totalBytes*8) / totalDuration)),
+	}, nil
+}
+
+func (vp *VideoProcessor) variantBandwidth(name string, nominal int) bandwidth {
+	measured, err := vp.measureBandwidth(name)
+	if err != nil {
+		vp.Logger.Warn("Could not measure bandwidth, using the nominal bitrate", "output", name, "bandwidth", nominal, "error", err)
+		return bandwidth{peak: nominal}
+	}
+	vp.Logger.Debug("Measured bandwidth", "output", name, "peak", measured.peak, "average", measured.average)
+	return measured
+}
@@ -0,0 +1,76 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	BurninTimecode    = "timecode"
+	BurninFrameNumber = "framenumber"
+	BurninFilename    = "filename"
+
+	reviewOutput = "review"
+	reviewHeight = 720
+)
+
+func ValidateBurnin(items []string) error {
+	for _, item := range items {
+		switch item {
+		case BurninTimecode, BurninFrameNumber, BurninFilename:
+		default:
+			return fmt.Errorf("unknown burn-in %q (expected %s, %s or %s)", item, BurninTimecode, BurninFrameNumber, BurninFilename)
+		}
+	}
+	return nil
+}
+
+func reviewRendition(renditions []types.Rendition) types.Rendition {
+	review := renditions[0]
+	for _, rendition := range renditions {
+		if rendition.Height <= reviewHeight && (review.Height > reviewHeight || rendition.Height > review.Height) {
+			review = rendition
+		}
+	}
+	review.Name = reviewOutput
+	return review
+}
+
+func escapeDrawtext(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `'\''`, ":", `\:`, "%", `\%`).Replace(text)
+}
+
+func (vp *VideoProcessor) sourceTimecode() string {
+	if stream := vp.sourceVideo(); stream != nil && stream.Tags["timecode"] != "" {
+		return stream.Tags["timecode"]
+	}
+	if vp.source != nil && vp.source.Format.Tags["timecode"] != "" {
+		return vp.source.Format.Tags["timecode"]
+	}
+	return "00:00:00:00"
+}
+
+func (vp *VideoProcessor) burninFilters(height int, fps float64) []string {
+	style := fmt.Sprintf("fontcolor=white:fontsize=%d:box=1:boxcolor=black@0.5:boxborderw=6", max(12, height/24))
+	if vp.Config.BurninFont != "" {
+		style += ":fontfile='" + escapeDrawtext(vp.Config.BurninFont) + "'"
+	}
+	var filters []string
+	for _, item := range vp.Config.Burnin {
+		switch item {
+		case BurninTimecode:
+			filters = append(filters, fmt.Sprintf("drawtext=timecode='%s':rate=%s:x=16:y=16:%s",
+				escapeDrawtext(vp.sourceTimecode()), strconv.FormatFloat(fps, 'f', -1, 64), style))
+		case BurninFrameNumber:
+			filters = append(filters, fmt.Sprintf("drawtext=text='%%{frame_num}':x=w-tw-16:y=16:%s", style))
+		case BurninFilename:
+			filters = append(filters, fmt.Sprintf("drawtext=text='%s':x=16:y=h-th-16:%s",
+				escapeDrawtext(filepath.Base(vp.InputFile)), style))
+		}
+	}
+	return filters
+}
@@ -0,0 +1,89 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Capabilities struct {
+	FFmpegPath     string   `json:"ffmpegPath"`
+	FFmpegVersion  string   `json:"ffmpegVersion,omitempty"`
+	FFprobePath    string   `json:"ffprobePath"`
+	FFprobeVersion string   `json:"ffprobeVersion,omitempty"`
+	VideoEncoders  []string `json:"videoEncoders,omitempty"`
+	AudioEncoders  []string `json:"audioEncoders,omitempty"`
+	HWAccels       []string `json:"hwaccels,omitempty"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+func parseEncoders(output string) (video, audio []string) {
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			listing = strings.HasPrefix(strings.TrimSpace(line), "---")
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0][0] {
+		case 'V':
+			video = append(video, fields[1])
+		case 'A':
+			audio = append(audio, fields[1])
+		}
+	}
+	return video, audio
+}
+
+func parseHWAccels(output string) []string {
+	var accels []string
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Hardware acceleration methods:"):
+			listing = true
+		case listing && line != "":
+			accels = append(accels, line)
+		}
+	}
+	return accels
+}
+
+func (vp *VideoProcessor) toolOutput(binary string, args ...string) (string, error) {
+	cmd := vp.command(binary, append([]string{"-hide_banner"}, args...)...)
+	vp.Logger.Debug("Probing ffmpeg capabilities", "command", cmd.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s %s: %w", binary, strings.Join(args, " "), err)
+	}
+	return string(output), nil
+}
+
+func (vp *VideoProcessor) ProbeCapabilities() *Capabilities {
+	caps := &Capabilities{FFmpegPath: vp.FFmpegPath, FFprobePath: vp.FFprobePath}
+	version, err := vp.toolVersion(vp.FFmpegPath)
+	if err != nil {
+		caps.Errors = append(caps.Errors, err.Error())
+		return caps
+	}
+	caps.FFmpegVersion = version.Raw
+	if version, err := vp.toolVersion(vp.FFprobePath); err != nil {
+		caps.Errors = append(caps.Errors, err.Error())
+	} else {
+		caps.FFprobeVersion = version.Raw
+	}
+	if output, err := vp.toolOutput(vp.FFmpegPath, "-encoders"); err != nil {
+		caps.Errors = append(caps.Errors, err.Error())
+	} else {
+		caps.VideoEncoders, caps.AudioEncoders = parseEncoders(output)
+	}
+	if output, err := vp.toolOutput(vp.FFmpegPath, "-hwaccels"); err != nil {
+		caps.Errors = append(caps.Errors, err.Error())
+	} else {
+		caps.HWAccels = parseHWAccels(output)
+	}
+	return caps
+}
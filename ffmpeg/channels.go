@@ -0,0 +1,87 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	surroundOutput         = "audio_surround"
+	defaultSurroundBitrate = "384k"
+	loudnessCompensation   = "loudnorm=I=-16:TP=-1.5:LRA=11"
+)
+
+var downmixMatrices = map[string]string{
+	"itu":      "pan=stereo|c0<c0+0.707*c2+0.707*c4|c1<c1+0.707*c2+0.707*c5",
+	"dialogue": "pan=stereo|c0<0.5*c0+c2+0.3*c4|c1<0.5*c1+c2+0.3*c5",
+}
+
+func (vp *VideoProcessor) channelArgs() ([]string, error) {
+	args := []string{"-ac", "2"}
+	stream := audioStream(vp.source)
+	if stream == nil || stream.Channels <= 2 {
+		return args, nil
+	}
+
+	var filters []string
+	switch {
+	case vp.Config.DownmixFilter != "":
+		filters = append(filters, vp.Config.DownmixFilter)
+	case vp.Config.Downmix == "":
+	default:
+		matrix, ok := downmixMatrices[vp.Config.Downmix]
+		if !ok {
+			return nil, fmt.Errorf("unknown downmix %q (expected itu or dialogue)", vp.Config.Downmix)
+		}
+		filters = append(filters, matrix)
+	}
+	if vp.Config.LoudnessCompensation {
+		filters = append(filters, loudnessCompensation)
+	}
+	if len(filters) == 0 {
+		return args, nil
+	}
+	return append([]string{"-af", strings.Join(filters, ",")}, args...), nil
+}
+
+func (vp *VideoProcessor) surroundBitrate() string {
+	if vp.Config.SurroundBitrate != "" {
+		return vp.Config.SurroundBitrate
+	}
+	return defaultSurroundBitrate
+}
+
+func (vp *VideoProcessor) encodeSurround(checkpoint *Checkpoint) error {
+	codecArgs := []string{"-c:a", "aac", "-b:a", vp.surroundBitrate(), "-ac", "6"}
+	if stream := audioStream(vp.source); vp.Config.AudioPassthrough && passthroughAudioCodecs[stream.CodecName] {
+		codecArgs = []string{"-c:a", "copy"}
+	}
+	return vp.encodeAudioOnly(checkpoint, surroundOutput, codecArgs)
+}
+
+func (vp *VideoProcessor) surroundBandwidth() int {
+	kbps, _ := utils.ParseBitrate(vp.surroundBitrate())
+	return kbps
+}
+
+func (vp *VideoProcessor) audioMedia() []hls.MediaRendition {
+	var stereoURI string
+	if vp.separateAudio {
+		stereoURI = audioOutput + ".m3u8"
+	}
+	media := []hls.MediaRendition{vp.audioRendition("stereo", "Stereo", "2", stereoURI)}
+	if vp.described != nil {
+		media = append(media, vp.describedMedia("stereo"))
+	}
+	if !vp.surround {
+		return media
+	}
+	media = append(media, vp.audioRendition("surround", "Surround 5.1", "6", surroundOutput+".m3u8"))
+	if vp.described != nil {
+		media = append(media, vp.describedMedia("surround"))
+	}
+	return media
+}
@@ -0,0 +1,143 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const checkpointFile = "checkpoint.json"
+
+type RenditionCheckpoint struct {
+	Segments  int     `json:"segments"`
+	Offset    float64 `json:"offset"`
+	Completed bool    `json:"completed"`
+}
+
+type Checkpoint struct {
+	InputFile  string                          `json:"inputFile"`
+	InputSize  int64                           `json:"inputSize"`
+	InputMTime time.Time                       `json:"inputMTime"`
+	Renditions map[string]*RenditionCheckpoint `json:"renditions"`
+
+	mu   sync.Mutex
+	path string
+}
+
+func (vp *VideoProcessor) loadCheckpoint() (*Checkpoint, error) {
+	info, err := vp.statInput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+
+	cp := &Checkpoint{
+		InputFile:  vp.InputFile,
+		InputSize:  info.Size(),
+		InputMTime: info.ModTime().UTC(),
+		Renditions: map[string]*RenditionCheckpoint{},
+		path:       filepath.Join(vp.workingDir(), checkpointFile),
+	}
+	if !vp.Resume {
+		return cp, cp.save()
+	}
+
+	data, err := os.ReadFile(cp.path)
+	if errors.Is(err, os.ErrNotExist) {
+		vp.Logger.Info("No checkpoint found, starting from scratch", "path", cp.path)
+		return cp, cp.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var previous Checkpoint
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", cp.path, err)
+	}
+	if previous.InputSize != cp.InputSize || !previous.InputMTime.Equal(cp.InputMTime) {
+		return nil, fmt.Errorf("checkpoint %s was written for a different version of %s", cp.path, previous.InputFile)
+	}
+
+	for _, rendition := range vp.Config.Renditions {
+		outputName := rendition.Name
+		playlist := filepath.Join(vp.workingDir(), fmt.Sprintf("%s.m3u8", outputName))
+		segments, offset, ended, err := readPlaylistProgress(playlist)
+		if err != nil {
+			continue
+		}
+		completed := ended
+		if prev, ok := previous.Renditions[outputName]; ok && prev.Completed {
+			completed = true
+		}
+		cp.Renditions[outputName] = &RenditionCheckpoint{Segments: segments, Offset: offset, Completed: completed}
+		vp.Logger.Info("Resuming rendition from checkpoint", "output", outputName, "segments", segments, "offset", offset, "completed", completed)
+	}
+	return cp, cp.save()
+}
+
+func (cp *Checkpoint) rendition(outputName string) RenditionCheckpoint {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if rc, ok := cp.Renditions[outputName]; ok {
+		return *rc
+	}
+	return RenditionCheckpoint{}
+}
+
+func (cp *Checkpoint) update(outputName, playlist string, completed bool) error {
+	segments, offset, _, err := readPlaylistProgress(playlist)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	cp.mu.Lock()
+	cp.Renditions[outputName] = &RenditionCheckpoint{Segments: segments, Offset: offset, Completed: completed}
+	cp.mu.Unlock()
+	return cp.save()
+}
+
+func (cp *Checkpoint) save() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(cp.path, data, 0644)
+}
+
+func readPlaylistProgress(playlist string) (segments int, duration float64, ended bool, err error) {
+	file, err := os.Open(playlist)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, 0, false, fmt.Errorf("invalid EXTINF in %s: %w", playlist, err)
+			}
+			segments++
+			duration += seconds
+		case line == "#EXT-X-ENDLIST":
+			ended = true
+		}
+	}
+	return segments, duration, ended, scanner.Err()
+}
@@ -0,0 +1,373 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	ClipMP4 = "mp4"
+	ClipHLS = "hls"
+
+	clipFileName      = "clip.mp4"
+	clipEdgeTolerance = 0.05
+)
+
+var clipEncodeArgs = []string{"-c:v", "libx264", "-preset", "veryfast", "-crf", "18", "-c:a", "aac", "-b:a", "128k"}
+
+func ParseClockTime(value string) (float64, error) {
+	parts := strings.Split(value, ":")
+	if value == "" || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q (expected [HH:]MM:SS[.mmm] or seconds)", value)
+	}
+	var seconds float64
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time %q (expected [HH:]MM:SS[.mmm] or seconds)", value)
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+type clipWindow struct {
+	segments []hls.Segment
+	starts   []float64
+}
+
+func windowSegments(media *hls.MediaPlaylist, start, end float64) clipWindow {
+	var window clipWindow
+	position := 0.0
+	for _, segment := range media.Segments {
+		if position+segment.Duration > start && position < end {
+			window.segments = append(window.segments, segment)
+			window.starts = append(window.starts, position)
+		}
+		position += segment.Duration
+	}
+	return window
+}
+
+func segmentPath(playlist, uri string) (string, error) {
+	if strings.Contains(uri, "://") {
+		return "", fmt.Errorf("%s references the absolute URI %s; clip the original output instead", playlist, uri)
+	}
+	return path.Join(path.Dir(playlist), uri), nil
+}
+
+func flatName(name string) string {
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+func (vp *VideoProcessor) readMedia(source hls.Source, name string) (*hls.MediaPlaylist, error) {
+	data, err := source.Read(name)
+	if err != nil {
+		vp.Logger.Error("Failed to read playlist", "source", source.String(), "playlist", name, "error", err)
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	media, err := hls.ParseMedia(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return media, nil
+}
+
+func (vp *VideoProcessor) fetchSegment(source hls.Source, playlist, uri, dst string) error {
+	name, err := segmentPath(playlist, uri)
+	if err != nil {
+		return err
+	}
+	data, err := source.Read(name)
+	if err != nil {
+		vp.Logger.Error("Failed to read segment", "source", source.String(), "segment", name, "error", err)
+		return fmt.Errorf("failed to read segment %s: %w", name, err)
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func (vp *VideoProcessor) Clip(source hls.Source, playlist string, start, end float64, format, baseURL string) error {
+	if end <= start {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("clip end %gs must be after its start %gs", end, start))
+	}
+	if err := os.MkdirAll(vp.OutputDir, os.ModePerm); err != nil {
+		vp.Logger.Error("Failed to create clip directory", "outputDir", vp.OutputDir, "error", err)
+		return fmt.Errorf("failed to create clip directory: %w", err)
+	}
+	data, err := source.Read(playlist)
+	if err != nil {
+		vp.Logger.Error("Failed to read playlist", "source", source.String(), "playlist", playlist, "error", err)
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("failed to read %s: %w", playlist, err))
+	}
+
+	vp.Logger.Info("Clipping HLS output", "source", source.String(), "playlist", playlist, "start", start, "end", end, "format", format)
+	switch format {
+	case ClipMP4:
+		return vp.clipMP4(source, playlist, data, start, end)
+	case ClipHLS:
+		return vp.clipHLS(source, playlist, data, start, end, baseURL)
+	default:
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("unknown clip format %q (expected %s or %s)", format, ClipMP4, ClipHLS))
+	}
+}
+
+func clipInputs(playlist string, data []byte) ([]string, error) {
+	if !hls.IsMaster(data) {
+		return []string{playlist}, nil
+	}
+	master, err := hls.ParseMaster(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", playlist, err)
+	}
+	if len(master.Variants) == 0 {
+		return nil, fmt.Errorf("%s has no variants", playlist)
+	}
+	variants := append([]hls.Variant(nil), master.Variants...)
+	sort.SliceStable(variants, func(i, j int) bool {
+		a, _ := strconv.Atoi(variants[i].Attributes["BANDWIDTH"])
+		b, _ := strconv.Atoi(variants[j].Attributes["BANDWIDTH"])
+		return a > b
+	})
+	top := variants[0]
+	inputs := []string{path.Join(path.Dir(playlist), top.URI)}
+	for _, rendition := range master.Renditions {
+		attrs := rendition.Attributes
+		if attrs["TYPE"] == "AUDIO" && attrs["URI"] != "" && attrs["GROUP-ID"] == top.Attributes["AUDIO"] && attrs["DEFAULT"] == "YES" {
+			inputs = append(inputs, path.Join(path.Dir(playlist), attrs["URI"]))
+			break
+		}
+	}
+	return inputs, nil
+}
+
+func (vp *VideoProcessor) localizeWindow(source hls.Source, name string, start, end float64, dir string) (string, float64, error) {
+	media, err := vp.readMedia(source, name)
+	if err != nil {
+		return "", 0, err
+	}
+	window := windowSegments(media, start, end)
+	if len(window.segments) == 0 {
+		return "", 0, fmt.Errorf("%s has no segments between %gs and %gs", name, start, end)
+	}
+
+	prefix := strings.TrimSuffix(flatName(name), ".m3u8")
+	fetched := map[string]string{}
+	fetch := func(uri string) (string, error) {
+		if file, ok := fetched[uri]; ok {
+			return file, nil
+		}
+		file := fmt.Sprintf("%s_%03d%s", prefix, len(fetched), path.Ext(uri))
+		if err := vp.fetchSegment(source, name, uri, filepath.Join(dir, file)); err != nil {
+			return "", err
+		}
+		fetched[uri] = file
+		return file, nil
+	}
+
+	lines := []string{"#EXTM3U", "#EXT-X-VERSION:7", fmt.Sprintf("#EXT-X-TARGETDURATION:%d", media.TargetDuration), "#EXT-X-PLAYLIST-TYPE:VOD"}
+	if media.Map != "" {
+		file, err := fetch(media.Map)
+		if err != nil {
+			return "", 0, err
+		}
+		lines = append(lines, fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"", file))
+	}
+	for _, segment := range window.segments {
+		file, err := fetch(segment.URI)
+		if err != nil {
+			return "", 0, err
+		}
+		lines = append(lines, fmt.Sprintf("#EXTINF:%.6f,", segment.Duration))
+		if segment.ByteRange != "" {
+			lines = append(lines, "#EXT-X-BYTERANGE:"+segment.ByteRange)
+		}
+		lines = append(lines, file)
+	}
+	lines = append(lines, "#EXT-X-ENDLIST")
+
+	local := filepath.Join(dir, prefix+".m3u8")
+	if err := os.WriteFile(local, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return "", 0, err
+	}
+	return local, window.starts[0], nil
+}
+
+func (vp *VideoProcessor) clipMP4(source hls.Source, playlist string, data []byte, start, end float64) error {
+	inputs, err := clipInputs(playlist, data)
+	if err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	dir, err := os.MkdirTemp(vp.ScratchDir, "go-ffmpeg-clip-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(vp.OutputDir, clipFileName)
+	args := []string{"-y"}
+	for _, input := range inputs {
+		local, offset, err := vp.localizeWindow(source, input, start, end, dir)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-ss", strconv.FormatFloat(start-offset, 'f', 3, 64), "-i", local)
+	}
+	if vp.Fake {
+		vp.Logger.Info("Fake mode: writing a synthetic clip instead of running ffmpeg", "output", output)
+		return os.WriteFile(output, []byte("fake clip\n"), 0644)
+	}
+	if len(inputs) > 1 {
+		args = append(args, "-map", "0:v:0", "-map", "1:a:0")
+	}
+	args = append(args, "-t", strconv.FormatFloat(end-start, 'f', 3, 64))
+	args = append(args, clipEncodeArgs...)
+	args = append(args, "-movflags", "+faststart", output)
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, clipFileName); err != nil {
+		vp.Logger.Error("Error writing clip", "output", output, "error", err)
+		return fmt.Errorf("error writing clip: %w", err)
+	}
+	vp.Logger.Info("Wrote clip", "output", output, "seconds", end-start)
+	return nil
+}
+
+func (vp *VideoProcessor) clipHLS(source hls.Source, playlist string, data []byte, start, end float64, baseURL string) error {
+	if !hls.IsMaster(data) {
+		return vp.clipMedia(source, playlist, flatName(path.Base(playlist)), start, end, baseURL, "")
+	}
+	if _, err := hls.ParseMaster(data); err != nil {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("%s: %w", playlist, err))
+	}
+
+	var lines []string
+	pendingBandwidth := ""
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF"):
+			continue
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			pendingBandwidth = hls.ParseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))["BANDWIDTH"]
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			uri := hls.ParseAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))["URI"]
+			if uri != "" {
+				name := path.Join(path.Dir(playlist), uri)
+				if err := vp.clipMedia(source, name, flatName(uri), start, end, baseURL, ""); err != nil {
+					return err
+				}
+				line = strings.Replace(line, `URI="`+uri+`"`, `URI="`+flatName(uri)+`"`, 1)
+			}
+		case !strings.HasPrefix(line, "#"):
+			name := path.Join(path.Dir(playlist), line)
+			if err := vp.clipMedia(source, name, flatName(line), start, end, baseURL, pendingBandwidth); err != nil {
+				return err
+			}
+			line = flatName(line)
+		}
+		lines = append(lines, line)
+	}
+
+	master := filepath.Join(vp.OutputDir, path.Base(playlist))
+	if err := os.WriteFile(master, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		vp.Logger.Error("Failed to write clip master playlist", "path", master, "error", err)
+		return fmt.Errorf("failed to write clip master playlist: %w", err)
+	}
+	vp.Logger.Info("Wrote clip playlists", "outputDir", vp.OutputDir, "master", master, "seconds", end-start)
+	return nil
+}
+
+func (vp *VideoProcessor) clipMedia(source hls.Source, name, output string, start, end float64, baseURL, bandwidth string) error {
+	media, err := vp.readMedia(source, name)
+	if err != nil {
+		return err
+	}
+	if media.Map != "" {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("%s uses fMP4 segments; HLS clips need MPEG-TS segments, use --format mp4", name))
+	}
+	window := windowSegments(media, start, end)
+	if len(window.segments) == 0 {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("%s has no segments between %gs and %gs", name, start, end))
+	}
+	for _, segment := range window.segments {
+		if segment.ByteRange != "" {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("%s uses byte ranges; HLS clips need one file per segment, use --format mp4", name))
+		}
+	}
+
+	stem := strings.TrimSuffix(output, ".m3u8")
+	lines := []string{"#EXTM3U", "#EXT-X-VERSION:3", "", "#EXT-X-MEDIA-SEQUENCE:0", "#EXT-X-PLAYLIST-TYPE:VOD"}
+	target := 0.0
+	previousPartial := false
+	for i, segment := range window.segments {
+		segStart, segEnd := window.starts[i], window.starts[i]+segment.Duration
+		from, to := math.Max(start, segStart), math.Min(end, segEnd)
+		partial := from-segStart > clipEdgeTolerance || segEnd-to > clipEdgeTolerance
+
+		var uri string
+		if partial {
+			uri = fmt.Sprintf("%s_clip_%03d.ts", stem, i)
+			if err := vp.encodeClipEdge(source, name, segment.URI, from-segStart, to-from, bandwidth, filepath.Join(vp.OutputDir, uri)); err != nil {
+				return err
+			}
+		} else {
+			segmentName, err := segmentPath(name, segment.URI)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			uri = strings.TrimSuffix(baseURL, "/") + "/" + segmentName
+		}
+		if i > 0 && (partial || previousPartial) {
+			lines = append(lines, "#EXT-X-DISCONTINUITY")
+		}
+		previousPartial = partial
+		lines = append(lines, fmt.Sprintf("#EXTINF:%.6f,", to-from), uri)
+		target = math.Max(target, to-from)
+	}
+	lines[2] = fmt.Sprintf("#EXT-X-TARGETDURATION:%d", int(math.Ceil(target)))
+	lines = append(lines, "#EXT-X-ENDLIST")
+
+	if err := os.WriteFile(filepath.Join(vp.OutputDir, output), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		vp.Logger.Error("Failed to write clip playlist", "playlist", output, "error", err)
+		return fmt.Errorf("failed to write clip playlist %s: %w", output, err)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) encodeClipEdge(source hls.Source, playlist, uri string, offset, length float64, bandwidth, output string) error {
+	original := output + ".src" + path.Ext(uri)
+	if err := vp.fetchSegment(source, playlist, uri, original); err != nil {
+		return err
+	}
+	if vp.Fake {
+		vp.Logger.Info("Fake mode: copying the edge segment instead of re-encoding it", "output", output)
+		return os.Rename(original, output)
+	}
+	defer os.Remove(original)
+
+	args := []string{"-y", "-ss", strconv.FormatFloat(offset, 'f', 3, 64), "-i", original,
+		"-t", strconv.FormatFloat(length, 'f', 3, 64)}
+	args = append(args, clipEncodeArgs...)
+	if bps, err := strconv.Atoi(bandwidth); err == nil && bps > 0 {
+		args = append(args, "-maxrate", strconv.Itoa(bps), "-bufsize", strconv.Itoa(bps*2))
+	}
+	args = append(args, "-f", "mpegts", output)
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, filepath.Base(output)); err != nil {
+		vp.Logger.Error("Error re-encoding clip edge", "segment", uri, "error", err)
+		return fmt.Errorf("error re-encoding clip edge %s: %w", uri, err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const DefaultVideoCodec = "libx264"
+
+var (
+	VideoCodecs = []string{"libx264", "h264_nvenc", "h264_videotoolbox"}
+	x264Presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+)
+
+func ValidateVideoCodec(codec, preset string) error {
+	if !slices.Contains(VideoCodecs, codec) {
+		return fmt.Errorf("unknown codec %q (expected %s)", codec, strings.Join(VideoCodecs, ", "))
+	}
+	if codec == "libx264" && !slices.Contains(x264Presets, preset) {
+		return fmt.Errorf("unknown preset %q (expected %s)", preset, strings.Join(x264Presets, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	ComposePIP        = "pip"
+	ComposeSideBySide = "side-by-side"
+
+	DefaultPIPPosition = "bottom-right"
+	DefaultPIPScale    = 0.25
+
+	composedInputName = "composed-input.mkv"
+	pipMargin         = 16
+)
+
+var pipPositions = map[string]string{
+	"top-left":     fmt.Sprintf("%d:%d", pipMargin, pipMargin),
+	"top-right":    fmt.Sprintf("main_w-overlay_w-%d:%d", pipMargin, pipMargin),
+	"bottom-left":  fmt.Sprintf("%d:main_h-overlay_h-%d", pipMargin, pipMargin),
+	"bottom-right": fmt.Sprintf("main_w-overlay_w-%d:main_h-overlay_h-%d", pipMargin, pipMargin),
+}
+
+func composition(config types.VideoProcessingConfig) (string, string, float64) {
+	layout, position, scale := config.ComposeLayout, config.PIPPosition, config.PIPScale
+	if layout == "" {
+		layout = ComposePIP
+	}
+	if position == "" {
+		position = DefaultPIPPosition
+	}
+	if scale == 0 {
+		scale = DefaultPIPScale
+	}
+	return layout, position, scale
+}
+
+func ValidateComposition(config types.VideoProcessingConfig) error {
+	if config.ComposeWith == "" {
+		return nil
+	}
+	layout, position, scale := composition(config)
+	switch layout {
+	case ComposePIP:
+		if _, ok := pipPositions[position]; !ok {
+			return fmt.Errorf("invalid picture-in-picture position %q (expected top-left, top-right, bottom-left or bottom-right)", position)
+		}
+		if scale <= 0 || scale >= 1 {
+			return fmt.Errorf("picture-in-picture scale must be between 0 and 1, got %g", scale)
+		}
+	case ComposeSideBySide:
+	default:
+		return fmt.Errorf("invalid layout %q (expected %s or %s)", layout, ComposePIP, ComposeSideBySide)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) compositionFilter(main *types.ProbeStream) string {
+	layout, position, scale := composition(vp.Config)
+	width, height := displaySize(main)
+	if layout == ComposeSideBySide {
+		return fmt.Sprintf("[0:%d]scale=-2:%d,setsar=1[left];[1:v]scale=-2:%d,setsar=1[right];[left][right]hstack=inputs=2:shortest=1,format=yuv420p[v]",
+			main.Index, height&^1, height&^1)
+	}
+	pipWidth := int(float64(width)*scale) &^ 1
+	return fmt.Sprintf("[1:v]scale=%d:-2,setsar=1[pip];[0:%d]setsar=1[main];[main][pip]overlay=%s:shortest=1,format=yuv420p[v]",
+		pipWidth, main.Index, pipPositions[position])
+}
+
+func (vp *VideoProcessor) composeInputs() error {
+	if err := ValidateComposition(vp.Config); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	info, err := vp.probeFile(vp.sourceFile())
+	if err != nil {
+		vp.Logger.Error("Failed to probe input", "file", vp.InputFile, "error", err)
+		return types.NewExitError(types.ExitProbe, fmt.Errorf("failed to probe input %s: %w", vp.InputFile, err))
+	}
+	main, _ := selectVideoStream(info, vp.Config.VideoStream)
+	if main == nil {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("input %s has no video stream to compose onto", vp.InputFile))
+	}
+	audioMap := "0:a:0?"
+	if audioStream(info) == nil {
+		audioMap = "1:a:0?"
+	}
+
+	output := filepath.Join(vp.workingDir(), composedInputName)
+	cmd := vp.command(vp.FFmpegPath, "-y", "-v", "error", "-i", vp.sourceFile(), "-i", vp.Config.ComposeWith,
+		"-filter_complex", vp.compositionFilter(main), "-map", "[v]", "-map", audioMap,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "16", "-c:a", "aac", "-b:a", "192k", output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	layout, _, _ := composition(vp.Config)
+	vp.Logger.Info("Composing inputs", "main", vp.InputFile, "with", vp.Config.ComposeWith, "layout", layout)
+	vp.Logger.Debug("Running ffmpeg", "command", cmd.String())
+	if err := cmd.Run(); err != nil {
+		vp.Logger.Error("Failed to compose inputs", "error", err, "output", strings.TrimSpace(stderr.String()))
+		return types.NewExitError(types.ExitEncode, fmt.Errorf("failed to compose inputs: %w", err))
+	}
+	vp.composed = output
+	vp.Config.VideoStream = ""
+	return nil
+}
+
+func (vp *VideoProcessor) removeComposedInput() {
+	if vp.composed == "" {
+		return
+	}
+	if err := os.Remove(vp.composed); err != nil && !os.IsNotExist(err) {
+		vp.Logger.Warn("Failed to remove composed input", "path", vp.composed, "error", err)
+	}
+	vp.composed = ""
+}
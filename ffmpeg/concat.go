@@ -0,0 +1,158 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	concatInputName = "concat-input.mkv"
+	concatListName  = "concat-list.txt"
+)
+
+type concatFormat struct {
+	video string
+	audio string
+}
+
+func streamFormat(info *types.ProbeInfo) concatFormat {
+	var format concatFormat
+	if video, _ := selectVideoStream(info, ""); video != nil {
+		format.video = fmt.Sprintf("%s %dx%d %s %s", video.CodecName, video.Width, video.Height, video.PixFmt, video.AvgFrameRate)
+	}
+	if audio := audioStream(info); audio != nil {
+		format.audio = fmt.Sprintf("%s %s %d", audio.CodecName, audio.SampleRate, audio.Channels)
+	}
+	return format
+}
+
+func (vp *VideoProcessor) concatInputs() error {
+	infos := make([]*types.ProbeInfo, len(vp.ConcatInputs))
+	for i, input := range vp.ConcatInputs {
+		if IsImageSequence(input) || isStillImage(input) {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("image input %s can't be concatenated", input))
+		}
+		info, err := vp.probeFile(input)
+		if err != nil {
+			vp.Logger.Error("Failed to probe concat input", "file", input, "error", err)
+			return types.NewExitError(types.ExitProbe, fmt.Errorf("failed to probe concat input %s: %w", input, err))
+		}
+		if video, _ := selectVideoStream(info, ""); video == nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("concat input %s has no video stream", input))
+		}
+		infos[i] = info
+	}
+
+	parts := vp.ConcatInputs
+	for i, info := range infos[1:] {
+		if streamFormat(info) == streamFormat(infos[0]) {
+			continue
+		}
+		vp.Logger.Info("Concat inputs differ, conforming them before joining", "first", vp.ConcatInputs[0], "differs", vp.ConcatInputs[i+1])
+		conformed, err := vp.conformInputs(infos)
+		defer removeFiles(conformed)
+		if err != nil {
+			return err
+		}
+		parts = conformed
+		break
+	}
+
+	var list bytes.Buffer
+	for _, part := range parts {
+		abs, err := filepath.Abs(part)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+	}
+	listPath := filepath.Join(vp.workingDir(), concatListName)
+	if err := os.WriteFile(listPath, list.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write concat list: %w", err)
+	}
+	defer os.Remove(listPath)
+
+	output := filepath.Join(vp.workingDir(), concatInputName)
+	cmd := vp.command(vp.FFmpegPath, "-y", "-v", "error", "-f", "concat", "-safe", "0", "-i", listPath,
+		"-map", "0:v:0", "-map", "0:a:0?", "-c", "copy", output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	vp.Logger.Info("Concatenating inputs", "inputs", len(parts), "output", output)
+	vp.Logger.Debug("Running ffmpeg", "command", cmd.String())
+	if err := cmd.Run(); err != nil {
+		vp.Logger.Error("Failed to concatenate inputs", "error", err, "output", strings.TrimSpace(stderr.String()))
+		return types.NewExitError(types.ExitEncode, fmt.Errorf("failed to concatenate inputs: %w", err))
+	}
+	vp.concatenated = output
+	return nil
+}
+
+func (vp *VideoProcessor) conformInputs(infos []*types.ProbeInfo) ([]string, error) {
+	video, _ := selectVideoStream(infos[0], "")
+	width, height := displaySize(video)
+	width, height = width&^1, height&^1
+	frameRate := video.AvgFrameRate
+	if fps, err := utils.ParseFrameRate(frameRate); err != nil || fps <= 0 {
+		frameRate = "30"
+	}
+	withAudio := false
+	for _, info := range infos {
+		withAudio = withAudio || audioStream(info) != nil
+	}
+
+	var parts []string
+	for i, input := range vp.ConcatInputs {
+		part := filepath.Join(vp.workingDir(), fmt.Sprintf("concat-part-%03d.mkv", i))
+		args := []string{"-y", "-v", "error", "-i", input}
+		switch {
+		case !withAudio:
+			args = append(args, "-map", "0:v:0", "-an")
+		case audioStream(infos[i]) == nil:
+			args = append(args, "-f", "lavfi", "-i", silentAudioSource, "-map", "0:v:0", "-map", "1:a:0", "-shortest")
+		default:
+			args = append(args, "-map", "0:v:0", "-map", "0:a:0")
+		}
+		args = append(args,
+			"-vf", fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=yuv420p",
+				width, height, width, height, frameRate),
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "16")
+		if withAudio {
+			args = append(args, "-c:a", "aac", "-b:a", "192k", "-ar", "48000", "-ac", "2")
+		}
+		args = append(args, part)
+
+		cmd := vp.command(vp.FFmpegPath, args...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		vp.Logger.Info("Conforming concat input", "file", input, "size", fmt.Sprintf("%dx%d", width, height), "frameRate", frameRate)
+		vp.Logger.Debug("Running ffmpeg", "command", cmd.String())
+		parts = append(parts, part)
+		if err := cmd.Run(); err != nil {
+			vp.Logger.Error("Failed to conform concat input", "file", input, "error", err, "output", strings.TrimSpace(stderr.String()))
+			return parts, types.NewExitError(types.ExitEncode, fmt.Errorf("failed to conform concat input %s: %w", input, err))
+		}
+	}
+	return parts, nil
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+func (vp *VideoProcessor) removeConcatInput() {
+	if vp.concatenated == "" {
+		return
+	}
+	if err := os.Remove(vp.concatenated); err != nil && !os.IsNotExist(err) {
+		vp.Logger.Warn("Failed to remove concatenated input", "path", vp.concatenated, "error", err)
+	}
+	vp.concatenated = ""
+}
@@ -0,0 +1,241 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	StateRunning   = "running"
+	StatePaused    = "paused"
+	StateCancelled = "cancelled"
+	StateDone      = "done"
+	StateFailed    = "failed"
+)
+
+var ErrCancelled = errors.New("cancelled")
+
+type ProgressEvent struct {
+	Type     string  `json:"type"`
+	Output   string  `json:"output,omitempty"`
+	State    string  `json:"state,omitempty"`
+	Seconds  float64 `json:"seconds,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Percent  float64 `json:"percent,omitempty"`
+	Speed    string  `json:"speed,omitempty"`
+	FPS      float64 `json:"fps,omitempty"`
+	Bitrate  string  `json:"bitrate,omitempty"`
+	Q        float64 `json:"q,omitempty"`
+	Bytes    int64   `json:"bytes,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type control struct {
+	mu        sync.Mutex
+	processes map[*os.Process]bool
+	paused    chan struct{}
+	cancelled bool
+	pausedAt  time.Time
+	pausedFor time.Duration
+}
+
+// pausedTime returns how long encoding has been paused in total, including
+// a pause in progress, and whether it is paused now.
+func (c *control) pausedTime() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := c.pausedFor
+	if c.paused != nil {
+		total += time.Since(c.pausedAt)
+	}
+	return total, c.paused != nil
+}
+
+func (c *control) start(cmd *exec.Cmd) error {
+	for {
+		c.mu.Lock()
+		if c.cancelled {
+			c.mu.Unlock()
+			return types.NewExitError(types.ExitCancelled, ErrCancelled)
+		}
+		if c.paused == nil {
+			isolateProcess(cmd)
+			err := cmd.Start()
+			if err == nil {
+				if c.processes == nil {
+					c.processes = map[*os.Process]bool{}
+				}
+				c.processes[cmd.Process] = true
+			}
+			c.mu.Unlock()
+			return err
+		}
+		wait := c.paused
+		c.mu.Unlock()
+		<-wait
+	}
+}
+
+func (c *control) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancelled {
+		return types.NewExitError(types.ExitCancelled, ErrCancelled)
+	}
+	return nil
+}
+
+func (c *control) finish(process *os.Process, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.processes, process)
+	if c.cancelled {
+		return types.NewExitError(types.ExitCancelled, ErrCancelled)
+	}
+	return err
+}
+
+func (vp *VideoProcessor) Pause() error {
+	if vp.ContainerRuntime != "" {
+		return errors.New("pausing is not supported with a container runtime")
+	}
+	c := vp.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancelled {
+		return ErrCancelled
+	}
+	if c.paused != nil {
+		return nil
+	}
+	for process := range c.processes {
+		if err := suspendProcess(process); err != nil {
+			for other := range c.processes {
+				resumeProcess(other)
+			}
+			return err
+		}
+	}
+	c.paused = make(chan struct{})
+	c.pausedAt = time.Now()
+	vp.Logger.Info("Paused encoding")
+	vp.emit(ProgressEvent{Type: "state", State: StatePaused})
+	return nil
+}
+
+func (vp *VideoProcessor) Unpause() error {
+	c := vp.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused == nil {
+		return nil
+	}
+	for process := range c.processes {
+		if err := resumeProcess(process); err != nil {
+			return err
+		}
+	}
+	close(c.paused)
+	c.paused = nil
+	c.pausedFor += time.Since(c.pausedAt)
+	vp.Logger.Info("Resumed encoding")
+	vp.emit(ProgressEvent{Type: "state", State: StateRunning})
+	return nil
+}
+
+func (vp *VideoProcessor) Cancel() {
+	c := vp.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancelled {
+		return
+	}
+	c.cancelled = true
+	for process := range c.processes {
+		if c.paused != nil {
+			resumeProcess(process)
+		}
+		killProcess(process)
+	}
+	if c.paused != nil {
+		close(c.paused)
+		c.paused = nil
+		c.pausedFor += time.Since(c.pausedAt)
+	}
+	vp.Logger.Warn("Cancelling encoding")
+	vp.emit(ProgressEvent{Type: "state", State: StateCancelled})
+}
+
+func (vp *VideoProcessor) emit(event ProgressEvent) {
+	if vp.Progress != nil {
+		vp.Progress(event)
+	}
+}
+
+func (vp *VideoProcessor) progressDuration() float64 {
+	var duration float64
+	if vp.source != nil {
+		duration = probeDuration(vp.source)
+	}
+	if vp.Sample > 0 && (duration == 0 || vp.Sample.Seconds() < duration) {
+		duration = vp.Sample.Seconds()
+	}
+	return duration
+}
+
+func (vp *VideoProcessor) readProgress(r io.Reader, output string, watch *watchdog) {
+	duration := vp.progressDuration()
+	event := ProgressEvent{Type: "progress", Output: output, Duration: duration}
+	started := time.Now()
+	var sampled time.Time
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		switch key {
+		case "out_time":
+			event.Seconds = parseTimestamp(value)
+			if duration > 0 {
+				event.Percent = math.Min(100, math.Round(event.Seconds/duration*1000)/10)
+			}
+		case "speed":
+			event.Speed = strings.TrimSpace(value)
+		case "fps":
+			event.FPS, _ = strconv.ParseFloat(value, 64)
+		case "bitrate":
+			event.Bitrate = strings.TrimSpace(value)
+		case "progress":
+			watch.advance(event.Seconds)
+			if vp.StatsInterval > 0 && (sampled.IsZero() || time.Since(sampled) >= vp.StatsInterval || value == "end") {
+				sampled = time.Now()
+				vp.recordStats(output, time.Since(started), event)
+			}
+			vp.emit(event)
+		default:
+			if strings.HasPrefix(key, "stream_") && strings.HasSuffix(key, "_q") {
+				event.Q, _ = strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+}
+
+func parseTimestamp(value string) float64 {
+	var seconds float64
+	for _, part := range strings.Split(value, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + v
+	}
+	return math.Max(0, seconds)
+}
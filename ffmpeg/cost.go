@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const bytesPerGB = 1e9
+
+type UnitPrices struct {
+	ComputePerHour    float64
+	StoragePerGBMonth float64
+	DeliveryPerGB     float64
+}
+
+var DefaultUnitPrices = UnitPrices{
+	ComputePerHour:    0.17,
+	StoragePerGBMonth: 0.023,
+	DeliveryPerGB:     0.085,
+}
+
+var segmentSuffix = regexp.MustCompile(`_(\d+|init)$`)
+
+func outputStem(name string) string {
+	return segmentSuffix.ReplaceAllString(strings.TrimSuffix(name, filepath.Ext(name)), "")
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
+
+func (vp *VideoProcessor) costReport() *types.CostReport {
+	if vp.Prices == nil {
+		return nil
+	}
+	if vp.Report.Cost == nil {
+		vp.Report.Cost = &types.CostReport{
+			ComputePerHour:    vp.Prices.ComputePerHour,
+			StoragePerGBMonth: vp.Prices.StoragePerGBMonth,
+			DeliveryPerGB:     vp.Prices.DeliveryPerGB,
+		}
+	}
+	return vp.Report.Cost
+}
+
+func (vp *VideoProcessor) estimateCost(info *types.ProbeInfo) {
+	cost := vp.costReport()
+	if cost == nil {
+		return
+	}
+	cost.EstimatedBytes = int64(vp.EstimateOutputSize(info))
+	cost.EstimatedStoragePerMonth = roundCost(float64(cost.EstimatedBytes) / bytesPerGB * cost.StoragePerGBMonth)
+	vp.Logger.Info("Estimated output cost", "bytes", cost.EstimatedBytes, "storagePerMonth", cost.EstimatedStoragePerMonth)
+}
+
+func (vp *VideoProcessor) measureCost(encodeStarted time.Time) error {
+	cost := vp.costReport()
+	if cost == nil {
+		return nil
+	}
+	cost.EncodeSeconds = math.Round(time.Since(encodeStarted).Seconds()*10) / 10
+	cost.ComputeCost = roundCost(cost.EncodeSeconds / 3600 * cost.ComputePerHour)
+
+	sizes := map[string]int64{}
+	var outputs []string
+	cost.OutputBytes = 0
+	err := filepath.WalkDir(vp.OutputDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name := entry.Name()
+		if name == checkpointFile || name == utils.OutputManifest || name == UploadManifestName {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		cost.OutputBytes += info.Size()
+		sizes[vp.outputStem(name)] += info.Size()
+		if filepath.Ext(name) == ".m3u8" && name != masterPlaylistName && !isMasterPlaylist(path) {
+			outputs = append(outputs, vp.outputStem(name))
+		}
+		return nil
+	})
+	if err != nil {
+		vp.Logger.Error("Failed to measure output size", "outputDir", vp.OutputDir, "error", err)
+		return err
+	}
+	cost.StoragePerMonth = roundCost(float64(cost.OutputBytes) / bytesPerGB * cost.StoragePerGBMonth)
+
+	sort.Strings(outputs)
+	duration := vp.progressDuration()
+	cost.Renditions = nil
+	for _, name := range outputs {
+		rendition := types.RenditionCost{Name: name, Bytes: sizes[name]}
+		if duration > 0 {
+			rendition.Kbps = int(float64(rendition.Bytes) * 8 / duration / 1000)
+			rendition.DeliveryPerHourViewed = roundCost(float64(rendition.Bytes) / duration * 3600 / bytesPerGB * cost.DeliveryPerGB)
+		}
+		cost.Renditions = append(cost.Renditions, rendition)
+	}
+	vp.Logger.Info("Output cost", "encodeSeconds", cost.EncodeSeconds, "computeCost", cost.ComputeCost,
+		"bytes", cost.OutputBytes, "storagePerMonth", cost.StoragePerMonth)
+	return nil
+}
@@ -0,0 +1,42 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const roleSessionName = "video-processor"
+
+func (vp *VideoProcessor) AssumeRole(cfg *aws.Config) error {
+	if vp.AssumeRoleARN == "" {
+		if vp.ExternalID != "" {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("an external ID requires a role to assume"))
+		}
+		return nil
+	}
+	if !strings.HasPrefix(vp.AssumeRoleARN, "arn:") {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid role ARN %q", vp.AssumeRoleARN))
+	}
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(*cfg), vp.AssumeRoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+		if vp.ExternalID != "" {
+			o.ExternalID = aws.String(vp.ExternalID)
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	vp.Logger.Info("Assuming IAM role for S3 access", "roleArn", vp.AssumeRoleARN)
+	return nil
+}
+
+func (vp *VideoProcessor) NewS3Storage(client *s3.Client, bucket string) *storage.S3 {
+	s := storage.NewS3(client, bucket)
+	s.RequesterPays = vp.RequesterPays
+	return s
+}
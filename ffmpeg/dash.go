@@ -0,0 +1,356 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	dashManifestName = "manifest.mpd"
+	audioOutput      = "audio"
+)
+
+func (vp *VideoProcessor) fragmentedMP4() bool {
+	return vp.Config.DASH || vp.fmp4 || vp.Config.SegmentFormat == SegmentM4S || vp.Config.SegmentFormat == SegmentMP4
+}
+
+func (vp *VideoProcessor) hlsFlags(extra ...string) string {
+	flags := vp.Config.HLSFlags
+	if len(flags) == 0 {
+		flags = DefaultHLSFlags
+	}
+	if vp.Config.SingleFile {
+		extra = append([]string{"single_file"}, extra...)
+	}
+	seen := map[string]bool{}
+	var combined []string
+	for _, flag := range append(append([]string(nil), flags...), extra...) {
+		if !seen[flag] {
+			seen[flag] = true
+			combined = append(combined, flag)
+		}
+	}
+	return strings.Join(combined, "+")
+}
+
+func (vp *VideoProcessor) segmentArgs(outputName string) []string {
+	segment := vp.segmentPattern(outputName)
+	if vp.Config.SingleFile {
+		segment = outputName
+	}
+	var args []string
+	if vp.keyInfo != "" {
+		args = append(args, "-hls_key_info_file", vp.keyInfo)
+	}
+	if !vp.fragmentedMP4() {
+		return append(args, "-hls_segment_filename", filepath.Join(vp.workingDir(), segment+vp.segmentExtension()))
+	}
+	if vp.Config.SegmentFormat == SegmentTS {
+		vp.Logger.Warn("Source needs fMP4 segments, ignoring the TS segment format", "output", outputName)
+	}
+	return append(args,
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", vp.initSegmentName(outputName),
+		"-hls_segment_filename", filepath.Join(vp.workingDir(), segment+vp.segmentExtension()),
+	)
+}
+
+type mediaPlaylist struct {
+	initURI   string
+	initRange string
+	segments  []string
+	ranges    []string
+	durations []float64
+}
+
+func dashRange(byteRange string, next int64) (string, int64, error) {
+	length, offset, hasOffset := strings.Cut(byteRange, "@")
+	n, err := strconv.ParseInt(length, 10, 64)
+	if err != nil || n <= 0 {
+		return "", 0, fmt.Errorf("invalid byte range %q", byteRange)
+	}
+	start := next
+	if hasOffset {
+		if start, err = strconv.ParseInt(offset, 10, 64); err != nil {
+			return "", 0, fmt.Errorf("invalid byte range %q", byteRange)
+		}
+	}
+	return fmt.Sprintf("%d-%d", start, start+n-1), start + n, nil
+}
+
+func readMediaPlaylist(path string) (*mediaPlaylist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	playlist := &mediaPlaylist{}
+	var pendingRange string
+	var next int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			_, uri, _ := strings.Cut(line, `URI="`)
+			playlist.initURI, _, _ = strings.Cut(uri, `"`)
+			if _, value, ok := strings.Cut(line, `BYTERANGE="`); ok {
+				value, _, _ = strings.Cut(value, `"`)
+				if playlist.initRange, next, err = dashRange(value, 0); err != nil {
+					return nil, fmt.Errorf("invalid EXT-X-MAP in %s: %w", path, err)
+				}
+			}
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			if pendingRange, next, err = dashRange(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"), next); err != nil {
+				return nil, fmt.Errorf("invalid EXT-X-BYTERANGE in %s: %w", path, err)
+			}
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			duration, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EXTINF in %s: %w", path, err)
+			}
+			playlist.durations = append(playlist.durations, duration)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			playlist.segments = append(playlist.segments, line)
+			playlist.ranges = append(playlist.ranges, pendingRange)
+			pendingRange = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(playlist.segments) != len(playlist.durations) {
+		return nil, fmt.Errorf("%s has %d segments but %d EXTINF tags", path, len(playlist.segments), len(playlist.durations))
+	}
+	return playlist, nil
+}
+
+type mpd struct {
+	XMLName                   xml.Name `xml:"MPD"`
+	Xmlns                     string   `xml:"xmlns,attr"`
+	Profiles                  string   `xml:"profiles,attr"`
+	Type                      string   `xml:"type,attr"`
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string   `xml:"minBufferTime,attr"`
+	Period                    struct {
+		AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+type mpdAdaptationSet struct {
+	ContentType      string              `xml:"contentType,attr"`
+	MimeType         string              `xml:"mimeType,attr"`
+	Lang             string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr"`
+	Roles            []mpdDescriptor     `xml:"Role"`
+	Accessibility    []mpdDescriptor     `xml:"Accessibility"`
+	Representations  []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdDescriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+type mpdRepresentation struct {
+	ID                string `xml:"id,attr"`
+	Bandwidth         int    `xml:"bandwidth,attr"`
+	Codecs            string `xml:"codecs,attr,omitempty"`
+	Width             int    `xml:"width,attr,omitempty"`
+	Height            int    `xml:"height,attr,omitempty"`
+	AudioSamplingRate int    `xml:"audioSamplingRate,attr,omitempty"`
+	SegmentList       struct {
+		Timescale      int `xml:"timescale,attr"`
+		Initialization *struct {
+			SourceURL string `xml:"sourceURL,attr"`
+			Range     string `xml:"range,attr,omitempty"`
+		} `xml:"Initialization,omitempty"`
+		SegmentTimeline struct {
+			S []struct {
+				D int `xml:"d,attr"`
+			} `xml:"S"`
+		} `xml:"SegmentTimeline"`
+		SegmentURLs []struct {
+			Media      string `xml:"media,attr"`
+			MediaRange string `xml:"mediaRange,attr,omitempty"`
+		} `xml:"SegmentURL"`
+	} `xml:"SegmentList"`
+}
+
+func h264Codec(level string) string {
+	value, err := strconv.ParseFloat(level, 64)
+	if err != nil {
+		return "avc1.640028"
+	}
+	return fmt.Sprintf("avc1.6400%02x", int(math.Round(value*10)))
+}
+
+func audioCodecString(rendition types.Rendition) string {
+	switch audioCodec(rendition) {
+	case AudioHEAAC:
+		return "mp4a.40.5"
+	case AudioHEAACv2:
+		return "mp4a.40.29"
+	case AudioOpus:
+		return "opus"
+	}
+	return "mp4a.40.2"
+}
+
+func newRepresentation(id string, bandwidth int, playlist *mediaPlaylist) mpdRepresentation {
+	rep := mpdRepresentation{ID: id, Bandwidth: bandwidth}
+	rep.SegmentList.Timescale = 1000
+	if playlist.initURI != "" {
+		rep.SegmentList.Initialization = &struct {
+			SourceURL string `xml:"sourceURL,attr"`
+			Range     string `xml:"range,attr,omitempty"`
+		}{playlist.initURI, playlist.initRange}
+	}
+	for i, segment := range playlist.segments {
+		rep.SegmentList.SegmentTimeline.S = append(rep.SegmentList.SegmentTimeline.S, struct {
+			D int `xml:"d,attr"`
+		}{int(math.Round(playlist.durations[i] * 1000))})
+		rep.SegmentList.SegmentURLs = append(rep.SegmentList.SegmentURLs, struct {
+			Media      string `xml:"media,attr"`
+			MediaRange string `xml:"mediaRange,attr,omitempty"`
+		}{segment, playlist.ranges[i]})
+	}
+	return rep
+}
+
+func (vp *VideoProcessor) GenerateDASHManifest() error {
+	path := filepath.Join(vp.workingDir(), dashManifestName)
+	vp.Logger.Info("Generating DASH manifest", "path", path)
+
+	manifest := mpd{
+		Xmlns:         "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:      "urn:mpeg:dash:profile:isoff-main:2011",
+		Type:          "static",
+		MinBufferTime: fmt.Sprintf("PT%dS", vp.Config.SegmentTime),
+	}
+
+	video := mpdAdaptationSet{ContentType: "video", MimeType: "video/mp4", SegmentAlignment: true}
+	webm := mpdAdaptationSet{ContentType: "video", MimeType: "video/webm", SegmentAlignment: true}
+	var duration float64
+	for _, rendition := range vp.Config.Renditions {
+		outputName := rendition.Name
+		playlist, err := readMediaPlaylist(filepath.Join(vp.workingDir(), outputName+".m3u8"))
+		if err != nil {
+			return fmt.Errorf("failed to read playlist for %s: %w", outputName, err)
+		}
+		rep := newRepresentation(outputName, rendition.VideoKbps()*1000, playlist)
+		level := rendition.Level
+		if actual, ok := vp.outputLevels[outputName]; ok {
+			level = actual
+		}
+		rep.Width, rep.Height = rendition.Width, rendition.Height
+		if actual, ok := vp.outputSizes[outputName]; ok {
+			rep.Width, rep.Height, _ = types.ParseResolution(actual)
+		}
+		if rendition.WebM() {
+			if level == "" || level == types.LevelAuto {
+				level = minimumVP9Level(rep.Width, rep.Height, 30)
+			}
+			rep.Codecs = vp9Codec(level)
+			webm.Representations = append(webm.Representations, rep)
+		} else {
+			rep.Codecs = h264Codec(level)
+			video.Representations = append(video.Representations, rep)
+		}
+
+		var total float64
+		for _, d := range playlist.durations {
+			total += d
+		}
+		duration = math.Max(duration, total)
+	}
+	manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, video)
+	if len(webm.Representations) > 0 {
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, webm)
+	}
+
+	audio := mpdAdaptationSet{ContentType: "audio", MimeType: "audio/mp4", Lang: vp.audioLanguage(), SegmentAlignment: true}
+	for _, track := range []struct {
+		name, bitrate, codecs string
+		enabled               bool
+	}{
+		{audioOutput, vp.Config.Renditions[0].AudioBitrate, audioCodecString(vp.Config.Renditions[0]), vp.separateAudio},
+		{surroundOutput, vp.surroundBitrate(), "mp4a.40.2", vp.surround},
+	} {
+		if !track.enabled {
+			continue
+		}
+		playlist, err := readMediaPlaylist(filepath.Join(vp.workingDir(), track.name+".m3u8"))
+		if err != nil {
+			return fmt.Errorf("failed to read playlist for %s: %w", track.name, err)
+		}
+		kbps, err := utils.ParseBitrate(track.bitrate)
+		if err != nil {
+			return fmt.Errorf("audio track %s: %w", track.name, err)
+		}
+		rep := newRepresentation(track.name, kbps*1000, playlist)
+		rep.Codecs = track.codecs
+		audio.Representations = append(audio.Representations, rep)
+	}
+	if len(audio.Representations) > 0 {
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, audio)
+	}
+	if vp.dolby {
+		playlist, err := readMediaPlaylist(filepath.Join(vp.workingDir(), dolbyOutput+".m3u8"))
+		if err != nil {
+			return fmt.Errorf("failed to read playlist for %s: %w", dolbyOutput, err)
+		}
+		rep := newRepresentation(dolbyOutput, vp.dolbyBandwidth()*1000, playlist)
+		rep.Codecs = dolbyFormats[vp.Config.DolbyAudio].codecs
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, mpdAdaptationSet{
+			ContentType: "audio", MimeType: "audio/mp4", Lang: vp.audioLanguage(), SegmentAlignment: true, Representations: []mpdRepresentation{rep},
+		})
+	}
+	if vp.described != nil {
+		playlist, err := readMediaPlaylist(filepath.Join(vp.workingDir(), describedOutput+".m3u8"))
+		if err != nil {
+			return fmt.Errorf("failed to read playlist for %s: %w", describedOutput, err)
+		}
+		kbps, err := utils.ParseBitrate(vp.Config.Renditions[0].AudioBitrate)
+		if err != nil {
+			return fmt.Errorf("audio track %s: %w", describedOutput, err)
+		}
+		rep := newRepresentation(describedOutput, kbps*1000, playlist)
+		rep.Codecs = "mp4a.40.2"
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, mpdAdaptationSet{
+			ContentType: "audio", MimeType: "audio/mp4", Lang: vp.describedLanguage(), SegmentAlignment: true,
+			Roles:           []mpdDescriptor{{dashRoleScheme, "description"}},
+			Accessibility:   []mpdDescriptor{{dashAudioPurposeScheme, dashAudioPurposeDescribe}},
+			Representations: []mpdRepresentation{rep},
+		})
+	}
+	if renditions := vp.webmRenditions(); len(renditions) > 0 && vp.separateAudio {
+		playlist, err := readMediaPlaylist(filepath.Join(vp.workingDir(), webmAudioOutput+".m3u8"))
+		if err != nil {
+			return fmt.Errorf("failed to read playlist for %s: %w", webmAudioOutput, err)
+		}
+		rep := newRepresentation(webmAudioOutput, renditions[0].AudioKbps()*1000, playlist)
+		rep.Codecs = "opus"
+		manifest.Period.AdaptationSets = append(manifest.Period.AdaptationSets, mpdAdaptationSet{
+			ContentType: "audio", MimeType: "audio/webm", Lang: vp.audioLanguage(), SegmentAlignment: true, Representations: []mpdRepresentation{rep},
+		})
+	}
+	manifest.MediaPresentationDuration = fmt.Sprintf("PT%.3fS", duration)
+
+	data, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode DASH manifest: %w", err)
+	}
+	return utils.WriteFileAtomic(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
@@ -0,0 +1,95 @@
+package ffmpeg
+
+import (
+	"errors"
+	"runtime"
+	"slices"
+	"strconv"
+)
+
+const fastestRetryPreset = "veryfast"
+
+// encodeSettings are the video encoder settings a failed rendition can be
+// retried with.
+type encodeSettings struct {
+	codec   string
+	preset  string
+	threads int
+}
+
+func (vp *VideoProcessor) initialEncodeSettings() encodeSettings {
+	return encodeSettings{codec: vp.Config.VideoCodec, preset: vp.Config.Preset, threads: vp.Threads}
+}
+
+func (s encodeSettings) codecArgs(crf int) []string {
+	switch s.codec {
+	case "h264_nvenc":
+		return []string{"-c:v", "h264_nvenc", "-forced-idr", "1"}
+	case "h264_videotoolbox":
+		return []string{"-c:v", "h264_videotoolbox"}
+	}
+	return []string{"-c:v", "libx264", "-preset", s.preset, "-crf", strconv.Itoa(crf)}
+}
+
+// degrade returns the next safer settings: the software encoder instead of
+// a hardware one, then a faster x264 preset, then half the threads.
+func (s encodeSettings) degrade() (encodeSettings, string, bool) {
+	if s.codec != DefaultVideoCodec {
+		s.codec = DefaultVideoCodec
+		if !slices.Contains(x264Presets, s.preset) {
+			s.preset = fastestRetryPreset
+		}
+		return s, "software encoder " + DefaultVideoCodec, true
+	}
+	current, fastest := slices.Index(x264Presets, s.preset), slices.Index(x264Presets, fastestRetryPreset)
+	if current > fastest {
+		s.preset = x264Presets[max(current-2, fastest)]
+		return s, "preset " + s.preset, true
+	}
+	if s.threads == 0 {
+		s.threads = runtime.NumCPU()
+	}
+	if s.threads > 1 {
+		s.threads /= 2
+		return s, strconv.Itoa(s.threads) + " threads", true
+	}
+	return s, "", false
+}
+
+func retryableFailure(err error) bool {
+	return err != nil && !errors.Is(err, ErrCancelled) && !errors.Is(err, ErrTimeout)
+}
+
+// encodeWithRetries runs a rendition's encode and, when it fails, retries it
+// up to RenditionRetries times with increasingly safer settings.
+func (vp *VideoProcessor) encodeWithRetries(output string, encodeArgs func(encodeSettings) []string) (err error) {
+	done := vp.startStage(StageEncode, output)
+	defer func() { done(err) }()
+	settings := vp.initialEncodeSettings()
+	err = vp.runRetryingEncoder(encodeArgs(settings), output, settings.threads)
+	var changes []string
+	for attempt := 1; attempt <= vp.RenditionRetries && retryableFailure(err); attempt++ {
+		if vp.Config.Reproducible {
+			vp.Logger.Error("Rendition failed; not retrying with safer settings, which would change the reproducible output", "output", output)
+			return err
+		}
+		next, change, ok := settings.degrade()
+		if !ok {
+			vp.Logger.Error("Rendition failed with the safest settings", "output", output, "error", err)
+			return err
+		}
+		settings = next
+		changes = append(changes, change)
+		vp.Logger.Warn("Rendition failed; retrying with safer settings", "output", output, "attempt", attempt+1, "change", change, "error", err)
+		err = vp.runRetryingEncoder(encodeArgs(settings), output, settings.threads)
+	}
+	if err == nil && len(changes) > 0 {
+		vp.statsMu.Lock()
+		defer vp.statsMu.Unlock()
+		if vp.Report.DegradedRenditions == nil {
+			vp.Report.DegradedRenditions = map[string][]string{}
+		}
+		vp.Report.DegradedRenditions[output] = changes
+	}
+	return err
+}
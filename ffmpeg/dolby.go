@@ -0,0 +1,110 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	DolbyAC3  = "ac3"
+	DolbyEAC3 = "eac3"
+
+	dolbyOutput = "audio_dolby"
+)
+
+var dolbyFormats = map[string]struct {
+	name, codecs, bitrate string
+}{
+	DolbyAC3:  {"Dolby Digital", "ac-3", "448k"},
+	DolbyEAC3: {"Dolby Digital Plus", "ec-3", "640k"},
+}
+
+func ValidateDolbyAudio(codec, bitrate string) error {
+	if codec == "" {
+		return nil
+	}
+	if _, ok := dolbyFormats[codec]; !ok {
+		return fmt.Errorf("unknown Dolby audio codec %q (expected %s or %s)", codec, DolbyAC3, DolbyEAC3)
+	}
+	if bitrate == "" {
+		return nil
+	}
+	if _, err := utils.ParseBitrate(bitrate); err != nil {
+		return fmt.Errorf("Dolby audio %w", err)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) dolbyBitrate() string {
+	if vp.Config.DolbyBitrate != "" {
+		return vp.Config.DolbyBitrate
+	}
+	return dolbyFormats[vp.Config.DolbyAudio].bitrate
+}
+
+func (vp *VideoProcessor) dolbyBandwidth() int {
+	if vp.dolbyPassthrough() {
+		if rate, err := strconv.Atoi(audioStream(vp.source).BitRate); err == nil && rate > 0 {
+			return (rate + 999) / 1000
+		}
+	}
+	kbps, _ := utils.ParseBitrate(vp.dolbyBitrate())
+	return kbps
+}
+
+func (vp *VideoProcessor) dolbyChannels() int {
+	channels := 2
+	if stream := audioStream(vp.source); stream != nil && stream.Channels > 0 {
+		channels = stream.Channels
+	}
+	return min(channels, 6)
+}
+
+func (vp *VideoProcessor) dolbyPassthrough() bool {
+	stream := audioStream(vp.source)
+	return stream != nil && stream.CodecName == vp.Config.DolbyAudio && stream.Channels <= 6
+}
+
+func (vp *VideoProcessor) hasEncoder(name string) (bool, error) {
+	output, err := vp.toolOutput(vp.FFmpegPath, "-encoders")
+	if err != nil {
+		return false, err
+	}
+	_, audio := parseEncoders(output)
+	return slices.Contains(audio, name), nil
+}
+
+func (vp *VideoProcessor) encodeDolby(checkpoint *Checkpoint) error {
+	codec := vp.Config.DolbyAudio
+	if vp.dolbyPassthrough() {
+		vp.Logger.Info("Passing Dolby source audio through", "codec", codec, "channels", vp.dolbyChannels())
+		return vp.encodeAudioOnly(checkpoint, dolbyOutput, []string{"-c:a", "copy"})
+	}
+	available, err := vp.hasEncoder(codec)
+	if err != nil {
+		return err
+	}
+	if !available {
+		vp.Logger.Error("ffmpeg has no Dolby audio encoder", "codec", codec, "ffmpeg", vp.FFmpegPath)
+		return fmt.Errorf("%s has no %s encoder; use a build that includes it or a source with %s audio to pass through", vp.FFmpegPath, codec, codec)
+	}
+	return vp.encodeAudioOnly(checkpoint, dolbyOutput,
+		[]string{"-c:a", codec, "-b:a", vp.dolbyBitrate(), "-ac", strconv.Itoa(vp.dolbyChannels())})
+}
+
+func (vp *VideoProcessor) dolbyMedia() hls.MediaRendition {
+	format := dolbyFormats[vp.Config.DolbyAudio]
+	return vp.audioRendition("dolby", format.name, strconv.Itoa(vp.dolbyChannels()), dolbyOutput+".m3u8")
+}
+
+func (vp *VideoProcessor) dolbyCodecs(outputName string) string {
+	level := vp.rendition(outputName).Level
+	if actual, ok := vp.outputLevels[outputName]; ok {
+		level = actual
+	}
+	return h264Codec(level) + "," + dolbyFormats[vp.Config.DolbyAudio].codecs
+}
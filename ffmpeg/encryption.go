@@ -0,0 +1,160 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	defaultKeyName = "enc.key"
+	keyInfoName    = ".enc.keyinfo"
+)
+
+func (vp *VideoProcessor) validateEncryption() error {
+	if !vp.Config.Encrypt {
+		if vp.Config.KeyURI != "" || vp.Config.KeyFile != "" {
+			return fmt.Errorf("a key URI or key file requires encryption to be enabled")
+		}
+		return nil
+	}
+	if vp.Config.DASH {
+		return fmt.Errorf("AES-128 encryption is HLS only and can't be combined with DASH output")
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) videoID() string {
+	if vp.VideoID != "" {
+		return vp.VideoID
+	}
+	return strings.TrimSuffix(filepath.Base(vp.InputFile), filepath.Ext(vp.InputFile))
+}
+
+func expandTemplate(template, videoID, keyPrefix string) string {
+	return strings.NewReplacer("{videoID}", videoID, "{keyPrefix}", strings.Trim(keyPrefix, "/")).Replace(template)
+}
+
+func (vp *VideoProcessor) expandTemplate(template string) string {
+	return expandTemplate(template, vp.videoID(), vp.KeyPrefix)
+}
+
+func (vp *VideoProcessor) keyURI() string {
+	if vp.Config.KeyURI == "" {
+		return defaultKeyName
+	}
+	return vp.expandTemplate(vp.Config.KeyURI)
+}
+
+func (vp *VideoProcessor) keyFile() string {
+	switch {
+	case vp.Config.KeyFile != "":
+		return vp.Config.KeyFile
+	case vp.Config.KeyURI == "":
+		return filepath.Join(vp.workingDir(), defaultKeyName)
+	default:
+		return filepath.Clean(vp.OutputDir) + ".key"
+	}
+}
+
+func (vp *VideoProcessor) prepareEncryption() error {
+	vp.keyInfo = ""
+	if !vp.Config.Encrypt {
+		return nil
+	}
+
+	keyFile := vp.keyFile()
+	if _, err := os.Stat(keyFile); err == nil && vp.Resume {
+		vp.Logger.Info("Reusing encryption key from the interrupted run", "keyFile", keyFile)
+	} else {
+		key := make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("failed to generate encryption key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(keyFile), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create key directory: %w", err)
+		}
+		if err := utils.WriteFileAtomic(keyFile, key, 0600); err != nil {
+			vp.Logger.Error("Failed to write encryption key", "keyFile", keyFile, "error", err)
+			return fmt.Errorf("failed to write encryption key: %w", err)
+		}
+	}
+
+	vp.keyInfo = filepath.Join(vp.workingDir(), keyInfoName)
+	if err := os.WriteFile(vp.keyInfo, []byte(vp.keyURI()+"\n"+keyFile+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write key info file: %w", err)
+	}
+	vp.Logger.Info("Encrypting segments with AES-128", "keyURI", vp.keyURI(), "keyFile", keyFile)
+	if vp.Config.KeyURI != "" && vp.Config.KeyFile == "" {
+		vp.Logger.Warn("The key is not uploaded; publish it at the key URI yourself", "keyFile", keyFile, "keyURI", vp.keyURI())
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) removeKeyInfo() {
+	if vp.keyInfo == "" {
+		return
+	}
+	if err := os.Remove(vp.keyInfo); err != nil && !errors.Is(err, os.ErrNotExist) {
+		vp.Logger.Warn("Failed to remove key info file", "path", vp.keyInfo, "error", err)
+	}
+	vp.keyInfo = ""
+}
+
+func (vp *VideoProcessor) rewriteSegmentURIs() error {
+	if vp.Config.SegmentBaseURL == "" {
+		return nil
+	}
+	base := strings.TrimSuffix(vp.expandTemplate(vp.Config.SegmentBaseURL), "/") + "/"
+	rewrite := func(uri string) string {
+		if strings.Contains(uri, "://") || strings.HasPrefix(uri, "/") {
+			return uri
+		}
+		return base + uri
+	}
+
+	paths, err := vp.outputPlaylists()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if hls.IsMaster(data) {
+			continue
+		}
+
+		var out strings.Builder
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			switch {
+			case strings.HasPrefix(line, "#EXT-X-MAP:"):
+				if uri := hls.ParseAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))["URI"]; uri != "" {
+					line = strings.Replace(line, `URI="`+uri+`"`, `URI="`+rewrite(uri)+`"`, 1)
+				}
+			case line != "" && !strings.HasPrefix(line, "#"):
+				line = rewrite(line)
+			}
+			out.WriteString(line + "\n")
+		}
+		if err := utils.WriteFileAtomic(path, []byte(out.String()), 0644); err != nil {
+			return fmt.Errorf("failed to rewrite %s: %w", path, err)
+		}
+	}
+	vp.Logger.Info("Rewrote segment URIs", "baseURL", base, "playlists", len(paths))
+	return nil
+}
+
+func (vp *VideoProcessor) keyTag() string {
+	return fmt.Sprintf(`#EXT-X-KEY:METHOD=AES-128,URI="%s"`, vp.keyURI())
+}
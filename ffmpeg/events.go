@@ -0,0 +1,57 @@
+package ffmpeg
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/events"
+)
+
+const publishTimeout = 30 * time.Second
+
+// EventTypes maps hook stages to the event types published on a bus.
+var EventTypes = map[string]string{
+	HookPreEncode:  "job.encode-started",
+	HookPostEncode: "job.encoded",
+	HookPreUpload:  "job.upload-started",
+	HookPostUpload: "job.published",
+	HookOnError:    "job.failed",
+}
+
+// EventHook publishes every stage of the job to an event bus. The event's
+// data is the same HookEvent exec hooks receive, and its subject is the
+// video ID. Events only report on the job, so a failed publish is logged and
+// never fails it.
+type EventHook struct {
+	Publisher events.Publisher
+	Source    string
+	Logger    *slog.Logger
+}
+
+// eventType returns the event type of a hook stage, job.<stage> for stages
+// without one in EventTypes.
+func eventType(stage string) string {
+	if typ, ok := EventTypes[stage]; ok {
+		return typ
+	}
+	return "job." + stage
+}
+
+func (h EventHook) Run(event HookEvent) error {
+	source := h.Source
+	if source == "" {
+		source = events.DefaultSource
+	}
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	typ := eventType(event.Stage)
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := h.Publisher.Publish(ctx, events.NewEvent(typ, source, event.VideoID, event)); err != nil {
+		logger.Error("Failed to publish event", "type", typ, "bus", h.Publisher.String(), "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/gastrader/go_ffmpeg/events"
+	"github.com/gastrader/go_ffmpeg/storage"
+)
+
+type failingPublisher struct {
+	published []string
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, event events.Event) error {
+	p.published = append(p.published, event.Type)
+	return errors.New("broker unavailable")
+}
+
+func (p *failingPublisher) String() string {
+	return "failing"
+}
+
+func TestEventHookFailureDoesNotFailJob(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	publisher := &failingPublisher{}
+	vp := NewVideoProcessor(logger)
+	vp.Fake = true
+	vp.OutputDir = t.TempDir()
+	vp.VideoID = "video"
+	vp.Storage = storage.NewMemory()
+	vp.Hooks = []Hook{EventHook{Publisher: publisher, Logger: logger}}
+
+	if err := vp.ProcessVideo(); err != nil {
+		t.Fatalf("ProcessVideo: %v", err)
+	}
+	if err := vp.UploadToS3(); err != nil {
+		t.Fatalf("UploadToS3: %v", err)
+	}
+	want := []string{EventTypes[HookPreEncode], EventTypes[HookPostEncode], EventTypes[HookPreUpload], EventTypes[HookPostUpload]}
+	for _, typ := range want {
+		if !slices.Contains(publisher.published, typ) {
+			t.Errorf("no %s event published, got %v", typ, publisher.published)
+		}
+	}
+}
+
+func TestEventTypeFallback(t *testing.T) {
+	if got := eventType(HookPostUpload); got != EventTypes[HookPostUpload] {
+		t.Errorf("eventType(%q) = %q, want %q", HookPostUpload, got, EventTypes[HookPostUpload])
+	}
+	if got, want := eventType("unknown"), "job.unknown"; got != want {
+		t.Errorf("eventType(%q) = %q, want %q", "unknown", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+const DefaultContainerImage = "jrottenberg/ffmpeg:6.1-ubuntu"
+
+func (vp *VideoProcessor) command(binary string, args ...string) *exec.Cmd {
+	if vp.ContainerRuntime == "" {
+		return exec.Command(binary, args...)
+	}
+	return vp.containerCommand(binary, nil, args...)
+}
+
+func (vp *VideoProcessor) containerCommand(binary string, runtimeArgs []string, args ...string) *exec.Cmd {
+	runArgs := append([]string{"run", "--rm", "-i", "--entrypoint", binary}, runtimeArgs...)
+	if runtime.GOOS != "windows" {
+		runArgs = append(runArgs, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	for _, dir := range vp.containerMounts() {
+		runArgs = append(runArgs, "-v", dir+":"+dir)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		runArgs = append(runArgs, "-w", wd)
+	}
+	runArgs = append(runArgs, vp.ContainerImage)
+	return exec.Command(vp.ContainerRuntime, append(runArgs, args...)...)
+}
+
+func (vp *VideoProcessor) containerMounts() []string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	if vp.InputFile != "" {
+		dirs = append(dirs, filepath.Dir(vp.InputFile))
+	}
+	dirs = append(dirs, vp.OutputDir, vp.workDir)
+
+	seen := map[string]bool{}
+	var mounts []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		mounts = append(mounts, abs)
+	}
+	return mounts
+}
+
+func (vp *VideoProcessor) RequiredTools() []string {
+	if vp.ContainerRuntime != "" {
+		return []string{vp.ContainerRuntime}
+	}
+	tools := []string{vp.FFmpegPath, vp.FFprobePath}
+	if vp.CPULimit > 0 || vp.MemoryLimit > 0 {
+		tools = append(tools, "systemd-run", "systemctl")
+	}
+	if vp.Nice > 0 && runtime.GOOS != "windows" {
+		tools = append(tools, "nice")
+	}
+	if vp.IOClass != "" && runtime.GOOS == "linux" {
+		tools = append(tools, "ionice")
+	}
+	return tools
+}
@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const fakeSegments = 3
+
+func (vp *VideoProcessor) writeFakeRenditions() error {
+	vp.Logger.Info("Fake mode: writing synthetic segments instead of running ffmpeg")
+
+	packet := append([]byte{0x47, 0x1F, 0xFF, 0x10}, bytes.Repeat([]byte{0xFF}, 184)...)
+	segment := bytes.Repeat(packet, 10)
+
+	for _, rendition := range vp.Config.Renditions {
+		outputName := rendition.Name
+		version := 3
+		switch {
+		case vp.fragmentedMP4():
+			version = 7
+		case vp.Config.SingleFile:
+			version = 4
+		}
+		var playlist bytes.Buffer
+		playlist.WriteString("#EXTM3U\n")
+		playlist.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", version))
+		playlist.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", vp.Config.SegmentTime))
+		playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+		playlist.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+		if vp.keyInfo != "" {
+			playlist.WriteString(vp.keyTag() + "\n")
+		}
+		if rendition.WebM() {
+			if err := vp.writeFakeWebM(outputName, &playlist, segment); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if vp.fragmentedMP4() {
+			header := vp.initSegmentName(outputName)
+			if err := os.WriteFile(filepath.Join(vp.workingDir(), header), []byte{0x00, 0x00, 0x00, 0x08, 'f', 't', 'y', 'p'}, 0644); err != nil {
+				return err
+			}
+			playlist.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", header))
+		}
+		if vp.Config.SingleFile {
+			name := outputName + vp.segmentExtension()
+			if err := os.WriteFile(filepath.Join(vp.workingDir(), name), bytes.Repeat(segment, fakeSegments), 0644); err != nil {
+				return err
+			}
+			for i := 0; i < fakeSegments; i++ {
+				playlist.WriteString(fmt.Sprintf("#EXTINF:%d.000000,\n#EXT-X-BYTERANGE:%d@%d\n%s\n", vp.Config.SegmentTime, len(segment), i*len(segment), name))
+			}
+		}
+		for i := 0; i < fakeSegments && !vp.Config.SingleFile; i++ {
+			name := vp.segmentName(outputName, i, vp.segmentExtension())
+			if err := os.WriteFile(filepath.Join(vp.workingDir(), name), segment, 0644); err != nil {
+				return err
+			}
+			playlist.WriteString(fmt.Sprintf("#EXTINF:%d.000000,\n%s\n", vp.Config.SegmentTime, name))
+		}
+		playlist.WriteString("#EXT-X-ENDLIST\n")
+
+		path := filepath.Join(vp.workingDir(), fmt.Sprintf("%s.m3u8", outputName))
+		if err := os.WriteFile(path, playlist.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) writeFakeWebM(outputName string, playlist *bytes.Buffer, segment []byte) error {
+	header := outputName + "_init.webm"
+	if err := os.WriteFile(filepath.Join(vp.workingDir(), header), []byte{0x1A, 0x45, 0xDF, 0xA3}, 0644); err != nil {
+		return err
+	}
+	playlist.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", header))
+	for i := 0; i < fakeSegments; i++ {
+		name := vp.segmentName(outputName, i, ".chk")
+		if err := os.WriteFile(filepath.Join(vp.workingDir(), name), segment, 0644); err != nil {
+			return err
+		}
+		playlist.WriteString(fmt.Sprintf("#EXTINF:%d.000000,\n%s\n", vp.Config.SegmentTime, name))
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+	return os.WriteFile(filepath.Join(vp.workingDir(), outputName+".m3u8"), playlist.Bytes(), 0644)
+}
@@ -0,0 +1,27 @@
+package ffmpeg
+
+import "github.com/gastrader/go_ffmpeg/types"
+
+func (vp *VideoProcessor) rendition(outputName string) types.Rendition {
+	for _, rendition := range vp.Config.Renditions {
+		if rendition.Name == outputName {
+			return rendition
+		}
+	}
+	return types.Rendition{}
+}
+
+func (vp *VideoProcessor) hasFilters(outputName string) bool {
+	rendition := vp.rendition(outputName)
+	return len(vp.extraArgs(outputName).ExtraFilterArgs) > 0 || len(vp.Config.PostFilters) > 0 ||
+		len(rendition.PreFilters) > 0 || len(rendition.PostFilters) > 0
+}
+
+func (vp *VideoProcessor) videoFilters(rendition types.Rendition, extra types.ExtraArgs, scaleFilters []string, height int, fps float64) []string {
+	filters := vp.frameRateFilters(append(vp.imageFilters(), extra.ExtraFilterArgs...))
+	filters = append(filters, rendition.PreFilters...)
+	filters = append(filters, scaleFilters...)
+	filters = append(filters, rendition.PostFilters...)
+	filters = append(filters, vp.Config.PostFilters...)
+	return append(filters, vp.burninFilters(height, fps)...)
+}
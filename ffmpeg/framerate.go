@@ -0,0 +1,88 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	VFRConform = "conform"
+	VFRKeep    = "keep"
+
+	vfrTolerance  = 0.01
+	snapTolerance = 0.02
+)
+
+var commonFrameRates = []struct {
+	rational string
+	value    float64
+}{
+	{"24000/1001", 24000.0 / 1001}, {"24", 24}, {"25", 25},
+	{"30000/1001", 30000.0 / 1001}, {"30", 30}, {"48", 48}, {"50", 50},
+	{"60000/1001", 60000.0 / 1001}, {"60", 60},
+}
+
+func snapFrameRate(fps float64) (string, float64) {
+	for _, rate := range commonFrameRates {
+		if math.Abs(fps-rate.value)/rate.value <= snapTolerance {
+			return rate.rational, rate.value
+		}
+	}
+	rounded := math.Max(1, math.Round(fps))
+	return strconv.Itoa(int(rounded)), rounded
+}
+
+func (vp *VideoProcessor) detectFrameRate(avgFrameRate string) (float64, error) {
+	vp.frameRateFilter = ""
+	vp.variableFrameRate = false
+
+	stream := vp.sourceVideo()
+	fps, err := utils.ParseFrameRate(avgFrameRate)
+	if err != nil && stream != nil {
+		fps, err = utils.ParseFrameRate(stream.AvgFrameRate)
+	}
+	if err != nil {
+		return 0, types.NewExitError(types.ExitProbe, fmt.Errorf("failed to read source frame rate: %w", err))
+	}
+	if stream == nil {
+		return fps, nil
+	}
+	rFrameRate, err := utils.ParseFrameRate(stream.RFrameRate)
+	if err != nil || math.Abs(rFrameRate-fps)/fps <= vfrTolerance {
+		return fps, nil
+	}
+
+	switch vp.Config.VFRPolicy {
+	case "", VFRConform:
+		rational, value := snapFrameRate(fps)
+		vp.frameRateFilter = "fps=" + rational
+		vp.Logger.Info("Conforming variable frame rate source to constant frame rate",
+			"avgFrameRate", fmt.Sprintf("%.3f", fps), "rFrameRate", stream.RFrameRate, "frameRate", rational)
+		return value, nil
+	case VFRKeep:
+		vp.variableFrameRate = true
+		vp.Logger.Info("Keeping variable frame rate, placing keyframes by time",
+			"avgFrameRate", fmt.Sprintf("%.3f", fps), "rFrameRate", stream.RFrameRate)
+		return fps, nil
+	}
+	return 0, fmt.Errorf("invalid VFR policy %q (expected %s or %s)", vp.Config.VFRPolicy, VFRConform, VFRKeep)
+}
+
+func (vp *VideoProcessor) frameRateFilters(filters []string) []string {
+	if vp.frameRateFilter == "" {
+		return filters
+	}
+	return append([]string{vp.frameRateFilter}, filters...)
+}
+
+func (vp *VideoProcessor) keyframeArgs(fps float64) []string {
+	if vp.variableFrameRate {
+		return []string{"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", vp.Config.SegmentTime), "-sc_threshold", "0", "-vsync", "vfr"}
+	}
+	gop := strconv.Itoa(max(1, int(math.Round(fps*float64(vp.Config.SegmentTime)))))
+	return []string{"-g", gop, "-keyint_min", gop, "-sc_threshold", "0"}
+}
@@ -0,0 +1,134 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	HDRStrip    = "strip"
+	HDRPreserve = "preserve"
+	HDRFail     = "fail"
+)
+
+type dynamicHDR struct {
+	dolbyVision bool
+	dvProfile   int
+	dvCompat    int
+	hdr10Plus   bool
+}
+
+func (h dynamicHDR) String() string {
+	var kinds []string
+	if h.dolbyVision {
+		kinds = append(kinds, fmt.Sprintf("Dolby Vision profile %d.%d", h.dvProfile, h.dvCompat))
+	}
+	if h.hdr10Plus {
+		kinds = append(kinds, "HDR10+")
+	}
+	return strings.Join(kinds, " and ")
+}
+
+func (h dynamicHDR) crossCompatible() bool {
+	return h.dvProfile == 8 && (h.dvCompat == 1 || h.dvCompat == 2 || h.dvCompat == 4)
+}
+
+func (vp *VideoProcessor) detectDynamicHDR(stream *types.ProbeStream) (dynamicHDR, error) {
+	var hdr dynamicHDR
+	for _, sideData := range stream.SideDataList {
+		if strings.HasPrefix(sideData.SideDataType, "DOVI configuration") {
+			hdr.dolbyVision = true
+			hdr.dvProfile = sideData.DVProfile
+			hdr.dvCompat = sideData.DVBLSignalCompatibilityID
+		}
+	}
+
+	cmd := vp.command(vp.FFprobePath, "-v", "error", "-select_streams", vp.videoSpecifier(), "-read_intervals", "%+#1",
+		"-show_entries", "frame=side_data_list", "-of", "json", vp.sourceFile())
+	vp.Logger.Debug("Running ffprobe", "command", cmd.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return hdr, fmt.Errorf("failed to read frame side data: %w", err)
+	}
+	var frames struct {
+		Frames []struct {
+			SideDataList []types.ProbeSideData `json:"side_data_list"`
+		} `json:"frames"`
+	}
+	if err := json.Unmarshal(output, &frames); err != nil {
+		return hdr, fmt.Errorf("failed to parse frame side data: %w", err)
+	}
+	for _, frame := range frames.Frames {
+		for _, sideData := range frame.SideDataList {
+			if strings.Contains(sideData.SideDataType, "SMPTE2094-40") || strings.Contains(sideData.SideDataType, "HDR10+") {
+				hdr.hdr10Plus = true
+			}
+		}
+	}
+	return hdr, nil
+}
+
+func (vp *VideoProcessor) applyHDRPolicy(copyVideo bool) error {
+	stream := vp.sourceVideo()
+	if stream == nil {
+		return nil
+	}
+	hdr, err := vp.detectDynamicHDR(stream)
+	if err != nil {
+		vp.Logger.Error("Failed to detect dynamic HDR metadata", "error", err)
+		return types.NewExitError(types.ExitProbe, err)
+	}
+	vp.hdr = hdr
+	if !hdr.dolbyVision && !hdr.hdr10Plus {
+		return nil
+	}
+
+	switch vp.Config.HDRPolicy {
+	case HDRFail:
+		vp.Logger.Error("Source has dynamic HDR metadata", "metadata", hdr.String())
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("source has %s metadata and --hdr-policy is fail", hdr))
+	case HDRPreserve:
+		if hdr.dolbyVision && !hdr.crossCompatible() {
+			return types.NewExitError(types.ExitValidation,
+				fmt.Errorf("%s has no cross-compatible base layer and can't be preserved; only profile 8.1, 8.2 and 8.4 can", hdr))
+		}
+		if !copyVideo {
+			vp.Logger.Warn("Dynamic HDR metadata is only preserved when packaging; re-encoded rungs lose it", "metadata", hdr.String())
+		}
+	case "", HDRStrip:
+		if copyVideo && hdr.dolbyVision && hdr.dvProfile == 5 {
+			return types.NewExitError(types.ExitValidation,
+				fmt.Errorf("stripping %s would leave an IPT base layer with wrong colors; transcode instead", hdr))
+		}
+		vp.Logger.Info("Stripping dynamic HDR metadata", "metadata", hdr.String())
+	default:
+		return types.NewExitError(types.ExitValidation,
+			fmt.Errorf("unknown HDR policy %q (expected %s, %s or %s)", vp.Config.HDRPolicy, HDRStrip, HDRPreserve, HDRFail))
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) hdrCopyArgs() []string {
+	if !vp.hdr.dolbyVision && !vp.hdr.hdr10Plus {
+		return nil
+	}
+	if vp.Config.HDRPolicy == HDRPreserve {
+		if vp.hdr.dolbyVision {
+			vp.fmp4 = true
+			return []string{"-tag:v", "dvh1", "-strict", "unofficial"}
+		}
+		return nil
+	}
+
+	var filters []string
+	if vp.hdr.dolbyVision {
+		filters = append(filters, "dovi_rpu=strip=1")
+	}
+	if vp.hdr.hdr10Plus {
+		filters = append(filters, "filter_units=remove_types=39")
+	}
+	return []string{"-bsf:v", strings.Join(filters, ",")}
+}
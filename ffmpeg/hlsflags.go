@@ -0,0 +1,42 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var DefaultHLSFlags = []string{"independent_segments"}
+
+var hlsFlagSupport = map[string]string{
+	"independent_segments": "",
+	"temp_file":            "",
+	"program_date_time":    "",
+	"append_list":          "",
+	"split_by_time":        "",
+	"round_durations":      "",
+	"discont_start":        "",
+	"delete_segments":      "it only applies to live playlists with a sliding window, and this tool writes VOD playlists",
+	"omit_endlist":         "VOD playlists need #EXT-X-ENDLIST, and resuming relies on it",
+	"single_file":          "use --single-file instead",
+}
+
+func ValidateHLSFlags(flags []string) error {
+	for _, flag := range flags {
+		reason, ok := hlsFlagSupport[flag]
+		if !ok {
+			known := make([]string, 0, len(hlsFlagSupport))
+			for name, reason := range hlsFlagSupport {
+				if reason == "" {
+					known = append(known, name)
+				}
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown hls flag %q (expected one of %s)", flag, strings.Join(known, ", "))
+		}
+		if reason != "" {
+			return fmt.Errorf("hls flag %s is not supported: %s", flag, reason)
+		}
+	}
+	return nil
+}
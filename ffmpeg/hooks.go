@@ -0,0 +1,124 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	HookPreEncode  = "pre-encode"
+	HookPostEncode = "post-encode"
+	HookPreUpload  = "pre-upload"
+	HookPostUpload = "post-upload"
+	HookOnError    = "on-error"
+)
+
+var HookStages = []string{HookPreEncode, HookPostEncode, HookPreUpload, HookPostUpload, HookOnError}
+
+type HookEvent struct {
+	Stage     string           `json:"stage"`
+	VideoID   string           `json:"videoId"`
+	InputFile string           `json:"inputFile"`
+	OutputDir string           `json:"outputDir"`
+	S3Bucket  string           `json:"bucket,omitempty"`
+	KeyPrefix string           `json:"keyPrefix,omitempty"`
+	Failed    string           `json:"failedStage,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Report    *types.JobReport `json:"report,omitempty"`
+}
+
+type Hook interface {
+	Run(event HookEvent) error
+}
+
+type HookFunc func(event HookEvent) error
+
+func (f HookFunc) Run(event HookEvent) error {
+	return f(event)
+}
+
+type ExecHook struct {
+	Stage   string
+	Command string
+}
+
+func ParseExecHook(spec string) (ExecHook, error) {
+	stage, command, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(command) == "" {
+		return ExecHook{}, fmt.Errorf("invalid hook %q (expected stage=command)", spec)
+	}
+	for _, known := range HookStages {
+		if stage == known {
+			return ExecHook{Stage: stage, Command: command}, nil
+		}
+	}
+	return ExecHook{}, fmt.Errorf("unknown hook stage %q (expected %s)", stage, strings.Join(HookStages, ", "))
+}
+
+func (h ExecHook) Run(event HookEvent) error {
+	if event.Stage != h.Stage {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", h.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", h.Command)
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "VIDPROC_HOOK_STAGE="+event.Stage)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %q failed: %w", h.Command, err)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) runHooks(stage string, failed string, cause error) error {
+	if len(vp.Hooks) == 0 {
+		return nil
+	}
+	event := HookEvent{
+		Stage:     stage,
+		VideoID:   vp.videoID(),
+		InputFile: vp.InputFile,
+		OutputDir: vp.OutputDir,
+		S3Bucket:  vp.S3Bucket,
+		KeyPrefix: vp.KeyPrefix,
+		Failed:    failed,
+		Report:    vp.Report,
+	}
+	if cause != nil {
+		event.Error = cause.Error()
+	}
+	for _, hook := range vp.Hooks {
+		vp.Logger.Debug("Running hook", "stage", stage)
+		if err := hook.Run(event); err != nil {
+			vp.Logger.Error("Hook failed", "stage", stage, "error", err)
+			return fmt.Errorf("%s hook failed: %w", stage, err)
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) runErrorHooks(failed string, err *error) {
+	if *err == nil {
+		return
+	}
+	if hookErr := vp.runHooks(HookOnError, failed, *err); hookErr != nil {
+		vp.Logger.Error("Error hook failed", "error", hookErr)
+	}
+}
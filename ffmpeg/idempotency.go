@@ -0,0 +1,113 @@
+package ffmpeg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+var ErrDuplicateJob = errors.New("job was already processed")
+
+func (vp *VideoProcessor) deduplicating() bool {
+	return vp.SkipDuplicates || vp.IdempotencyKey != ""
+}
+
+func (vp *VideoProcessor) jobInputs() []string {
+	inputs := vp.ConcatInputs
+	if len(inputs) == 0 {
+		inputs = []string{vp.InputFile}
+	}
+	var files []string
+	for _, input := range inputs {
+		if IsImageSequence(input) {
+			files = append(files, SequenceFrames(input)...)
+		} else {
+			files = append(files, input)
+		}
+	}
+	if vp.Config.ComposeWith != "" {
+		files = append(files, vp.Config.ComposeWith)
+	}
+	return files
+}
+
+func (vp *VideoProcessor) jobKey() (string, error) {
+	if vp.IdempotencyKey != "" {
+		return vp.IdempotencyKey, nil
+	}
+	hash := sha256.New()
+	for _, input := range vp.jobInputs() {
+		if err := hashFile(hash, input); err != nil {
+			vp.Logger.Error("Failed to hash input", "path", input, "error", err)
+			return "", fmt.Errorf("failed to hash input %s: %w", input, err)
+		}
+	}
+	settings, err := json.Marshal(struct {
+		Config      types.VideoProcessingConfig
+		PackageOnly bool
+		Sample      time.Duration
+		SampleStart string
+	}{vp.Config, vp.PackageOnly, vp.Sample, vp.SampleStart})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode settings: %w", err)
+	}
+	hash.Write(settings)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(hash io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(hash, file)
+	return err
+}
+
+func (vp *VideoProcessor) CheckDuplicate() error {
+	if !vp.deduplicating() || vp.Storage == nil {
+		return nil
+	}
+	key, err := vp.jobKey()
+	if err != nil {
+		return err
+	}
+	vp.Report.IdempotencyKey = key
+
+	manifestKey, err := vp.objectKey(filepath.Join(vp.OutputDir, UploadManifestName))
+	if err != nil {
+		return err
+	}
+	body, err := vp.Storage.Get(context.Background(), manifestKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		vp.Logger.Error("Failed to read upload manifest", "key", manifestKey, "error", err)
+		return fmt.Errorf("failed to read upload manifest %s: %w", manifestKey, err)
+	}
+	defer body.Close()
+
+	var manifest types.UploadManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		vp.Logger.Warn("Ignoring unreadable upload manifest", "key", manifestKey, "error", err)
+		return nil
+	}
+	if manifest.IdempotencyKey != key {
+		return nil
+	}
+	vp.Report.Duplicate = true
+	vp.Logger.Info("Skipping duplicate job", "idempotencyKey", key, "manifest", manifestKey, "uploadedAt", manifest.UploadedAt)
+	return ErrDuplicateJob
+}
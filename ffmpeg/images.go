@@ -0,0 +1,112 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	DefaultStillDuration  = 10 * time.Second
+	DefaultImageFrameRate = "25"
+
+	kenBurnsZoom = 1.2
+)
+
+var (
+	sequencePattern = regexp.MustCompile(`%0?\d*d`)
+	stillExtensions = map[string]bool{
+		".png":  true,
+		".jpg":  true,
+		".jpeg": true,
+		".webp": true,
+		".bmp":  true,
+		".tif":  true,
+		".tiff": true,
+	}
+)
+
+func IsImageSequence(input string) bool {
+	return sequencePattern.MatchString(filepath.Base(input))
+}
+
+func SequenceFrames(pattern string) []string {
+	dir, base := filepath.Split(pattern)
+	frames, _ := filepath.Glob(filepath.Join(dir, sequencePattern.ReplaceAllString(base, "*")))
+	return frames
+}
+
+func (vp *VideoProcessor) statInput() (os.FileInfo, error) {
+	if !IsImageSequence(vp.InputFile) {
+		return os.Stat(vp.InputFile)
+	}
+	frames := SequenceFrames(vp.InputFile)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames match %s", vp.InputFile)
+	}
+	return os.Stat(frames[0])
+}
+
+func isStillImage(input string) bool {
+	return !IsImageSequence(input) && stillExtensions[strings.ToLower(filepath.Ext(input))]
+}
+
+func (vp *VideoProcessor) imageInput() bool {
+	return IsImageSequence(vp.InputFile) || isStillImage(vp.InputFile)
+}
+
+func (vp *VideoProcessor) imageFrameRate() string {
+	if vp.Config.ImageFrameRate != "" {
+		return vp.Config.ImageFrameRate
+	}
+	return DefaultImageFrameRate
+}
+
+func (vp *VideoProcessor) stillSeconds() float64 {
+	if vp.Config.StillDuration > 0 {
+		return vp.Config.StillDuration.Seconds()
+	}
+	return DefaultStillDuration.Seconds()
+}
+
+func (vp *VideoProcessor) probeInputArgs() []string {
+	if !vp.imageInput() {
+		return nil
+	}
+	return []string{"-framerate", vp.imageFrameRate()}
+}
+
+func (vp *VideoProcessor) inputArgs() []string {
+	switch {
+	case IsImageSequence(vp.InputFile):
+		return []string{"-framerate", vp.imageFrameRate(), "-i", vp.sourceFile()}
+	case !isStillImage(vp.InputFile):
+		return []string{"-i", vp.sourceFile()}
+	case vp.Config.KenBurns:
+		return []string{"-framerate", vp.imageFrameRate(), "-i", vp.sourceFile()}
+	}
+	args := []string{"-loop", "1", "-framerate", vp.imageFrameRate()}
+	if vp.Sample <= 0 {
+		args = append(args, "-t", strconv.FormatFloat(vp.stillSeconds(), 'f', 3, 64))
+	}
+	return append(args, "-i", vp.sourceFile())
+}
+
+func (vp *VideoProcessor) imageFilters() []string {
+	stream := vp.sourceVideo()
+	if !vp.Config.KenBurns || !isStillImage(vp.InputFile) || stream == nil {
+		return nil
+	}
+	fps, _ := utils.ParseFrameRate(vp.imageFrameRate())
+	frames := max(1, int(math.Round(vp.stillSeconds()*fps)))
+	width, height := displaySize(stream)
+	return []string{fmt.Sprintf("zoompan=z='min(zoom+%.6f,%g)':x='iw/2-(iw/zoom/2)':y='ih/2-(ih/zoom/2)':d=%d:s=%dx%d:fps=%s",
+		(kenBurnsZoom-1)/float64(frames), kenBurnsZoom, frames, width&^1, height&^1, vp.imageFrameRate())}
+}
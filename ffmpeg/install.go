@@ -0,0 +1,202 @@
+package ffmpeg
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	ManagedFFmpegVersion = "6.0"
+	ManagedFFmpegURL     = "https://github.com/eugeneware/ffmpeg-static/releases/download/b6.0/"
+)
+
+var managedPlatforms = map[string]string{
+	"linux/amd64":   "linux-x64",
+	"linux/arm64":   "linux-arm64",
+	"darwin/amd64":  "darwin-x64",
+	"darwin/arm64":  "darwin-arm64",
+	"windows/amd64": "win32-x64",
+}
+
+// managedChecksums pins the SHA-256 of each gzipped ffmpeg and ffprobe
+// asset of the ManagedFFmpegURL release, by platform. A platform without an
+// entry can only be installed with the digests given explicitly.
+var managedChecksums = map[string]map[string]string{}
+
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+func DefaultManagedToolsDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "video-processor", "ffmpeg-"+ManagedFFmpegVersion)
+}
+
+func managedToolPath(dir, tool string) string {
+	if runtime.GOOS == "windows" {
+		tool += ".exe"
+	}
+	return filepath.Join(dir, tool)
+}
+
+func ManagedTools(dir string) (ffmpegPath, ffprobePath string, ok bool) {
+	if dir == "" {
+		return "", "", false
+	}
+	ffmpegPath, ffprobePath = managedToolPath(dir, "ffmpeg"), managedToolPath(dir, "ffprobe")
+	for _, path := range []string{ffmpegPath, ffprobePath} {
+		if _, err := os.Stat(path); err != nil {
+			return "", "", false
+		}
+	}
+	return ffmpegPath, ffprobePath, true
+}
+
+func (vp *VideoProcessor) UseManagedTools() {
+	if vp.ContainerRuntime != "" || vp.FFmpegPath != "ffmpeg" || vp.FFprobePath != "ffprobe" {
+		return
+	}
+	if _, err := exec.LookPath("ffmpeg"); err == nil {
+		return
+	}
+	if ffmpegPath, ffprobePath, ok := ManagedTools(vp.ManagedToolsDir); ok {
+		vp.Logger.Debug("Using managed ffmpeg", "dir", vp.ManagedToolsDir)
+		vp.FFmpegPath, vp.FFprobePath = ffmpegPath, ffprobePath
+	}
+}
+
+// downloadTool downloads a gzipped binary to path. The SHA-256 of the
+// download must match checksum before the binary is made executable.
+func downloadTool(url, checksum, path string) error {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	hash := sha256.New()
+	body := io.TeeReader(resp.Body, hash)
+	reader, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", url, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("checksum mismatch for %s: got sha256 %s, want %s", url, sum, checksum)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// toolChecksums returns the expected SHA-256 of each tool's download. Given
+// digests take precedence; the pinned ones only vouch for the pinned release,
+// so any other source needs both given.
+func toolChecksums(baseURL, platform string, given map[string]string) (map[string]string, error) {
+	checksums := map[string]string{}
+	for tool, sum := range given {
+		if tool != "ffmpeg" && tool != "ffprobe" {
+			return nil, fmt.Errorf("unknown tool %q in checksums (expected ffmpeg or ffprobe)", tool)
+		}
+		if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 %q for %s", sum, tool)
+		}
+		checksums[tool] = sum
+	}
+	pinned := managedChecksums[platform]
+	if strings.TrimSuffix(baseURL, "/") != strings.TrimSuffix(ManagedFFmpegURL, "/") {
+		pinned = nil
+	}
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if checksums[tool] != "" {
+			continue
+		}
+		if pinned[tool] == "" {
+			return nil, fmt.Errorf("no pinned checksum for %s from %s on %s; pass --sha256 %s=<sha256 of the .gz>", tool, baseURL, platform, tool)
+		}
+		checksums[tool] = pinned[tool]
+	}
+	return checksums, nil
+}
+
+// InstallFFmpeg downloads the static build from baseURL. checksums maps
+// ffmpeg and ffprobe to the SHA-256 of their .gz downloads and may be nil for
+// the pinned release.
+func (vp *VideoProcessor) InstallFFmpeg(baseURL string, checksums map[string]string, force bool) error {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	asset, ok := managedPlatforms[platform]
+	if !ok {
+		supported := make([]string, 0, len(managedPlatforms))
+		for name := range managedPlatforms {
+			supported = append(supported, name)
+		}
+		slices.Sort(supported)
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("no static ffmpeg build for %s (available for %s)", platform, strings.Join(supported, ", ")))
+	}
+	if vp.ManagedToolsDir == "" {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("no directory to install ffmpeg into; pass --ffmpeg-dir"))
+	}
+	checksums, err := toolChecksums(baseURL, platform, checksums)
+	if err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if ffmpegPath, ffprobePath, ok := ManagedTools(vp.ManagedToolsDir); ok && !force {
+		vp.Logger.Info("Managed ffmpeg is already installed", "dir", vp.ManagedToolsDir)
+		vp.FFmpegPath, vp.FFprobePath = ffmpegPath, ffprobePath
+		return vp.CheckToolVersions()
+	}
+	if err := os.MkdirAll(vp.ManagedToolsDir, 0755); err != nil {
+		vp.Logger.Error("Failed to create ffmpeg directory", "dir", vp.ManagedToolsDir, "error", err)
+		return fmt.Errorf("failed to create ffmpeg directory: %w", err)
+	}
+
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		url := strings.TrimSuffix(baseURL, "/") + "/" + tool + "-" + asset + ".gz"
+		path := managedToolPath(vp.ManagedToolsDir, tool)
+		vp.Logger.Info("Downloading static build", "tool", tool, "version", ManagedFFmpegVersion, "url", url)
+		if err := downloadTool(url, checksums[tool], path); err != nil {
+			vp.Logger.Error("Failed to download static build", "tool", tool, "url", url, "error", err)
+			return types.NewExitError(types.ExitMissingTools, fmt.Errorf("failed to download %s: %w", tool, err))
+		}
+	}
+
+	vp.FFmpegPath = managedToolPath(vp.ManagedToolsDir, "ffmpeg")
+	vp.FFprobePath = managedToolPath(vp.ManagedToolsDir, "ffprobe")
+	if err := vp.CheckToolVersions(); err != nil {
+		return err
+	}
+	vp.Logger.Info("Installed managed ffmpeg", "dir", vp.ManagedToolsDir)
+	return nil
+}
@@ -0,0 +1,106 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+type Job struct {
+	InputFile    string
+	ConcatInputs []string
+	OutputDir    string
+	S3Bucket     string
+	KeyPrefix    string
+	VideoID      string
+	Resume       bool
+	Storage      storage.Storage
+
+	IdempotencyKey string
+}
+
+// forJob returns a copy of vp for one job. Settings are shared with vp, and
+// the pause and cancel controls too, but the configuration, report and job
+// state are the job's own.
+func (vp *VideoProcessor) forJob(job Job) *VideoProcessor {
+	// Only the tool versions, checked once up front, carry over.
+	report := types.JobReport{
+		InputFile:     job.InputFile,
+		OutputDir:     job.OutputDir,
+		StartedAt:     time.Now().UTC(),
+		FFmpegPath:    vp.Report.FFmpegPath,
+		FFmpegVersion: vp.Report.FFmpegVersion,
+		FFmpegConfig:  vp.Report.FFmpegConfig,
+		FFprobePath:   vp.Report.FFprobePath,
+		FFprobeVer:    vp.Report.FFprobeVer,
+	}
+
+	j := *vp
+	j.Logger = vp.Logger.With("input", job.InputFile)
+	j.Storage = job.Storage
+	j.InputFile = job.InputFile
+	j.ConcatInputs = job.ConcatInputs
+	j.OutputDir = job.OutputDir
+	j.S3Bucket = job.S3Bucket
+	j.KeyPrefix = job.KeyPrefix
+	j.VideoID = job.VideoID
+	j.Resume = job.Resume
+	j.IdempotencyKey = job.IdempotencyKey
+	j.Config = vp.Config.Clone()
+	j.Report = &report
+	j.jobState = &jobState{}
+	return &j
+}
+
+func (vp *VideoProcessor) Run(job Job) (*types.JobReport, error) {
+	if job.OutputDir == "" {
+		return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("job for %s has no output directory", job.InputFile))
+	}
+	if job.Storage == nil && job.S3Bucket != "" && vp.Storage != nil && vp.S3Bucket == job.S3Bucket {
+		job.Storage = vp.Storage
+	}
+
+	j := vp.forJob(job)
+	notified := j.StartNotifications()
+	err := j.runJob()
+	j.Report.FinishedAt = time.Now().UTC()
+	if err != nil {
+		j.Report.Error = err.Error()
+	}
+	notified(err)
+	return j.Report, err
+}
+
+func (vp *VideoProcessor) runJob() error {
+	if duplicate, err := vp.encodeJob(); duplicate || err != nil {
+		return err
+	}
+	return vp.uploadJob()
+}
+
+func (vp *VideoProcessor) encodeJob() (bool, error) {
+	if err := vp.CheckDuplicate(); errors.Is(err, ErrDuplicateJob) {
+		return true, nil
+	} else if err != nil {
+		return false, types.NewExitError(types.ExitUpload, err)
+	}
+	if err := vp.ProcessVideo(); err != nil {
+		vp.Logger.Error("Error processing video", "error", err)
+		return false, types.NewExitError(types.ExitEncode, fmt.Errorf("error processing video: %w", err))
+	}
+	return false, nil
+}
+
+func (vp *VideoProcessor) uploadJob() error {
+	if vp.Storage == nil && vp.S3Bucket == "" {
+		return vp.PruneOutputs()
+	}
+	if err := vp.UploadToS3(); err != nil {
+		vp.Logger.Error("Error uploading", "error", err)
+		return types.NewExitError(types.ExitUpload, fmt.Errorf("error uploading to S3: %w", err))
+	}
+	return vp.PruneOutputs()
+}
@@ -0,0 +1,19 @@
+package ffmpeg
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func storageKey(name string) string {
+	key := path.Clean(filepath.ToSlash(strings.TrimPrefix(name, filepath.VolumeName(name))))
+	for strings.HasPrefix(key, "../") {
+		key = strings.TrimPrefix(key, "../")
+	}
+	key = strings.TrimPrefix(key, "/")
+	if key == "." || key == ".." {
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,31 @@
+package ffmpeg
+
+import "testing"
+
+func TestStorageKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"720.m3u8", "720.m3u8"},
+		{"thumbnails/thumb_0001.jpg", "thumbnails/thumb_0001.jpg"},
+		{"./720_00000.ts", "720_00000.ts"},
+		{"/720.m3u8", "720.m3u8"},
+		{"//out/720.m3u8", "out/720.m3u8"},
+		{"/out/../720.m3u8", "720.m3u8"},
+		{"../720.m3u8", "720.m3u8"},
+		{"../../poster/poster.jpg", "poster/poster.jpg"},
+		{"poster/../../720.m3u8", "720.m3u8"},
+		{"/../720.m3u8", "720.m3u8"},
+		{".", ""},
+		{"..", ""},
+		{"../..", ""},
+		{"/", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := storageKey(tt.name); got != tt.want {
+			t.Errorf("storageKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
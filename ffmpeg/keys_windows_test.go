@@ -0,0 +1,26 @@
+package ffmpeg
+
+import "testing"
+
+func TestStorageKeyWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{`C:\out\720.m3u8`, "out/720.m3u8"},
+		{`c:\720.m3u8`, "720.m3u8"},
+		{`C:720.m3u8`, "720.m3u8"},
+		{`\\server\share\out\720.m3u8`, "out/720.m3u8"},
+		{`thumbnails\thumb_0001.jpg`, "thumbnails/thumb_0001.jpg"},
+		{`..\..\720.m3u8`, "720.m3u8"},
+		{`C:\..\720.m3u8`, "720.m3u8"},
+		{`\720.m3u8`, "720.m3u8"},
+		{`C:\`, ""},
+		{`\\server\share`, ""},
+	}
+	for _, tt := range tests {
+		if got := storageKey(tt.name); got != tt.want {
+			t.Errorf("storageKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+func rung(name string, width, height int, bitrate, audioRate, level string) types.Rendition {
+	return types.Rendition{Name: name, Width: width, Height: height, VideoBitrate: bitrate, AudioBitrate: audioRate, Level: level}
+}
+
+var (
+	rung2160 = rung("2160", 3840, 2160, "16800k", "160k", "5.1")
+	rung1440 = rung("1440", 2560, 1440, "10000k", "160k", "5.1")
+	rung1080 = rung("1080", 1920, 1080, "7800k", "128k", "4.2")
+	rung720  = rung("720", 1280, 720, "4500k", "128k", "3.1")
+	rung480  = rung("480", 854, 480, "2000k", "96k", "3.1")
+	rung360  = rung("360", 640, 360, "730k", "96k", "3.0")
+	rung240  = rung("240", 426, 240, "365k", "64k", "3.0")
+)
+
+var ladderPresets = map[string][]types.Rendition{
+	"default": {
+		rung("1080", 1920, 1080, "16000k", "128k", "4.2"),
+		rung("720", 1280, 720, "6000k", "96k", "3.1"),
+	},
+	"apple-hls": {rung2160, rung1440, rung1080, rung720, rung480, rung360, rung240},
+	"youtube": {
+		rung("2160", 3840, 2160, "35000k", "192k", "5.1"),
+		rung("1440", 2560, 1440, "16000k", "192k", "5.1"),
+		rung("1080", 1920, 1080, "8000k", "128k", "4.2"),
+		rung("720", 1280, 720, "5000k", "128k", "3.1"),
+		rung("480", 854, 480, "2500k", "96k", "3.1"),
+		rung("360", 640, 360, "1000k", "96k", "3.0"),
+		rung("240", 426, 240, "500k", "64k", "3.0"),
+	},
+	"mobile": {
+		rung("720", 1280, 720, "2500k", "96k", "3.1"),
+		rung("480", 854, 480, "1200k", "96k", "3.0"),
+		rung("360", 640, 360, "700k", "64k", "3.0"),
+		rung("240", 426, 240, "400k", "64k", "3.0"),
+	},
+	"4k": {
+		rung("2160", 3840, 2160, "16000k", "160k", "5.1"),
+		rung("1440", 2560, 1440, "9000k", "160k", "5.1"),
+		rung("1080", 1920, 1080, "6000k", "128k", "4.2"),
+		rung("720", 1280, 720, "3000k", "128k", "3.1"),
+	},
+}
+
+var (
+	Platforms     = []string{"web", "mobile", "tv"}
+	platformRungs = map[string][]types.Rendition{
+		"web":    {rung1080, rung720, rung480, rung360},
+		"mobile": {rung720, rung480, rung360, rung240},
+		"tv":     {rung2160, rung1440, rung1080, rung720},
+	}
+)
+
+func RecommendLadder(platforms []string, sourceHeight int, bitrateScale float64) ([]types.Rendition, error) {
+	seen := map[string]bool{}
+	var ladder []types.Rendition
+	for _, platform := range platforms {
+		rungs, ok := platformRungs[platform]
+		if !ok {
+			return nil, fmt.Errorf("unknown platform %q (expected %s)", platform, strings.Join(Platforms, ", "))
+		}
+		for _, rendition := range rungs {
+			if seen[rendition.Name] || sourceHeight > 0 && rendition.Height > sourceHeight {
+				continue
+			}
+			seen[rendition.Name] = true
+			rendition.VideoBitrate = fmt.Sprintf("%dk", int(float64(rendition.VideoKbps())*bitrateScale))
+			ladder = append(ladder, rendition)
+		}
+	}
+	if len(ladder) == 0 {
+		return nil, fmt.Errorf("no rungs for %s fit a %dp source", strings.Join(platforms, ", "), sourceHeight)
+	}
+	sort.SliceStable(ladder, func(i, j int) bool { return ladder[i].Height > ladder[j].Height })
+	return ladder, nil
+}
+
+func LadderPresetNames() []string {
+	names := make([]string, 0, len(ladderPresets))
+	for name := range ladderPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (vp *VideoProcessor) ApplyLadderPreset(name string) error {
+	rungs, ok := ladderPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown ladder preset %q (available: %s)", name, strings.Join(LadderPresetNames(), ", "))
+	}
+
+	vp.Config.Renditions = append([]types.Rendition(nil), rungs...)
+	return nil
+}
@@ -0,0 +1,117 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+)
+
+var languageTag = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*$`)
+
+// Source streams are usually tagged with ISO 639-2 codes, while HLS wants
+// RFC 5646 tags, which use the two-letter ISO 639-1 code where one exists.
+var iso639Languages = map[string]struct{ tag, name string }{
+	"ara": {"ar", "Arabic"},
+	"chi": {"zh", "Chinese"},
+	"zho": {"zh", "Chinese"},
+	"cze": {"cs", "Czech"},
+	"ces": {"cs", "Czech"},
+	"dan": {"da", "Danish"},
+	"dut": {"nl", "Dutch"},
+	"nld": {"nl", "Dutch"},
+	"eng": {"en", "English"},
+	"fin": {"fi", "Finnish"},
+	"fre": {"fr", "French"},
+	"fra": {"fr", "French"},
+	"ger": {"de", "German"},
+	"deu": {"de", "German"},
+	"gre": {"el", "Greek"},
+	"ell": {"el", "Greek"},
+	"heb": {"he", "Hebrew"},
+	"hin": {"hi", "Hindi"},
+	"hun": {"hu", "Hungarian"},
+	"ind": {"id", "Indonesian"},
+	"ita": {"it", "Italian"},
+	"jpn": {"ja", "Japanese"},
+	"kor": {"ko", "Korean"},
+	"nor": {"no", "Norwegian"},
+	"pol": {"pl", "Polish"},
+	"por": {"pt", "Portuguese"},
+	"rus": {"ru", "Russian"},
+	"spa": {"es", "Spanish"},
+	"swe": {"sv", "Swedish"},
+	"tha": {"th", "Thai"},
+	"tur": {"tr", "Turkish"},
+	"ukr": {"uk", "Ukrainian"},
+	"vie": {"vi", "Vietnamese"},
+}
+
+func ValidateLanguage(tag string) error {
+	if tag == "" || languageTag.MatchString(tag) {
+		return nil
+	}
+	return fmt.Errorf("invalid language tag %q (expected e.g. en, pt-BR or eng)", tag)
+}
+
+func normalizeLanguage(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || strings.EqualFold(tag, "und") || !languageTag.MatchString(tag) {
+		return ""
+	}
+	if language, ok := iso639Languages[strings.ToLower(tag)]; ok {
+		return language.tag
+	}
+	return tag
+}
+
+func languageName(tag string) string {
+	for _, language := range iso639Languages {
+		if strings.EqualFold(language.tag, tag) {
+			return language.name
+		}
+	}
+	return ""
+}
+
+// audioLanguage is the RFC 5646 tag of the audio renditions, taken from
+// --audio-language or else the source's audio stream.
+func (vp *VideoProcessor) audioLanguage() string {
+	if vp.Config.AudioLanguage != "" {
+		return normalizeLanguage(vp.Config.AudioLanguage)
+	}
+	if stream := audioStream(vp.source); stream != nil {
+		return normalizeLanguage(stream.Tags["language"])
+	}
+	return ""
+}
+
+func (vp *VideoProcessor) audioName(label string) string {
+	name := vp.Config.AudioName
+	if stream := audioStream(vp.source); name == "" && stream != nil {
+		name = strings.TrimSpace(stream.Tags["title"])
+	}
+	if name == "" {
+		name = languageName(vp.audioLanguage())
+	}
+	if name == "" {
+		return label
+	}
+	return strings.ReplaceAll(name, "\"", "'") + " (" + label + ")"
+}
+
+// audioRendition returns the audio EXT-X-MEDIA entry of a group. Every audio
+// group holds a single main entry, so it is always the default.
+func (vp *VideoProcessor) audioRendition(group, label, channels, uri string) hls.MediaRendition {
+	return hls.MediaRendition{
+		Type:       "AUDIO",
+		GroupID:    group,
+		Language:   vp.audioLanguage(),
+		Name:       vp.audioName(label),
+		Default:    true,
+		Autoselect: true,
+		Channels:   channels,
+		URI:        uri,
+	}
+}
@@ -0,0 +1,63 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type h264Level struct {
+	name    string
+	maxFS   int
+	maxMBPS int
+	maxBR   int
+	maxCPB  int
+}
+
+var h264Levels = []h264Level{
+	{"3.0", 1620, 40500, 10000, 10000},
+	{"3.1", 3600, 108000, 14000, 14000},
+	{"3.2", 5120, 216000, 20000, 20000},
+	{"4.0", 8192, 245760, 20000, 25000},
+	{"4.1", 8192, 245760, 50000, 62500},
+	{"4.2", 8704, 522240, 50000, 62500},
+	{"5.0", 22080, 589824, 135000, 135000},
+	{"5.1", 36864, 983040, 240000, 240000},
+	{"5.2", 36864, 2073600, 240000, 240000},
+	{"6.0", 139264, 4177920, 240000, 240000},
+	{"6.1", 139264, 8355840, 480000, 480000},
+	{"6.2", 139264, 16711680, 800000, 800000},
+}
+
+var h264ProfileFactors = map[string]float64{
+	"baseline": 1,
+	"main":     1,
+	"high":     1.25,
+	"high10":   3,
+	"high422":  4,
+	"high444":  4,
+}
+
+func minimumH264Level(width, height int, fps float64, maxrate, bufsize int, profile string) (string, error) {
+	factor, ok := h264ProfileFactors[profile]
+	if !ok {
+		factor = 1
+	}
+	frameSize := ((width + 15) / 16) * ((height + 15) / 16)
+	mbps := int(float64(frameSize) * fps)
+	for _, level := range h264Levels {
+		if frameSize <= level.maxFS && mbps <= level.maxMBPS &&
+			float64(maxrate) <= float64(level.maxBR)*factor && float64(bufsize) <= float64(level.maxCPB)*factor {
+			return level.name, nil
+		}
+	}
+	return "", fmt.Errorf("%dx%d at %g fps with maxrate %dk and bufsize %dk exceeds every H.264 level for the %s profile", width, height, fps, maxrate, bufsize, profile)
+}
+
+func maxLevel(a, b string) string {
+	av, _ := strconv.ParseFloat(a, 64)
+	bv, _ := strconv.ParseFloat(b, 64)
+	if bv > av {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,74 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+func threadArgs(threads int, args []string) []string {
+	if threads <= 0 || len(args) == 0 {
+		return args
+	}
+	n := strconv.Itoa(threads)
+	limited := append([]string{"-threads", n, "-filter_threads", n}, args[:len(args)-1]...)
+	return append(limited, "-threads", n, args[len(args)-1])
+}
+
+var scopes atomic.Int64
+
+func newScopeName() string {
+	return fmt.Sprintf("go-ffmpeg-%d-%d.scope", os.Getpid(), scopes.Add(1))
+}
+
+// oomKilled reports whether the encoder in scope was ended by the OOM
+// killer. A SIGKILL alone could also come from an operator or orchestrator,
+// so it is only trusted when the scope's memory.events counted an oom_kill.
+// systemd watches that counter and fails the unit with result oom-kill; the
+// cgroup itself is removed as soon as ffmpeg exits, so the unit result is
+// what's left to read. Encoders outside a scope are never treated as OOM.
+func oomKilled(err error, scope string) bool {
+	var exitErr *exec.ExitError
+	if scope == "" || !errors.As(err, &exitErr) {
+		return false
+	}
+	out, showErr := exec.Command("systemctl", "--user", "show", "--property=Result", "--value", scope).Output()
+	// The failed unit stays loaded until it is reset.
+	exec.Command("systemctl", "--user", "reset-failed", scope).Run()
+	return showErr == nil && strings.TrimSpace(string(out)) == "oom-kill"
+}
+
+func (vp *VideoProcessor) runRetryingEncoder(args []string, output string, threads int) error {
+	stalls := 0
+	for {
+		ffmpegCmd, scope := vp.limitedEncoderCommand(threads, args...)
+		vp.Logger.Debug("Running ffmpeg", "output", output, "command", ffmpegCmd.String())
+		err := vp.runEncoder(ffmpegCmd, output)
+		if errors.Is(err, ErrStalled) && stalls < vp.StallRetries {
+			stalls++
+			vp.Logger.Warn("Encoder stalled; retrying", "output", output, "attempt", stalls+1)
+			continue
+		}
+		if err == nil || !oomKilled(err, scope) {
+			return err
+		}
+		if vp.Config.Reproducible {
+			vp.Logger.Error("Encoder ran out of memory; not retrying with fewer threads, which would change the reproducible output", "output", output, "threads", threads)
+			return err
+		}
+		if threads == 0 {
+			threads = runtime.NumCPU()
+		}
+		if threads <= 1 {
+			vp.Logger.Error("Encoder ran out of memory even with a single thread", "output", output)
+			return err
+		}
+		threads /= 2
+		vp.Logger.Warn("Encoder ran out of memory; retrying with fewer threads", "output", output, "threads", threads)
+	}
+}
@@ -0,0 +1,337 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	NotifySuccess     = "success"
+	NotifyFailure     = "failure"
+	NotifyLongRunning = "long-running"
+
+	NotifySlack   = "slack"
+	NotifyDiscord = "discord"
+	NotifyEmail   = "email"
+
+	maxErrorExcerpt = 500
+	discordMaxChars = 2000
+)
+
+var (
+	NotifyEvents   = []string{NotifySuccess, NotifyFailure, NotifyLongRunning}
+	NotifyChannels = []string{NotifySlack, NotifyDiscord, NotifyEmail}
+
+	DefaultNotifyTemplates = map[string]string{
+		NotifySuccess:     "Processed {{.InputFile}} in {{.Elapsed}}{{if .PlaybackURL}}: {{.PlaybackURL}}{{end}}",
+		NotifyFailure:     "Failed to process {{.InputFile}} after {{.Elapsed}}: {{.Error}}",
+		NotifyLongRunning: "Still processing {{.InputFile}} after {{.Elapsed}}",
+	}
+
+	notifyClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+const smtpTimeout = 30 * time.Second
+
+// Notification is the data a notification template is rendered with. Report
+// is nil for long-running warnings, which are sent while the job still runs.
+type Notification struct {
+	Event       string
+	InputFile   string
+	OutputDir   string
+	Bucket      string
+	KeyPrefix   string
+	PlaybackURL string
+	Elapsed     time.Duration
+	Error       string
+	Report      *types.JobReport
+}
+
+type NotifyChannel interface {
+	Send(subject, message string) error
+	String() string
+}
+
+type Notifier struct {
+	Channel NotifyChannel
+	Events  []string
+}
+
+type SlackWebhook struct {
+	URL string
+}
+
+func (s SlackWebhook) Send(subject, message string) error {
+	return postJSON(s.URL, map[string]string{"text": message})
+}
+
+func (s SlackWebhook) String() string {
+	return NotifySlack
+}
+
+type DiscordWebhook struct {
+	URL string
+}
+
+func (d DiscordWebhook) Send(subject, message string) error {
+	if runes := []rune(message); len(runes) > discordMaxChars {
+		message = string(runes[:discordMaxChars-1]) + "…"
+	}
+	return postJSON(d.URL, map[string]string{"content": message})
+}
+
+func (d DiscordWebhook) String() string {
+	return NotifyDiscord
+}
+
+// SMTPServer is where email notifications are sent from. Username and
+// Password are optional; when set, PLAIN authentication is used.
+type SMTPServer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+type EmailNotifier struct {
+	Server SMTPServer
+	To     []string
+}
+
+// Send mails the notification like smtp.SendMail, but gives up after
+// smtpTimeout so an unresponsive server can't hold up the job's exit.
+func (e EmailNotifier) Send(subject, message string) error {
+	host, _, _ := strings.Cut(e.Server.Addr, ":")
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		headerValue(e.Server.From), headerValue(strings.Join(e.To, ", ")), mime.QEncoding.Encode("UTF-8", headerValue(subject)), message)
+
+	conn, err := net.DialTimeout("tcp", e.Server.Addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.Server.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Server.Username, e.Server.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.Server.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// headerValue keeps a value on one header line. Input file names, e.g. S3
+// keys, can contain line breaks that would otherwise add headers.
+func headerValue(value string) string {
+	return strings.Join(strings.FieldsFunc(value, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+}
+
+func (e EmailNotifier) String() string {
+	return NotifyEmail
+}
+
+func postJSON(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(excerpt)))
+	}
+	return nil
+}
+
+// ParseNotifier parses a notifier as channel[:event,...]=target, e.g.
+// slack:failure=https://hooks.slack.com/services/... or
+// email=ops@example.com,oncall@example.com. Without events it is sent on
+// success and failure.
+func ParseNotifier(spec string, server SMTPServer) (Notifier, error) {
+	name, target, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(target) == "" {
+		return Notifier{}, fmt.Errorf("invalid notifier %q (expected channel[:events]=target)", spec)
+	}
+	channel, events, _ := strings.Cut(name, ":")
+	notifier := Notifier{Events: []string{NotifySuccess, NotifyFailure}}
+	if events != "" {
+		notifier.Events = strings.Split(events, ",")
+		for _, event := range notifier.Events {
+			if !slices.Contains(NotifyEvents, event) {
+				return Notifier{}, fmt.Errorf("unknown notification event %q (expected %s)", event, strings.Join(NotifyEvents, ", "))
+			}
+		}
+	}
+	switch channel {
+	case NotifySlack, NotifyDiscord:
+		if !strings.HasPrefix(target, "https://") && !strings.HasPrefix(target, "http://") {
+			return Notifier{}, fmt.Errorf("%s notifier needs a webhook URL, got %q", channel, target)
+		}
+		if channel == NotifySlack {
+			notifier.Channel = SlackWebhook{URL: target}
+		} else {
+			notifier.Channel = DiscordWebhook{URL: target}
+		}
+	case NotifyEmail:
+		if server.Addr == "" || server.From == "" {
+			return Notifier{}, fmt.Errorf("email notifier needs an SMTP server and sender address")
+		}
+		to := strings.Split(target, ",")
+		for _, address := range to {
+			if !strings.Contains(address, "@") {
+				return Notifier{}, fmt.Errorf("invalid email address %q", address)
+			}
+		}
+		notifier.Channel = EmailNotifier{Server: server, To: to}
+	default:
+		return Notifier{}, fmt.Errorf("unknown notifier %q (expected %s)", channel, strings.Join(NotifyChannels, ", "))
+	}
+	return notifier, nil
+}
+
+func ValidateNotifyTemplates(templates map[string]string) error {
+	for event, text := range templates {
+		if !slices.Contains(NotifyEvents, event) {
+			return fmt.Errorf("unknown notification event %q (expected %s)", event, strings.Join(NotifyEvents, ", "))
+		}
+		if _, err := template.New(event).Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", event, err)
+		}
+	}
+	return nil
+}
+
+// playbackURL is where the published master playlist can be played from: the
+// PlaybackURL template if given, else under the segment base URL, else its
+// S3 location.
+func (vp *VideoProcessor) playbackURL() string {
+	switch {
+	case vp.PlaybackURL != "":
+		return vp.expandTemplate(vp.PlaybackURL)
+	case vp.Config.SegmentBaseURL != "":
+		return strings.TrimSuffix(vp.expandTemplate(vp.Config.SegmentBaseURL), "/") + "/" + masterPlaylistName
+	case vp.S3Bucket != "":
+		key, err := vp.objectKey(filepath.Join(vp.OutputDir, masterPlaylistName))
+		if err != nil {
+			return ""
+		}
+		return "s3://" + vp.S3Bucket + "/" + key
+	}
+	return ""
+}
+
+func errorExcerpt(err error) string {
+	text := strings.TrimSpace(err.Error())
+	if runes := []rune(text); len(runes) > maxErrorExcerpt {
+		text = string(runes[:maxErrorExcerpt]) + "…"
+	}
+	return text
+}
+
+// StartNotifications arms the long-running warning and returns the function
+// that sends the success or failure notification once the job has finished.
+func (vp *VideoProcessor) StartNotifications() func(error) {
+	if len(vp.Notifiers) == 0 {
+		return func(error) {}
+	}
+	started := time.Now()
+	var timer *time.Timer
+	if vp.NotifyLongRunning > 0 {
+		timer = time.AfterFunc(vp.NotifyLongRunning, func() {
+			vp.notify(Notification{Event: NotifyLongRunning, Elapsed: time.Since(started).Round(time.Second)})
+		})
+	}
+	return func(err error) {
+		if timer != nil {
+			timer.Stop()
+		}
+		n := Notification{Event: NotifySuccess, Elapsed: time.Since(started).Round(time.Second), Report: vp.Report}
+		if err != nil {
+			n.Event = NotifyFailure
+			n.Error = errorExcerpt(err)
+		}
+		vp.notify(n)
+	}
+}
+
+// notify sends n to every notifier subscribed to its event. Failed
+// notifications are logged and never fail the job.
+func (vp *VideoProcessor) notify(n Notification) {
+	n.InputFile = vp.InputFile
+	n.OutputDir = vp.OutputDir
+	n.Bucket = vp.S3Bucket
+	n.KeyPrefix = vp.KeyPrefix
+	n.PlaybackURL = vp.playbackURL()
+
+	text, ok := vp.NotifyTemplates[n.Event]
+	if !ok {
+		text = DefaultNotifyTemplates[n.Event]
+	}
+	tmpl, err := template.New(n.Event).Parse(text)
+	if err != nil {
+		vp.Logger.Error("Invalid notification template", "event", n.Event, "error", err)
+		return
+	}
+	var message strings.Builder
+	if err := tmpl.Execute(&message, n); err != nil {
+		vp.Logger.Error("Failed to render notification", "event", n.Event, "error", err)
+		return
+	}
+	subject := fmt.Sprintf("[video-processor] %s: %s", n.Event, filepath.Base(n.InputFile))
+
+	for _, notifier := range vp.Notifiers {
+		if !slices.Contains(notifier.Events, n.Event) {
+			continue
+		}
+		vp.Logger.Debug("Sending notification", "event", n.Event, "channel", notifier.Channel.String())
+		if err := notifier.Channel.Send(subject, message.String()); err != nil {
+			vp.Logger.Error("Failed to send notification", "event", n.Event, "channel", notifier.Channel.String(), "error", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const packageOutput = "source"
+
+var (
+	packageVideoCodecs = map[string]bool{"h264": true, "hevc": true}
+	packageAudioCodecs = map[string]bool{"aac": true, "mp3": true, "ac3": true, "eac3": true}
+)
+
+func (vp *VideoProcessor) keyframeTimes() ([]float64, error) {
+	cmd := vp.command(vp.FFprobePath, "-v", "error", "-select_streams", vp.videoSpecifier(),
+		"-show_entries", "packet=pts_time,flags", "-of", "csv=p=0", vp.sourceFile())
+	vp.Logger.Debug("Running ffprobe", "command", cmd.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyframes: %w", err)
+	}
+
+	var times []float64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		pts, flags, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ",")
+		if !strings.HasPrefix(flags, "K") {
+			continue
+		}
+		if t, err := strconv.ParseFloat(pts, 64); err == nil {
+			times = append(times, t)
+		}
+	}
+	return times, scanner.Err()
+}
+
+func maxKeyframeInterval(times []float64) float64 {
+	var longest float64
+	for i := 1; i < len(times); i++ {
+		longest = math.Max(longest, times[i]-times[i-1])
+	}
+	return longest
+}
+
+func (vp *VideoProcessor) packageRendition() error {
+	info, err := vp.Probe()
+	if err != nil {
+		return err
+	}
+	if err := vp.ValidateInput(info); err != nil {
+		return err
+	}
+	vp.source = info
+	if err := vp.checkSegmentCount(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	vp.outputSizes = map[string]string{}
+
+	video := vp.sourceVideo()
+	if video == nil {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("audio-only inputs can't be packaged; transcode instead"))
+	}
+	vp.Report.VideoStream = &video.Index
+	if !packageVideoCodecs[video.CodecName] {
+		vp.Logger.Error("Video codec can't be packaged without re-encoding", "codec", video.CodecName)
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("video codec %s is not supported in HLS; transcode instead of packaging", video.CodecName))
+	}
+
+	if err := vp.applyHDRPolicy(true); err != nil {
+		return err
+	}
+
+	keyframes, err := vp.keyframeTimes()
+	if err != nil {
+		vp.Logger.Error("Failed to read keyframes", "error", err)
+		return types.NewExitError(types.ExitProbe, err)
+	}
+	if interval := maxKeyframeInterval(keyframes); interval > float64(vp.Config.SegmentTime) {
+		vp.Logger.Warn("Source keyframe interval is longer than the segment duration, segments will be longer",
+			"maxKeyframeInterval", interval, "segmentTime", vp.Config.SegmentTime)
+	}
+
+	width, height := displaySize(video)
+	bitrate, _ := strconv.Atoi(video.BitRate)
+	if bitrate == 0 {
+		bitrate, _ = strconv.Atoi(info.Format.BitRate)
+	}
+	vp.Config.Renditions = []types.Rendition{{
+		Name:         packageOutput,
+		Width:        width,
+		Height:       height,
+		VideoBitrate: fmt.Sprintf("%dk", bitrate/1000),
+		AudioBitrate: "128k",
+	}}
+
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, vp.Config.ExtraInputArgs...)
+	args = append(args, vp.windowArgs()...)
+	args = append(args, "-i", vp.sourceFile(), "-map", "0:"+vp.videoSpecifier(), "-map", "0:a:0?", "-c:v", "copy")
+	if audio := audioStream(info); audio != nil && !packageAudioCodecs[audio.CodecName] {
+		vp.Logger.Info("Re-encoding audio that HLS doesn't support", "codec", audio.CodecName)
+		args = append(args, "-c:a", "aac", "-b:a", vp.Config.Renditions[0].AudioBitrate, "-ac", "2")
+	} else {
+		args = append(args, "-c:a", "copy")
+	}
+	hdrArgs := vp.hdrCopyArgs()
+	args = append(args, hdrArgs...)
+	if video.CodecName == "hevc" && !slices.Contains(hdrArgs, "-tag:v") {
+		vp.fmp4 = true
+		args = append(args, "-tag:v", "hvc1")
+	}
+	args = append(args,
+		"-hls_time", strconv.Itoa(vp.Config.SegmentTime), "-hls_list_size", "0",
+		"-hls_playlist_type", "vod", "-hls_flags", vp.hlsFlags())
+	args = append(args, vp.Config.ExtraOutputArgs...)
+	args = append(args, vp.segmentArgs(packageOutput)...)
+	args = append(args, filepath.Join(vp.workingDir(), packageOutput+".m3u8"))
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Info("Packaging without re-encoding", "codec", video.CodecName, "resolution", vp.Config.Renditions[0].Resolution())
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, packageOutput); err != nil {
+		vp.Logger.Error("Error packaging input", "error", err)
+		return fmt.Errorf("error packaging input: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+type renditionError struct {
+	output string
+	err    error
+}
+
+func (e *renditionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *renditionError) Unwrap() error {
+	return e.err
+}
+
+// dropFailedRenditions lets the job go on without the video renditions that
+// failed, when PartialSuccess is set. It returns the first error when any
+// other stage failed, the job was cancelled, or no video rendition is left.
+func (vp *VideoProcessor) dropFailedRenditions(errs []error) error {
+	failed := map[string]error{}
+	for _, err := range errs {
+		var failure *renditionError
+		if !vp.PartialSuccess || !errors.As(err, &failure) || errors.Is(err, ErrCancelled) {
+			return err
+		}
+		failed[failure.output] = err
+	}
+	remaining := slices.DeleteFunc(slices.Clone(vp.Config.Renditions), func(r types.Rendition) bool {
+		return failed[r.Name] != nil
+	})
+	if len(remaining) == 0 {
+		vp.Logger.Error("Every rendition failed, nothing to publish")
+		return errs[0]
+	}
+
+	vp.Report.Degraded = true
+	vp.Report.FailedRenditions = map[string]string{}
+	for output, err := range failed {
+		vp.Logger.Warn("Leaving failed rendition out of the output", "output", output, "error", err)
+		vp.Report.FailedRenditions[output] = err.Error()
+		if err := vp.removeOutputFiles(output); err != nil {
+			return err
+		}
+	}
+	vp.Config.Renditions = remaining
+	vp.Logger.Warn("Continuing with a partial ladder", "failed", len(failed), "remaining", len(remaining))
+	return nil
+}
+
+func (vp *VideoProcessor) removeOutputFiles(output string) error {
+	entries, err := os.ReadDir(vp.workingDir())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || vp.outputStem(entry.Name()) != output {
+			continue
+		}
+		if err := os.Remove(filepath.Join(vp.workingDir(), entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove %s of failed rendition %s: %w", entry.Name(), output, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const defaultPassthroughTolerance = 0.1
+
+func (vp *VideoProcessor) passthroughTolerance() float64 {
+	if vp.Config.PassthroughTolerance > 0 {
+		return vp.Config.PassthroughTolerance
+	}
+	return defaultPassthroughTolerance
+}
+
+func (vp *VideoProcessor) videoPassthroughReason(outputName string, width, height int, bitrate, level string, keyframeInterval float64) string {
+	stream := vp.sourceVideo()
+	switch {
+	case stream == nil:
+		return "no source video stream"
+	case len(vp.Config.Burnin) > 0:
+		return "burn-in overlay"
+	case stream.CodecName != "h264":
+		return fmt.Sprintf("source codec is %s", stream.CodecName)
+	case stream.PixFmt != "yuv420p":
+		return fmt.Sprintf("source pixel format is %s", stream.PixFmt)
+	case rotationOf(stream) != 0:
+		return "source is rotated"
+	case stream.SampleAspectRatio != "" && stream.SampleAspectRatio != "1:1" && stream.SampleAspectRatio != "0:1":
+		return fmt.Sprintf("source sample aspect ratio is %s", stream.SampleAspectRatio)
+	case stream.Width != width || stream.Height != height:
+		return fmt.Sprintf("source is %dx%d", stream.Width, stream.Height)
+	case vp.hdr.dolbyVision || vp.hdr.hdr10Plus:
+		return "source has dynamic HDR metadata"
+	case vp.hasFilters(outputName):
+		return "rendition has extra filters"
+	case vp.frameRateFilter != "":
+		return "source has a variable frame rate"
+	case keyframeInterval <= 0 || keyframeInterval > float64(vp.Config.SegmentTime):
+		return fmt.Sprintf("source keyframe interval %.2fs exceeds the segment duration", keyframeInterval)
+	}
+
+	sourceBitrate, err := strconv.Atoi(stream.BitRate)
+	if err != nil || sourceBitrate <= 0 {
+		return "source bitrate is unknown"
+	}
+	kbps, err := utils.ParseBitrate(bitrate)
+	if err != nil {
+		return err.Error()
+	}
+	limit := float64(kbps*1000) * (1 + vp.passthroughTolerance())
+	if float64(sourceBitrate) > limit {
+		return fmt.Sprintf("source bitrate %dk exceeds %s", sourceBitrate/1000, bitrate)
+	}
+	if rungLevel, err := strconv.ParseFloat(level, 64); err == nil && float64(stream.Level) > rungLevel*10 {
+		return fmt.Sprintf("source level %.1f exceeds %s", float64(stream.Level)/10, level)
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ProgramDateTimeMTime = "mtime"
+	ProgramDateTimeNow   = "now"
+
+	programDateTimeTag    = "#EXT-X-PROGRAM-DATE-TIME:"
+	programDateTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+func ValidateProgramDateTime(value string) error {
+	switch value {
+	case "", ProgramDateTimeMTime, ProgramDateTimeNow:
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+		return fmt.Errorf("expected %s, %s or an RFC 3339 time such as 2024-05-01T18:30:00Z, got %q", ProgramDateTimeMTime, ProgramDateTimeNow, value)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) programDateTimeStart() (time.Time, error) {
+	var start time.Time
+	switch vp.Config.ProgramDateTime {
+	case ProgramDateTimeMTime:
+		info, err := vp.statInput()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat input file: %w", err)
+		}
+		start = info.ModTime()
+	case ProgramDateTimeNow:
+		start = vp.encodeStarted
+	default:
+		parsed, err := time.Parse(time.RFC3339Nano, vp.Config.ProgramDateTime)
+		if err != nil {
+			return time.Time{}, err
+		}
+		start = parsed
+	}
+	return start.Add(time.Duration(vp.trimStart * float64(time.Second))).UTC(), nil
+}
+
+func ProgramDateTime(start time.Time) PlaylistProcessor {
+	return func(playlist *Playlist) error {
+		if playlist.Master {
+			return nil
+		}
+		var lines []string
+		var elapsed float64
+		for _, line := range playlist.Lines {
+			if strings.HasPrefix(line, programDateTimeTag) {
+				continue
+			}
+			if value, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+				value, _, _ = strings.Cut(value, ",")
+				duration, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return fmt.Errorf("invalid segment duration %q", line)
+				}
+				stamp := start.Add(time.Duration(elapsed * float64(time.Second)))
+				lines = append(lines, programDateTimeTag+stamp.Format(programDateTimeLayout))
+				elapsed += duration
+			}
+			lines = append(lines, line)
+		}
+		playlist.Lines = lines
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	posterDir       = "poster"
+	posterFrameName = ".poster-frame.png"
+	posterBatch     = 100
+)
+
+var (
+	DefaultPosterSizes   = []int{1080, 720, 360}
+	DefaultPosterFormats = []string{"jpeg"}
+
+	posterEncoders = map[string][]string{
+		"jpeg": {"-q:v", "2"},
+		"webp": {"-c:v", "libwebp", "-quality", "85"},
+		"avif": {"-c:v", "libaom-av1", "-still-picture", "1", "-crf", "30", "-b:v", "0"},
+	}
+	posterExtensions = map[string]string{"jpeg": ".jpg", "webp": ".webp", "avif": ".avif"}
+)
+
+func ValidatePoster(at string, sizes []int, formats []string) error {
+	if at != "" {
+		if _, err := ParseClockTime(at); err != nil {
+			return fmt.Errorf("invalid poster time: %w", err)
+		}
+	}
+	for _, size := range sizes {
+		if size <= 0 {
+			return fmt.Errorf("invalid poster size %d", size)
+		}
+	}
+	for _, format := range formats {
+		if _, ok := posterEncoders[format]; !ok {
+			return fmt.Errorf("unknown poster format %q (expected jpeg, webp or avif)", format)
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) GeneratePoster(at string, sizes []int, formats []string) (err error) {
+	done := vp.startStage(StagePoster, "")
+	defer func() { done(err) }()
+	if err := ValidatePoster(at, sizes, formats); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	dir := filepath.Join(vp.OutputDir, posterDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		vp.Logger.Error("Failed to create poster directory", "dir", dir, "error", err)
+		return fmt.Errorf("failed to create poster directory: %w", err)
+	}
+	if err := vp.ownOutputDir(posterDir); err != nil {
+		return fmt.Errorf("failed to record poster directory: %w", err)
+	}
+
+	if vp.Fake {
+		vp.Logger.Info("Fake mode: writing synthetic posters instead of running ffmpeg")
+		for _, size := range sizes {
+			for _, format := range formats {
+				path := filepath.Join(dir, fmt.Sprintf("poster_%d%s", size, posterExtensions[format]))
+				if err := os.WriteFile(path, []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	frame := filepath.Join(dir, posterFrameName)
+	defer os.Remove(frame)
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, vp.Config.ExtraInputArgs...)
+	if at != "" {
+		args = append(args, "-ss", at)
+		args = append(args, vp.inputArgs()...)
+		args = append(args, "-map", "0:"+vp.videoSpecifier())
+	} else {
+		args = append(args, "-skip_frame", "nokey")
+		args = append(args, vp.inputArgs()...)
+		args = append(args, "-map", "0:"+vp.videoSpecifier(), "-vf", fmt.Sprintf("thumbnail=n=%d", posterBatch), "-vsync", "vfr")
+	}
+	args = append(args, "-frames:v", "1", frame)
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Info("Selecting poster frame", "time", at, "automatic", at == "")
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, posterDir); err != nil {
+		vp.Logger.Error("Error selecting poster frame", "error", err)
+		return fmt.Errorf("error selecting poster frame: %w", err)
+	}
+
+	args = []string{"-y", "-i", frame}
+	for _, size := range sizes {
+		for _, format := range formats {
+			args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", size))
+			args = append(args, posterEncoders[format]...)
+			args = append(args, "-frames:v", "1", filepath.Join(dir, fmt.Sprintf("poster_%d%s", size, posterExtensions[format])))
+		}
+	}
+	ffmpegCmd = vp.encoderCommand(args...)
+	vp.Logger.Info("Writing posters", "dir", dir, "sizes", sizes, "formats", formats)
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, posterDir); err != nil {
+		vp.Logger.Error("Error writing posters", "error", err)
+		return fmt.Errorf("error writing posters: %w", err)
+	}
+	return nil
+}
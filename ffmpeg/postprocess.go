@@ -0,0 +1,118 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+type Playlist struct {
+	Name      string
+	Master    bool
+	VideoID   string
+	KeyPrefix string
+	Lines     []string
+}
+
+type PlaylistProcessor func(playlist *Playlist) error
+
+func InsertTags(master bool, tags ...string) PlaylistProcessor {
+	return func(playlist *Playlist) error {
+		if playlist.Master != master || len(tags) == 0 {
+			return nil
+		}
+		at := 0
+		for i, line := range playlist.Lines {
+			if line == "#EXTM3U" || strings.HasPrefix(line, "#EXT-X-VERSION:") {
+				at = i + 1
+			}
+		}
+		lines := append([]string{}, playlist.Lines[:at]...)
+		for _, tag := range tags {
+			lines = append(lines, expandTemplate(tag, playlist.VideoID, playlist.KeyPrefix))
+		}
+		playlist.Lines = append(lines, playlist.Lines[at:]...)
+		return nil
+	}
+}
+
+func LoadPlaylistTemplate(path string) ([]PlaylistProcessor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist template: %w", err)
+	}
+	defer file.Close()
+
+	var master, media []string
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";"):
+		case line == "[master]" || line == "[media]" || line == "[all]":
+			section = strings.Trim(line, "[]")
+		case !strings.HasPrefix(line, "#"):
+			return nil, fmt.Errorf("%s:%d: expected a playlist tag or section, got %q", path, number, line)
+		case section == "":
+			return nil, fmt.Errorf("%s:%d: tag outside a [master], [media] or [all] section", path, number)
+		default:
+			if section != "media" {
+				master = append(master, line)
+			}
+			if section != "master" {
+				media = append(media, line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist template: %w", err)
+	}
+	return []PlaylistProcessor{InsertTags(true, master...), InsertTags(false, media...)}, nil
+}
+
+func (vp *VideoProcessor) postProcessPlaylists() error {
+	processors := vp.PlaylistProcessors
+	if vp.Config.ProgramDateTime != "" {
+		start, err := vp.programDateTimeStart()
+		if err != nil {
+			return err
+		}
+		processors = append(append([]PlaylistProcessor(nil), processors...), ProgramDateTime(start))
+	}
+	if len(processors) == 0 {
+		return nil
+	}
+	paths, err := vp.outputPlaylists()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		playlist := &Playlist{
+			Name:      filepath.Base(path),
+			Master:    hls.IsMaster(data),
+			VideoID:   vp.videoID(),
+			KeyPrefix: vp.KeyPrefix,
+			Lines:     strings.Split(strings.TrimRight(string(data), "\n"), "\n"),
+		}
+		for _, process := range processors {
+			if err := process(playlist); err != nil {
+				return fmt.Errorf("%s: %w", playlist.Name, err)
+			}
+		}
+		if err := utils.WriteFileAtomic(path, []byte(strings.Join(playlist.Lines, "\n")+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	vp.Logger.Info("Post-processed playlists", "playlists", len(paths))
+	return nil
+}
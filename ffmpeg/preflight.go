@@ -0,0 +1,177 @@
+package ffmpeg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const diskHeadroom = 1.2
+
+func (vp *VideoProcessor) EstimateOutputSize(info *types.ProbeInfo) uint64 {
+	var kbps int
+	for _, rendition := range vp.Config.Renditions {
+		kbps += rendition.VideoKbps() + rendition.AudioKbps()
+	}
+	duration := probeDuration(info)
+	if vp.Sample > 0 {
+		duration = min(duration, vp.Sample.Seconds())
+	}
+	return uint64(duration * float64(kbps) * 1000 / 8)
+}
+
+func (vp *VideoProcessor) checkDiskSpace(info *types.ProbeInfo) error {
+	required := uint64(float64(vp.EstimateOutputSize(info)) * diskHeadroom)
+
+	dirs := []string{vp.workingDir()}
+	if vp.workingDir() != vp.OutputDir {
+		dirs = append(dirs, vp.OutputDir)
+	}
+	for _, dir := range dirs {
+		free, err := utils.FreeDiskSpace(dir)
+		if err != nil {
+			vp.Logger.Warn("Skipping disk space check", "dir", dir, "error", err)
+			continue
+		}
+		vp.Logger.Info("Disk space check", "dir", dir, "requiredBytes", required, "freeBytes", free)
+		if free < required {
+			vp.Logger.Error("Not enough disk space", "dir", dir, "requiredBytes", required, "freeBytes", free)
+			return types.NewExitError(types.ExitValidation,
+				fmt.Errorf("not enough disk space in %s: need about %d MB, %d MB available", dir, required>>20, free>>20))
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) prepareWorkDir() error {
+	if vp.ScratchDir == "" {
+		vp.workDir = ""
+		return nil
+	}
+
+	absOutput, err := filepath.Abs(vp.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(absOutput))
+	vp.workDir = filepath.Join(vp.ScratchDir, fmt.Sprintf("go-ffmpeg-%s-%x", filepath.Base(absOutput), sum[:4]))
+	if !vp.Resume {
+		if err := os.RemoveAll(vp.workDir); err != nil {
+			return fmt.Errorf("failed to clear scratch directory: %w", err)
+		}
+	}
+	if err := os.MkdirAll(vp.workDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	vp.Logger.Info("Using scratch directory", "scratchDir", vp.workDir)
+	return nil
+}
+
+func (vp *VideoProcessor) finalizeWorkDir() error {
+	if vp.workDir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(vp.workDir)
+	if err != nil {
+		return fmt.Errorf("failed to read scratch directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		src := filepath.Join(vp.workDir, entry.Name())
+		dst := filepath.Join(vp.OutputDir, entry.Name())
+		if err := utils.MoveFile(src, dst); err != nil {
+			vp.Logger.Error("Failed to move file from scratch directory", "src", src, "dst", dst, "error", err)
+			return fmt.Errorf("failed to move %s to output directory: %w", entry.Name(), err)
+		}
+	}
+	vp.Logger.Info("Moved outputs from scratch directory", "scratchDir", vp.workDir, "outputDir", vp.OutputDir)
+	return os.RemoveAll(vp.workDir)
+}
+
+func (vp *VideoProcessor) workingDir() string {
+	if vp.workDir != "" {
+		return vp.workDir
+	}
+	return vp.OutputDir
+}
+
+// snapshotOutputs records the files already in the output directory before
+// a job writes to it. writeOutputManifest adds the files that are new or
+// changed since, which doesn't depend on the file system's timestamp
+// resolution the way comparing against the job's start time would.
+func (vp *VideoProcessor) snapshotOutputs() (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(vp.OutputDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output directory: %w", err)
+	}
+	existing := map[string]os.FileInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", entry.Name(), err)
+		}
+		existing[entry.Name()] = info
+	}
+	return existing, nil
+}
+
+func (vp *VideoProcessor) writeOutputManifest(existing map[string]os.FileInfo) error {
+	files, err := utils.ReadOutputManifest(vp.OutputDir)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, name := range files {
+		seen[name] = true
+	}
+	if !seen[UploadManifestName] {
+		files = append(files, UploadManifestName)
+		seen[UploadManifestName] = true
+	}
+
+	entries, err := os.ReadDir(vp.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == utils.OutputManifest || seen[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", entry.Name(), err)
+		}
+		if before, ok := existing[entry.Name()]; ok && before.ModTime().Equal(info.ModTime()) && before.Size() == info.Size() {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+	return utils.WriteOutputManifest(vp.OutputDir, files)
+}
+
+// ownOutputDir records a subdirectory the tool writes into, such as the
+// thumbnails, in the output manifest, so later runs may clear it.
+func (vp *VideoProcessor) ownOutputDir(name string) error {
+	files, err := utils.ReadOutputManifest(vp.OutputDir)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(files, name) {
+		return nil
+	}
+	return utils.WriteOutputManifest(vp.OutputDir, append(files, name))
+}
@@ -0,0 +1,35 @@
+package ffmpeg
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+func TestOutputManifestListsOnlyJobFiles(t *testing.T) {
+	vp := NewVideoProcessor(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	vp.Fake = true
+	vp.OutputDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(vp.OutputDir, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vp.ProcessVideo(); err != nil {
+		t.Fatalf("ProcessVideo: %v", err)
+	}
+	files, err := utils.ReadOutputManifest(vp.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(files, "notes.txt") {
+		t.Errorf("manifest lists a file the job didn't write: %v", files)
+	}
+	if !slices.Contains(files, masterPlaylistName) {
+		t.Errorf("manifest doesn't list %s: %v", masterPlaylistName, files)
+	}
+}
@@ -0,0 +1,107 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strconv"
+)
+
+const (
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+func (vp *VideoProcessor) encoderCommand(args ...string) *exec.Cmd {
+	cmd, _ := vp.limitedEncoderCommand(vp.Threads, args...)
+	return cmd
+}
+
+// limitedEncoderCommand returns the ffmpeg command and, when it runs in a
+// systemd-run scope, the scope's unit name.
+func (vp *VideoProcessor) limitedEncoderCommand(threads int, args ...string) (*exec.Cmd, string) {
+	if vp.Config.Reproducible {
+		args = reproducibleArgs(args)
+	}
+	args = threadArgs(threads, args)
+	if vp.readsProgress() {
+		args = append([]string{"-progress", "pipe:1", "-nostats"}, args...)
+	}
+	if vp.CPULimit <= 0 && vp.MemoryLimit <= 0 {
+		return vp.command(vp.FFmpegPath, args...), ""
+	}
+	if vp.ContainerRuntime != "" {
+		var runtimeArgs []string
+		if vp.CPULimit > 0 {
+			runtimeArgs = append(runtimeArgs, "--cpus", strconv.FormatFloat(vp.CPULimit, 'f', -1, 64))
+		}
+		if vp.MemoryLimit > 0 {
+			runtimeArgs = append(runtimeArgs, "--memory", strconv.FormatInt(vp.MemoryLimit, 10))
+		}
+		return vp.containerCommand(vp.FFmpegPath, runtimeArgs, args...), ""
+	}
+	scope := newScopeName()
+	runArgs := []string{"--user", "--scope", "--quiet", "--unit", scope}
+	if vp.CPULimit > 0 {
+		runArgs = append(runArgs, "-p", fmt.Sprintf("CPUQuota=%d%%", int(vp.CPULimit*100)))
+	}
+	if vp.MemoryLimit > 0 {
+		runArgs = append(runArgs, "-p", fmt.Sprintf("MemoryMax=%d", vp.MemoryLimit), "-p", "MemorySwapMax=0")
+	}
+	runArgs = append(runArgs, "--", vp.FFmpegPath)
+	return exec.Command("systemd-run", append(runArgs, args...)...), scope
+}
+
+// wrapCommand makes cmd run through prefix, a command such as nice that
+// execs the rest of its arguments. The program keeps its pid, so the process
+// group, pause and kill still reach it.
+func wrapCommand(cmd *exec.Cmd, prefix []string) {
+	if len(prefix) == 0 {
+		return
+	}
+	args := append(slices.Clone(prefix[1:]), cmd.Path)
+	wrapped := exec.Command(prefix[0], append(args, cmd.Args[1:]...)...)
+	cmd.Path, cmd.Args = wrapped.Path, wrapped.Args
+	if cmd.Err == nil {
+		cmd.Err = wrapped.Err
+	}
+}
+
+func (vp *VideoProcessor) runEncoder(cmd *exec.Cmd, output string) error {
+	if err := vp.checkJobDeadline(); err != nil {
+		return err
+	}
+	var progress io.ReadCloser
+	if vp.readsProgress() {
+		pipe, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		progress = pipe
+	}
+	if vp.ContainerRuntime == "" && (vp.Nice > 0 || vp.IOClass != "") {
+		if err := lowerPriority(cmd, vp.Nice, vp.IOClass); err != nil {
+			vp.Logger.Warn("Failed to lower ffmpeg priority", "error", err)
+		}
+	}
+	if err := vp.control.start(cmd); err != nil {
+		return err
+	}
+
+	vp.emit(ProgressEvent{Type: "state", Output: output, State: StateRunning})
+	watch := vp.watch(cmd.Process, output)
+	if progress != nil {
+		vp.readProgress(progress, output, watch)
+	}
+	err := vp.control.finish(cmd.Process, cmd.Wait())
+	if reason := watch.stop(); reason != nil && err != nil {
+		err = reason
+	}
+	if err != nil {
+		vp.emit(ProgressEvent{Type: "state", Output: output, State: StateFailed, Error: err.Error()})
+	} else {
+		vp.emit(ProgressEvent{Type: "state", Output: output, State: StateDone})
+	}
+	return err
+}
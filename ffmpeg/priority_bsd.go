@@ -0,0 +1,17 @@
+//go:build darwin || freebsd
+
+package ffmpeg
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// lowerPriority runs cmd through nice, so ffmpeg starts with the lower
+// priority and every thread it creates inherits it.
+func lowerPriority(cmd *exec.Cmd, nice int, ioClass string) error {
+	if nice > 0 {
+		wrapCommand(cmd, []string{"nice", "-n", strconv.Itoa(nice)})
+	}
+	return nil
+}
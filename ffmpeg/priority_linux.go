@@ -0,0 +1,30 @@
+//go:build linux
+
+package ffmpeg
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// lowerPriority runs cmd through nice and ionice, so ffmpeg starts with the
+// lower priority and every thread it creates inherits it. Linux priorities
+// are per thread, so changing them once ffmpeg runs would miss its workers.
+func lowerPriority(cmd *exec.Cmd, nice int, ioClass string) error {
+	var prefix []string
+	if nice > 0 {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(nice))
+	}
+	switch ioClass {
+	case "":
+	case IOClassBestEffort:
+		prefix = append(prefix, "ionice", "-c", "2", "-n", "7")
+	case IOClassIdle:
+		prefix = append(prefix, "ionice", "-c", "3")
+	default:
+		return fmt.Errorf("unknown I/O class %q", ioClass)
+	}
+	wrapCommand(cmd, prefix)
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ffmpeg
+
+import "os/exec"
+
+func lowerPriority(cmd *exec.Cmd, nice int, ioClass string) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package ffmpeg
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const (
+	belowNormalPriorityClass = 0x4000
+	idlePriorityClass        = 0x0040
+)
+
+// lowerPriority creates ffmpeg in a lower priority class.
+func lowerPriority(cmd *exec.Cmd, nice int, ioClass string) error {
+	class := uint32(belowNormalPriorityClass)
+	if nice >= 15 || ioClass == IOClassIdle {
+		class = idlePriorityClass
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= class
+	return nil
+}
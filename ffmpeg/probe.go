@@ -0,0 +1,44 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+func (vp *VideoProcessor) Probe() (*types.ProbeInfo, error) {
+	done := vp.startStage(StageProbe, "")
+	info, err := vp.probeFile(vp.sourceFile(), vp.probeInputArgs()...)
+	done(err)
+	if err != nil {
+		vp.Logger.Error("Failed to probe input", "file", vp.InputFile, "error", err)
+		return nil, types.NewExitError(types.ExitProbe, fmt.Errorf("failed to probe input %s: %w", vp.InputFile, err))
+	}
+	if isStillImage(vp.InputFile) {
+		info.Format.Duration = strconv.FormatFloat(vp.stillSeconds(), 'f', 3, 64)
+	}
+	return info, nil
+}
+
+func (vp *VideoProcessor) probeFile(path string, inputArgs ...string) (*types.ProbeInfo, error) {
+	probeArgs := append(inputArgs, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	probeCmd := vp.command(vp.FFprobePath, probeArgs...)
+	vp.Logger.Debug("Running ffprobe", "command", probeCmd.String())
+	output, err := probeCmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var info types.ProbeInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	return &info, nil
+}
+
+func probeDuration(info *types.ProbeInfo) float64 {
+	duration, _ := strconv.ParseFloat(info.Format.Duration, 64)
+	return duration
+}
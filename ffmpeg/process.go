@@ -1,81 +1,558 @@
 package ffmpeg
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/storage"
 	"github.com/gastrader/go_ffmpeg/types"
 	"github.com/gastrader/go_ffmpeg/utils"
 	"github.com/joho/godotenv"
 )
 
+const masterPlaylistName = "playlist.m3u8"
+
 type VideoProcessor struct {
-	Logger    *slog.Logger
-	S3Client  *s3.Client
-	InputFile string
-	OutputDir string
-	S3Bucket  string
-	Config    types.VideoProcessingConfig
+	Logger       *slog.Logger
+	S3Client     *s3.Client
+	Storage      storage.Storage
+	InputFile    string
+	ConcatInputs []string
+	OutputDir    string
+	S3Bucket     string
+	KeyPrefix    string
+	VideoID      string
+
+	AssumeRoleARN string
+	ExternalID    string
+	RequesterPays bool
+
+	Resume bool
+	Config types.VideoProcessingConfig
+
+	ScratchDir    string
+	SkipDiskCheck bool
+	IfNoneMatch   bool
+	Fake          bool
+	PackageOnly   bool
+
+	IdempotencyKey string
+	SkipDuplicates bool
+	Prices         *UnitPrices
+	StatsInterval  time.Duration
+	EncodeJobs     int
+	UploadJobs     int
+
+	DeleteStale          bool
+	SkipUnchanged        bool
+	DeleteAfterUpload    bool
+	KeepOutputs          int
+	PlaylistCacheControl string
+	SegmentCacheControl  string
+	ObjectTags           map[string]string
+	ObjectMetadata       map[string]string
+	TagObjectType        bool
+
+	ErrDetect      string
+	DiscardCorrupt bool
+	ExitOnError    bool
+	Repair         bool
+
+	Nice        int
+	IOClass     string
+	CPULimit    float64
+	MemoryLimit int64
+	Threads     int
+
+	JobTimeout       time.Duration
+	RenditionTimeout time.Duration
+	StallTimeout     time.Duration
+	StallRetries     int
+	RenditionRetries int
+	PartialSuccess   bool
+
+	Sample      time.Duration
+	SampleStart string
+
+	FFmpegPath         string
+	FFprobePath        string
+	ManagedToolsDir    string
+	MinFFmpegVersion   string
+	ContainerRuntime   string
+	ContainerImage     string
+	Report             *types.JobReport
+	Hooks              []Hook
+	Notifiers          []Notifier
+	NotifyTemplates    map[string]string
+	NotifyLongRunning  time.Duration
+	PlaybackURL        string
+	PlaylistProcessors []PlaylistProcessor
+	Progress           func(ProgressEvent)
+
+	control *control
+	*jobState
+}
+
+// jobState is what a VideoProcessor learns while running one job. Each job
+// started with Run gets a fresh one.
+type jobState struct {
+	workDir       string
+	repaired      string
+	concatenated  string
+	composed      string
+	trimStart     float64
+	trimLength    float64
+	surround      bool
+	dolby         bool
+	described     *types.ProbeStream
+	separateAudio bool
+	noAudio       bool
+	audioOnly     bool
+	silentAudio   bool
+	fmp4          bool
+	hdr           dynamicHDR
+	source        *types.ProbeInfo
+	outputSizes   map[string]string
+	outputLevels  map[string]string
+	keyInfo       string
+
+	frameRateFilter   string
+	variableFrameRate bool
+	statsMu           sync.Mutex
+	encodeStarted     time.Time
+	encodePaused      time.Duration
+	onlyOutputs       map[string]bool
+	unchangedUploads  int
+	remoteFiles       map[string]types.UploadedFile
+}
+
+func NewVideoProcessorWithHandler(handler slog.Handler) *VideoProcessor {
+	return NewVideoProcessor(slog.New(handler))
 }
 
 func NewVideoProcessor(logger *slog.Logger) *VideoProcessor {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	return &VideoProcessor{
-		Logger: logger,
+		Logger:           logger,
+		control:          &control{},
+		jobState:         &jobState{},
+		FFmpegPath:       envOr("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:      envOr("FFPROBE_PATH", "ffprobe"),
+		ManagedToolsDir:  DefaultManagedToolsDir(),
+		MinFFmpegVersion: "4.0",
+		Report:           &types.JobReport{},
 		Config: types.VideoProcessingConfig{
-			Outputs:     []string{"1080", "720"},
-			Resolutions: []string{"1920x1080", "1280x720"},
-			Bitrates:    []string{"16000k", "6000k"},
-			AudioRates:  []string{"128k", "96k"},
-			Levels:      []string{"4.2", "3.1"},
+			Renditions:  append([]types.Rendition(nil), ladderPresets["default"]...),
+			VideoCodec:  DefaultVideoCodec,
 			Preset:      "slow",
+			ScalePolicy: ScaleFit,
 			CRF:         12,
 			SegmentTime: 4,
+
+			SegmentDigits: DefaultSegmentDigits,
 		},
 	}
 }
 
-func (vp *VideoProcessor) ProcessVideo() error {
+func (vp *VideoProcessor) ProcessVideo() (err error) {
 	vp.Logger.Info("Processing video into segments.")
+	vp.encodeStarted = time.Now()
+	vp.encodePaused, _ = vp.control.pausedTime()
+	defer vp.runErrorHooks("encode", &err)
+	defer func() {
+		if err != nil {
+			vp.emit(ProgressEvent{Type: "job", State: StateFailed, Error: err.Error()})
+		} else {
+			vp.emit(ProgressEvent{Type: "job", State: StateDone})
+		}
+	}()
+	vp.emit(ProgressEvent{Type: "job", State: StateRunning})
+
+	if len(vp.Config.Burnin) > 0 {
+		if vp.PackageOnly {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("burn-in needs re-encoding and can't be used when packaging"))
+		}
+		if err := ValidateBurnin(vp.Config.Burnin); err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		if len(vp.Config.Renditions) > 0 {
+			vp.Config.Renditions = []types.Rendition{reviewRendition(vp.Config.Renditions)}
+		}
+	}
+	if !vp.PackageOnly {
+		if err := types.ValidateRenditions(vp.Config.Renditions); err != nil {
+			vp.Logger.Error("Invalid rendition ladder", "error", err)
+			return types.NewExitError(types.ExitValidation, err)
+		}
+	}
+	if err := vp.validateEncryption(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := vp.validateVariantOptions(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := vp.validateReproducible(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := vp.validateWebM(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := vp.validateSegmentFormat(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := types.ValidateStartOffset(vp.Config.StartOffset); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if vp.Config.SurroundAudio {
+		if _, err := utils.ParseBitrate(vp.surroundBitrate()); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("surround %w", err))
+		}
+	}
+	if err := ValidateDolbyAudio(vp.Config.DolbyAudio, vp.Config.DolbyBitrate); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := ValidateLanguage(vp.Config.AudioLanguage); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := ValidateAudioDescription(vp.Config.AudioDescription); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if vp.Sample > 0 {
+		if vp.Resume {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("sample encodes can't be resumed"))
+		}
+		if err := ValidateSampleStart(vp.SampleStart); err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+	}
+	outputsBefore, err := vp.snapshotOutputs()
+	if err != nil {
+		return err
+	}
+	if err := vp.runHooks(HookPreEncode, "", nil); err != nil {
+		return err
+	}
+
+	if err := vp.prepareWorkDir(); err != nil {
+		vp.Logger.Error("Failed to prepare scratch directory", "scratchDir", vp.ScratchDir, "error", err)
+		return err
+	}
+	if err := vp.prepareEncryption(); err != nil {
+		return err
+	}
+	defer vp.removeKeyInfo()
+
+	if len(vp.ConcatInputs) > 1 && !vp.Fake {
+		if err := vp.concatInputs(); err != nil {
+			return err
+		}
+		defer vp.removeConcatInput()
+	}
+	if vp.Config.ComposeWith != "" && !vp.Fake {
+		if err := vp.composeInputs(); err != nil {
+			return err
+		}
+		defer vp.removeComposedInput()
+	}
+
+	if vp.Fake {
+		if err := vp.writeFakeRenditions(); err != nil {
+			vp.Logger.Error("Failed to generate fake renditions", "error", err)
+			return fmt.Errorf("failed to generate fake renditions: %w", err)
+		}
+	} else if vp.PackageOnly {
+		if err := vp.packageRendition(); err != nil {
+			return err
+		}
+	} else if err := vp.encodeRenditions(); err != nil {
+		return err
+	}
+
+	if err := vp.generatePlaylists(); err != nil {
+		return err
+	}
+	vp.removeKeyInfo()
+
+	if err := vp.finalizeWorkDir(); err != nil {
+		return err
+	}
+	if err := vp.writeOutputManifest(outputsBefore); err != nil {
+		vp.Logger.Error("Failed to write output manifest", "error", err)
+		return fmt.Errorf("failed to write output manifest: %w", err)
+	}
+	if vp.Config.Reproducible {
+		if err := vp.writeChecksums(); err != nil {
+			vp.Logger.Error("Failed to write output checksums", "error", err)
+			return fmt.Errorf("failed to write output checksums: %w", err)
+		}
+	}
+	if err := vp.measureCost(vp.encodeStarted); err != nil {
+		return fmt.Errorf("failed to measure output cost: %w", err)
+	}
+
+	if vp.Sample > 0 {
+		vp.Report.Sample = vp.sampleLabel()
+		vp.Logger.Warn("This is a sample encode, not the full video", "sample", vp.Report.Sample, "outputDir", vp.OutputDir)
+	}
+	vp.Logger.Info("Video processing completed successfully")
+	return vp.runHooks(HookPostEncode, "", nil)
+}
 
+// generatePlaylists writes the master playlist and DASH manifest and rewrites
+// the rendition playlists for publishing.
+func (vp *VideoProcessor) generatePlaylists() (err error) {
+	done := vp.startStage(StagePlaylists, "")
+	defer func() { done(err) }()
+	if err := vp.GenerateMasterPlaylist(); err != nil {
+		vp.Logger.Error("Failed to generate master playlist", "error", err)
+		return fmt.Errorf("failed to generate master playlist: %w", err)
+	}
+	if vp.Config.DASH {
+		if err := vp.GenerateDASHManifest(); err != nil {
+			vp.Logger.Error("Failed to generate DASH manifest", "error", err)
+			return fmt.Errorf("failed to generate DASH manifest: %w", err)
+		}
+	}
+	if err := vp.rewriteSegmentURIs(); err != nil {
+		vp.Logger.Error("Failed to rewrite segment URIs", "error", err)
+		return fmt.Errorf("failed to rewrite segment URIs: %w", err)
+	}
+	if err := vp.postProcessPlaylists(); err != nil {
+		vp.Logger.Error("Failed to post-process playlists", "error", err)
+		return fmt.Errorf("failed to post-process playlists: %w", err)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) encodeRenditions() error {
 	numCPUs := runtime.NumCPU()
 	sem := make(chan struct{}, numCPUs)
 	var wg sync.WaitGroup
-	var errChan = make(chan error, len(vp.Config.Resolutions))
+	var errChan = make(chan error, len(vp.Config.Renditions)+5)
 
-	frameRateCmd := exec.Command("ffprobe", "-v", "0", "-of", "default=noprint_wrappers=1:nokey=1",
-		"-select_streams", "v:0", "-show_entries", "stream=avg_frame_rate", vp.InputFile)
+	if vp.Repair {
+		if err := vp.repairInput(); err != nil {
+			return err
+		}
+		defer vp.removeRepairedInput()
+	}
 
+	info, err := vp.Probe()
+	if err != nil {
+		return err
+	}
+	if err := vp.ValidateInput(info); err != nil {
+		return err
+	}
+	vp.source = info
+	if err := vp.checkSegmentCount(); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	vp.audioOnly = false
+	if vp.sourceVideo() == nil {
+		return vp.encodeAudioLadder()
+	}
+	vp.Report.VideoStream = &vp.sourceVideo().Index
+	vp.trimStart, vp.trimLength = 0, 0
+	if vp.Config.Trim {
+		if err := vp.detectTrim(); err != nil {
+			return err
+		}
+	}
+	vp.outputSizes = map[string]string{}
+	vp.outputLevels = map[string]string{}
+	if vp.Config.SurroundAudio {
+		if stream := audioStream(info); stream != nil && stream.Channels > 2 {
+			vp.surround = true
+		} else {
+			vp.Logger.Info("Source audio is not multi-channel, skipping surround rendition")
+		}
+	}
+	if err := vp.applyHDRPolicy(false); err != nil {
+		return err
+	}
+	vp.noAudio, vp.silentAudio = false, false
+	if audioStream(info) == nil {
+		if vp.Config.NoAudioPolicy == NoAudioSilence {
+			vp.Logger.Info("Source has no audio stream, adding a silent audio track")
+			vp.silentAudio = true
+		} else {
+			vp.Logger.Info("Source has no audio stream, encoding video only")
+			vp.noAudio = true
+		}
+	}
+	vp.separateAudio = vp.Config.DASH && !vp.noAudio
+	vp.dolby = false
+	if vp.Config.DolbyAudio != "" {
+		if vp.noAudio || vp.silentAudio {
+			vp.Logger.Info("Source has no audio stream, skipping Dolby rendition")
+		} else {
+			vp.dolby = true
+		}
+	}
+	vp.described = nil
+	if vp.Config.AudioDescription != "" && !vp.noAudio && !vp.silentAudio {
+		stream, err := selectDescribedAudio(info, vp.Config.AudioDescription)
+		if err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		if stream == nil {
+			vp.Logger.Info("Source has no audio description track, skipping it")
+		}
+		vp.described = stream
+	}
+	if !vp.SkipDiskCheck {
+		if err := vp.checkDiskSpace(info); err != nil {
+			return err
+		}
+	}
+	vp.estimateCost(info)
+
+	frameRateArgs := append(vp.probeInputArgs(), "-v", "0", "-of", "default=noprint_wrappers=1:nokey=1",
+		"-select_streams", vp.videoSpecifier(), "-show_entries", "stream=avg_frame_rate", vp.sourceFile())
+	frameRateCmd := vp.command(vp.FFprobePath, frameRateArgs...)
+
+	vp.Logger.Debug("Running ffprobe", "command", frameRateCmd.String())
 	frameRateOutput, err := frameRateCmd.Output()
 	if err != nil {
 		vp.Logger.Error("Failed to get frame rate", "error", err)
-		return fmt.Errorf("failed to get frame rate: %w", err)
+		return types.NewExitError(types.ExitProbe, fmt.Errorf("failed to get frame rate: %w", err))
+	}
+
+	frameRate, err := vp.detectFrameRate(string(frameRateOutput))
+	if err != nil {
+		vp.Logger.Error("Failed to determine frame rate", "error", err)
+		return err
+	}
+
+	var keyframeInterval float64
+	if vp.Config.SmartPassthrough {
+		keyframes, err := vp.keyframeTimes()
+		if err != nil {
+			vp.Logger.Error("Failed to read keyframes", "error", err)
+			return types.NewExitError(types.ExitProbe, err)
+		}
+		keyframeInterval = maxKeyframeInterval(keyframes)
 	}
 
-	frameRate := utils.ParseFrameRate(string(frameRateOutput))
-	gopSize := frameRate * vp.Config.SegmentTime
+	checkpoint, err := vp.loadCheckpoint()
+	if err != nil {
+		vp.Logger.Error("Failed to load checkpoint", "error", err)
+		return fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	for _, rendition := range vp.Config.Renditions {
+		outputName := rendition.Name
+		resolution := rendition.Resolution()
+		bitrate := rendition.VideoBitrate
+		level := rendition.Level
+		profile := rendition.Profile
+		if profile == "" {
+			profile = "high"
+		}
+		crf := rendition.CRF
+		if crf == 0 {
+			crf = vp.Config.CRF
+		}
+
+		width, height, scaleFilters, err := vp.renditionScale(rendition.Width, rendition.Height)
+		if err != nil {
+			vp.Logger.Error("Invalid rendition scaling", "output", outputName, "error", err)
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		vp.outputSizes[outputName] = fmt.Sprintf("%dx%d", width, height)
+
+		if rendition.WebM() {
+			vp.outputLevels[outputName] = minimumVP9Level(width, height, frameRate)
+			if checkpoint.rendition(outputName).Completed {
+				vp.Logger.Info("Skipping completed rendition", "resolution", resolution, "output", outputName)
+				continue
+			}
+			filters := vp.videoFilters(rendition, vp.extraArgs(outputName), scaleFilters, height, frameRate)
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(rendition types.Rendition, filters []string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := vp.encodeWebM(checkpoint, rendition, filters, frameRate); err != nil {
+					errChan <- &renditionError{rendition.Name, err}
+				}
+			}(rendition, filters)
+			continue
+		}
+
+		maxrate := rendition.EffectiveMaxRate()
+		bufsize := rendition.EffectiveBufSize()
+		required, err := minimumH264Level(width, height, frameRate, rendition.MaxRateKbps(), rendition.BufSizeKbps(), profile)
+		if err != nil {
+			vp.Logger.Error("Rendition doesn't fit any H.264 level", "output", outputName, "error", err)
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("rendition %s: %w", outputName, err))
+		}
+		switch {
+		case level == "" || level == types.LevelAuto:
+			level = required
+		case maxLevel(level, required) != level && vp.Config.StrictLevels:
+			vp.Logger.Error("Rendition exceeds its H.264 level", "output", outputName, "level", level, "requiredLevel", required)
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("rendition %s needs H.264 level %s or higher but is configured for %s", outputName, required, level))
+		case maxLevel(level, required) != level:
+			vp.Logger.Info("Raising H.264 level to fit rendition", "output", outputName, "size", vp.outputSizes[outputName], "maxrate", maxrate, "level", level, "requiredLevel", required)
+			level = required
+		}
+		vp.outputLevels[outputName] = level
 
-	for i, resolution := range vp.Config.Resolutions {
-		outputName := vp.Config.Outputs[i]
-		bitrate := vp.Config.Bitrates[i]
-		audioRate := vp.Config.AudioRates[i]
-		level := vp.Config.Levels[i]
+		copyVideo := false
+		if vp.Config.SmartPassthrough {
+			if reason := vp.videoPassthroughReason(outputName, width, height, bitrate, level, keyframeInterval); reason != "" {
+				vp.Logger.Debug("Re-encoding rendition", "output", outputName, "reason", reason)
+			} else {
+				vp.Logger.Info("Source matches rendition, passing video through", "output", outputName)
+				copyVideo = true
+			}
+		}
 
-		bitrateValue := utils.ParseBitrate(bitrate)
-		maxrate := fmt.Sprintf("%dk", int(float64(bitrateValue)*1.2))
-		bufsize := fmt.Sprintf("%dk", bitrateValue*2)
+		audioArgs, err := vp.audioArgs(rendition)
+		if err != nil {
+			vp.Logger.Error("Invalid audio settings", "output", outputName, "error", err)
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		switch {
+		case vp.noAudio:
+		case audioArgs[1] == "copy":
+			vp.Logger.Info("Passing source audio through", "output", outputName, "codec", audioStream(vp.source).CodecName)
+		case audioArgs[1] == "libopus":
+			vp.Logger.Warn("Opus audio in MPEG-TS segments is not supported by most HLS players", "output", outputName)
+		}
 
-		playlist := filepath.Join(vp.OutputDir, fmt.Sprintf("%s.m3u8", outputName))
+		playlist := filepath.Join(vp.workingDir(), fmt.Sprintf("%s.m3u8", outputName))
+
+		progress := checkpoint.rendition(outputName)
+		if progress.Completed {
+			vp.Logger.Info("Skipping completed rendition", "resolution", resolution, "output", outputName)
+			continue
+		}
 
 		sem <- struct{}{}
 		wg.Add(1)
@@ -86,84 +563,372 @@ func (vp *VideoProcessor) ProcessVideo() error {
 				wg.Done()
 			}()
 
-			ffmpegCmd := exec.Command("ffmpeg", "-y", "-i", vp.InputFile,
-				"-c:v", "libx264", "-preset", vp.Config.Preset, "-crf", "12", "-profile:v", "high", "-level:v", level,
-				"-s", resolution, "-b:v", bitrate, "-maxrate", maxrate, "-bufsize", bufsize,
-				"-c:a", "aac", "-b:a", audioRate, "-ac", "2",
-				"-g", strconv.Itoa(gopSize), "-keyint_min", strconv.Itoa(gopSize), "-sc_threshold", "0",
-				"-hls_time", "4", "-hls_list_size", "0", "-hls_flags", "independent_segments",
-				"-hls_segment_filename", filepath.Join(vp.OutputDir, fmt.Sprintf("%s_%%03d.ts", outputName)),
-				playlist)
-			if err := ffmpegCmd.Run(); err != nil {
+			var inputArgs []string
+			hlsFlags := vp.hlsFlags()
+			startNumber := 0
+			if progress.Segments > 0 && !vp.Config.SingleFile {
+				offset := strconv.FormatFloat(progress.Offset, 'f', 3, 64)
+				inputArgs = []string{"-ss", offset}
+				hlsFlags = vp.hlsFlags("append_list")
+				startNumber = progress.Segments
+				vp.Logger.Info("Resuming rendition", "resolution", resolution, "segment", startNumber, "offset", offset)
+			} else if progress.Segments > 0 {
+				vp.Logger.Info("Single-file renditions can't be resumed, restarting", "resolution", resolution)
+			}
+
+			extra := vp.extraArgs(outputName)
+			encodeArgs := func(settings encodeSettings) []string {
+				args := append([]string{"-y"}, inputArgs...)
+				args = append(args, vp.resilienceArgs()...)
+				args = append(args, extra.ExtraInputArgs...)
+				args = append(args, vp.windowArgs()...)
+				args = append(args, vp.inputArgs()...)
+				args = append(args, vp.streamMaps()...)
+				if copyVideo {
+					args = append(args, "-c:v", "copy")
+				} else {
+					filters := vp.videoFilters(rendition, extra, scaleFilters, height, frameRate)
+					args = append(args, settings.codecArgs(crf)...)
+					args = append(args,
+						"-profile:v", profile, "-level:v", level,
+						"-vf", strings.Join(filters, ","),
+						"-b:v", bitrate, "-maxrate", maxrate, "-bufsize", bufsize)
+					args = append(args, vp.keyframeArgs(frameRate)...)
+				}
+				if vp.separateAudio {
+					args = append(args, "-an")
+				} else {
+					args = append(args, audioArgs...)
+				}
+				args = append(args,
+					"-hls_time", strconv.Itoa(vp.Config.SegmentTime), "-hls_list_size", "0", "-hls_flags", hlsFlags,
+					"-start_number", strconv.Itoa(startNumber))
+				if startNumber > 0 {
+					args = append(args, "-output_ts_offset", strconv.FormatFloat(progress.Offset, 'f', 3, 64))
+				}
+				args = append(args, extra.ExtraOutputArgs...)
+				args = append(args, vp.segmentArgs(outputName)...)
+				args = append(args, playlist)
+				return args
+			}
+			err := vp.encodeWithRetries(outputName, encodeArgs)
+			if cpErr := checkpoint.update(outputName, playlist, err == nil); cpErr != nil {
+				vp.Logger.Error("Failed to write checkpoint", "output", outputName, "error", cpErr)
+			}
+			if err != nil {
 				vp.Logger.Error("Error processing resolution", "resolution", resolution, "error", err)
-				errChan <- fmt.Errorf("error processing resolution %s: %w", resolution, err)
+				errChan <- &renditionError{outputName, fmt.Errorf("error processing resolution %s: %w", resolution, err)}
 			}
 		}(resolution, outputName, bitrate, maxrate, bufsize, playlist)
 	}
+	if vp.separateAudio {
+		audioArgs, err := vp.audioArgs(vp.Config.Renditions[0])
+		if err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := vp.encodeAudioOnly(checkpoint, audioOutput, audioArgs); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+	if webm := vp.webmRenditions(); len(webm) > 0 && vp.separateAudio {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := vp.encodeWebMAudio(checkpoint, webm[0].AudioBitrate); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+	if vp.surround {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := vp.encodeSurround(checkpoint); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+	if vp.dolby {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := vp.encodeDolby(checkpoint); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+	if vp.described != nil {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := vp.encodeDescribedAudio(checkpoint); err != nil {
+				errChan <- err
+			}
+		}()
+	}
 	wg.Wait()
 	close(errChan)
 
+	var errs []error
 	for err := range errChan {
 		if err != nil {
 			vp.Logger.Error("Error during video processing", "error", err)
-			return fmt.Errorf("error during video processing: %w", err)
+			errs = append(errs, err)
 		}
 	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if err := vp.dropFailedRenditions(errs); err != nil {
+		return fmt.Errorf("error during video processing: %w", err)
+	}
+	return nil
+}
 
-	masterPlaylist := filepath.Join(vp.OutputDir, "playlist.m3u8")
-	vp.Logger.Info("Generating master playlist...", "masterPlaylist", masterPlaylist)
+func (vp *VideoProcessor) WriteReport(path string) error {
+	data, err := json.MarshalIndent(vp.Report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0644)
+}
 
-	if err := vp.GenerateMasterPlaylist(); err != nil {
-		vp.Logger.Error("Failed to generate master playlist", "error", err)
-		return fmt.Errorf("failed to generate master playlist: %w", err)
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return fallback
+}
 
-	vp.Logger.Info("Video processing completed successfully")
-	return nil
+func (vp *VideoProcessor) extraArgs(outputName string) types.ExtraArgs {
+	extra := types.ExtraArgs{
+		ExtraInputArgs:  append([]string{}, vp.Config.ExtraInputArgs...),
+		ExtraOutputArgs: append([]string{}, vp.Config.ExtraOutputArgs...),
+		ExtraFilterArgs: append([]string{}, vp.Config.ExtraFilterArgs...),
+	}
+	if rendition, ok := vp.Config.RenditionExtraArgs[outputName]; ok {
+		extra.ExtraInputArgs = append(extra.ExtraInputArgs, rendition.ExtraInputArgs...)
+		extra.ExtraOutputArgs = append(extra.ExtraOutputArgs, rendition.ExtraOutputArgs...)
+		extra.ExtraFilterArgs = append(extra.ExtraFilterArgs, rendition.ExtraFilterArgs...)
+	}
+	return extra
 }
 
-func (vp *VideoProcessor) UploadToS3() error {
-	return filepath.Walk(vp.OutputDir, func(path string, info os.FileInfo, err error) error {
+func (vp *VideoProcessor) UploadToS3() (err error) {
+	defer vp.runErrorHooks("upload", &err)
+	done := vp.startStage(StageUpload, "")
+	defer func() { done(err) }()
+	if vp.Storage == nil {
+		vp.Storage = vp.NewS3Storage(vp.S3Client, vp.S3Bucket)
+	}
+	if err := storage.ValidateTags(vp.objectTags("")); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	if err := vp.runHooks(HookPreUpload, "", nil); err != nil {
+		return err
+	}
+
+	var segments, variants, masters []string
+	err = filepath.Walk(vp.OutputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			vp.Logger.Error("Error walking through files", "path", path, "error", err)
 			return fmt.Errorf("error walking through files: %w", err)
 		}
-		if info.IsDir() {
+		if info.IsDir() || info.Name() == checkpointFile || info.Name() == utils.OutputManifest || info.Name() == UploadManifestName {
 			return nil
 		}
-
-		relPath, err := filepath.Rel(vp.OutputDir, path)
-		if err != nil {
-			vp.Logger.Error("Failed to calculate relative path", "path", path, "error", err)
-			return fmt.Errorf("failed to calculate relative path: %w", err)
+		if vp.onlyOutputs != nil && !vp.onlyOutputs[vp.outputStem(info.Name())] {
+			return nil
 		}
 
-		newPath := filepath.ToSlash(path)
-
-		file, err := os.Open(path)
-		if err != nil {
-			vp.Logger.Error("Failed to open file", "path", path, "error", err)
-			return fmt.Errorf("failed to open file %s: %w", path, err)
+		switch {
+		case info.Name() == masterPlaylistName || info.Name() == dashManifestName || isMasterPlaylist(path):
+			masters = append(masters, path)
+		case isPlaylist(info.Name()):
+			variants = append(variants, path)
+		default:
+			segments = append(segments, path)
 		}
-		defer file.Close()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		_, err = vp.S3Client.PutObject(context.Background(), &s3.PutObjectInput{
-			Bucket: &vp.S3Bucket,
-			Key:    &newPath,
-			Body:   file,
-		})
-		if err != nil {
-			vp.Logger.Error("Failed to upload file")
-			return fmt.Errorf("failed to upload file %s: %w", relPath, err)
+	manifest := &types.UploadManifest{Storage: vp.Storage.String()}
+	if vp.IdempotencyKey != "" || vp.SkipDuplicates && vp.InputFile != "" {
+		if vp.Report.IdempotencyKey == "" {
+			if vp.Report.IdempotencyKey, err = vp.jobKey(); err != nil {
+				return err
+			}
 		}
+		manifest.IdempotencyKey = vp.Report.IdempotencyKey
+	}
+	previous, err := vp.previousUploads()
+	if err != nil {
 		return err
+	}
+	total := len(segments) + len(variants) + len(masters)
+	vp.unchangedUploads = 0
+	for _, group := range [][]string{segments, variants, masters} {
+		for _, path := range group {
+			if err := vp.control.err(); err != nil {
+				return err
+			}
+			uploaded, err := vp.uploadFile(path)
+			if err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, uploaded)
+			vp.emit(ProgressEvent{Type: "upload", Output: uploaded.Path, Bytes: uploaded.Size, Percent: math.Round(float64(len(manifest.Files))/float64(total)*1000) / 10})
+		}
+	}
+	manifest.Files = mergeUploads(previous, manifest.Files)
+	if err := vp.writeUploadManifest(manifest); err != nil {
+		return err
+	}
+	if vp.DeleteStale {
+		if err := vp.deleteStale(manifest); err != nil {
+			return err
+		}
+	}
+	vp.Logger.Info("Uploaded files", "storage", vp.Storage.String(), "segments", len(segments), "playlists", len(variants)+len(masters), "unchanged", vp.unchangedUploads)
+	if err := vp.runHooks(HookPostUpload, "", nil); err != nil {
+		return err
+	}
+	if vp.DeleteAfterUpload {
+		return vp.deleteLocalOutputs(manifest)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) objectKey(path string) (string, error) {
+	if vp.KeyPrefix == "" {
+		return storageKey(path), nil
+	}
+	relPath, err := filepath.Rel(vp.OutputDir, path)
+	if err != nil {
+		vp.Logger.Error("Failed to calculate relative path", "path", path, "error", err)
+		return "", fmt.Errorf("failed to calculate relative path: %w", err)
+	}
+	return strings.TrimSuffix(vp.KeyPrefix, "/") + "/" + storageKey(relPath), nil
+}
+
+func (vp *VideoProcessor) uploadFile(path string) (types.UploadedFile, error) {
+	key, err := vp.objectKey(path)
+	if err != nil {
+		return types.UploadedFile{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		vp.Logger.Error("Failed to open file", "path", path, "error", err)
+		return types.UploadedFile{}, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		vp.Logger.Error("Failed to read file", "path", path, "error", err)
+		return types.UploadedFile{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	relPath, _ := filepath.Rel(vp.OutputDir, path)
+
+	if remote, ok := vp.remoteFiles[filepath.ToSlash(relPath)]; ok && remote.Key == key && remote.Size == size && remote.SHA256 == sum {
+		vp.Logger.Debug("Skipping unchanged object", "key", key, "bytes", size)
+		vp.unchangedUploads++
+		return remote, nil
+	}
+	if vp.SkipUnchanged {
+		existing, err := vp.Storage.Head(context.Background(), key)
+		switch {
+		case err == nil && existing.Size == size && existing.SHA256 == sum:
+			vp.Logger.Debug("Skipping unchanged object", "key", key, "bytes", size)
+			vp.unchangedUploads++
+			return types.UploadedFile{Path: filepath.ToSlash(relPath), Key: key, Size: size, SHA256: sum, ETag: existing.ETag}, nil
+		case err != nil && !errors.Is(err, storage.ErrNotFound):
+			vp.Logger.Error("Failed to check existing object", "key", key, "error", err)
+			return types.UploadedFile{}, fmt.Errorf("failed to check existing object %s: %w", key, err)
+		}
+	}
+	metadata := map[string]string{}
+	for name, value := range vp.ObjectMetadata {
+		metadata[name] = value
+	}
+	metadata[storage.ChecksumMetadataKey] = sum
+
+	tags := vp.objectTags(path)
+	started := time.Now()
+	result, err := vp.Storage.Put(context.Background(), key, file, storage.PutOptions{
+		IfNoneMatch:  vp.IfNoneMatch,
+		ContentType:  contentType(path),
+		CacheControl: vp.cacheControl(path),
+		Tags:         tags,
+		Metadata:     metadata,
 	})
+	if err != nil {
+		if errors.Is(err, storage.ErrExists) {
+			vp.Logger.Error("Object already exists", "key", key)
+			return types.UploadedFile{}, fmt.Errorf("object %s already exists in %s: %w", key, vp.Storage, err)
+		}
+		vp.Logger.Error("Failed to upload file", "path", path, "error", err)
+		return types.UploadedFile{}, fmt.Errorf("failed to upload file %s: %w", path, err)
+	}
+	vp.Logger.Debug("PutObject", "storage", vp.Storage.String(), "key", key, "bytes", size, "tags", tags,
+		"etag", result.ETag, "duration", time.Since(started))
+
+	return types.UploadedFile{
+		Path:   filepath.ToSlash(relPath),
+		Key:    key,
+		Size:   size,
+		SHA256: sum,
+		ETag:   result.ETag,
+	}, nil
 }
 
 func (vp *VideoProcessor) InitAWSClient() (*s3.Client, error) {
+	cfg, err := vp.LoadAWSConfig()
+	if err != nil {
+		return nil, err
+	}
+	vp.Logger.Info("S3 client initialized successfully")
+
+	return s3.NewFromConfig(cfg), nil
+}
+
+func (vp *VideoProcessor) LoadAWSConfig() (aws.Config, error) {
 	err := godotenv.Load()
 	if err != nil {
-		return nil, fmt.Errorf("error loading .env file: %v", err)
+		return aws.Config{}, fmt.Errorf("error loading .env file: %v", err)
 	}
 
 	accessKey := os.Getenv("AWS_ACCESS_KEY_ID_S3")
@@ -174,28 +939,87 @@ func (vp *VideoProcessor) InitAWSClient() (*s3.Client, error) {
 		config.WithRegion(region),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretAccessKey, "")))
 	if err != nil {
-		return nil, fmt.Errorf("could not load AWS config from env: %v", err)
+		return aws.Config{}, fmt.Errorf("could not load AWS config from env: %v", err)
 	}
-	vp.Logger.Info("S3 client initialized successfully")
-
-	return s3.NewFromConfig(cfg), nil
+	if err := vp.AssumeRole(&cfg); err != nil {
+		return aws.Config{}, err
+	}
+	return cfg, nil
 }
 
 func (vp *VideoProcessor) GenerateMasterPlaylist() error {
-	masterPlaylist := filepath.Join(vp.OutputDir, "playlist.m3u8")
+	masterPlaylist := filepath.Join(vp.workingDir(), masterPlaylistName)
 	vp.Logger.Info("Generating master playlist", "path", masterPlaylist)
 
-	var buffer bytes.Buffer
-	buffer.WriteString("#EXTM3U\n")
-	buffer.WriteString("#EXT-X-VERSION:3\n")
+	playlist, err := vp.masterPlaylist()
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(masterPlaylist, playlist.Render(), 0644)
+}
 
-	for i, playlist := range vp.Config.Outputs {
-		resolution := vp.Config.Resolutions[i]
-		bitrate := vp.Config.Bitrates[i]
-		bandwidth := (utils.ParseBitrate(bitrate) + 128) * 1000
-		buffer.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%s\n", bandwidth, resolution))
-		buffer.WriteString(fmt.Sprintf("%s.m3u8\n", filepath.Base(playlist)))
+func (vp *VideoProcessor) masterPlaylist() (*hls.MasterPlaylist, error) {
+	playlist := &hls.MasterPlaylist{Version: 3}
+	switch {
+	case vp.fragmentedMP4():
+		playlist.Version = 7
+	case vp.Config.SingleFile:
+		playlist.Version = 4
+	}
+	if vp.Sample > 0 {
+		playlist.Tags = append(playlist.Tags, fmt.Sprintf("## SAMPLE ENCODE: %s of %s", vp.sampleLabel(), filepath.Base(vp.InputFile)))
+	}
+	sessionTags, err := vp.sessionTags()
+	if err != nil {
+		return nil, err
+	}
+	playlist.Tags = append(playlist.Tags, sessionTags...)
+	if vp.audioOnly {
+		playlist.Variants = vp.audioVariants()
+		return playlist, nil
+	}
+	if vp.surround || vp.separateAudio || vp.dolby || vp.described != nil {
+		playlist.Renditions = append(playlist.Renditions, vp.audioMedia()...)
+	}
+	if vp.dolby {
+		playlist.Renditions = append(playlist.Renditions, vp.dolbyMedia())
 	}
 
-	return os.WriteFile(masterPlaylist, buffer.Bytes(), 0644)
+	variants, err := vp.masterVariants()
+	if err != nil {
+		return nil, err
+	}
+	for _, rendition := range variants {
+		name := rendition.Name
+		uri := filepath.Base(name) + ".m3u8"
+		resolution := rendition.Resolution()
+		if size, ok := vp.outputSizes[name]; ok {
+			resolution = size
+		}
+		video := vp.variantBandwidth(name, (rendition.VideoKbps()+128)*1000)
+		if !vp.surround && !vp.separateAudio && !vp.dolby && vp.described == nil {
+			variant := video.variant(uri)
+			variant.Resolution = resolution
+			playlist.Variants = append(playlist.Variants, variant)
+			continue
+		}
+		stereo := video
+		if vp.separateAudio {
+			stereo = video.plus(vp.variantBandwidth(audioOutput, rendition.AudioKbps()*1000))
+		}
+		variant := stereo.variant(uri)
+		variant.Resolution, variant.Audio = resolution, "stereo"
+		playlist.Variants = append(playlist.Variants, variant)
+		if vp.surround {
+			variant := video.plus(vp.variantBandwidth(surroundOutput, vp.surroundBandwidth()*1000)).variant(uri)
+			variant.Resolution, variant.Audio = resolution, "surround"
+			playlist.Variants = append(playlist.Variants, variant)
+		}
+		if vp.dolby {
+			variant := video.plus(vp.variantBandwidth(dolbyOutput, vp.dolbyBandwidth()*1000)).variant(uri)
+			variant.Resolution, variant.Codecs, variant.Audio = resolution, vp.dolbyCodecs(name), "dolby"
+			playlist.Variants = append(playlist.Variants, variant)
+		}
+	}
+	return playlist, nil
 }
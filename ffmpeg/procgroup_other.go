@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ffmpeg
+
+import (
+	"os"
+	"os/exec"
+)
+
+func isolateProcess(cmd *exec.Cmd) {}
+
+func killProcess(process *os.Process) error {
+	return process.Kill()
+}
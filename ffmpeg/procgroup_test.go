@@ -0,0 +1,77 @@
+//go:build linux || darwin || freebsd || windows
+
+package ffmpeg
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+const processRoleEnv = "GO_FFMPEG_TEST_PROCESS"
+
+// TestMain lets the test binary stand in for ffmpeg and a process ffmpeg
+// starts, so the process tests need no other tools.
+func TestMain(m *testing.M) {
+	switch os.Getenv(processRoleEnv) {
+	case "parent":
+		child := exec.Command(os.Args[0])
+		child.Env = append(os.Environ(), processRoleEnv+"=child")
+		child.Stdout = os.Stdout
+		if err := child.Start(); err != nil {
+			os.Exit(1)
+		}
+		os.Stdout.WriteString("started\n")
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "child":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestKillProcessStopsChildren(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), processRoleEnv+"=parent")
+	cmd.Stdout = w
+	isolateProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	reader := bufio.NewReader(r)
+	if line, err := reader.ReadString('\n'); err != nil || line != "started\n" {
+		cmd.Process.Kill()
+		t.Fatalf("helper didn't start its child: %q, %v", line, err)
+	}
+
+	if err := killProcess(cmd.Process); err != nil {
+		t.Fatalf("killProcess: %v", err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Error("helper exited cleanly, want it killed")
+	}
+
+	// The child holds the write end of the pipe too, so it only reaches EOF
+	// once the child is gone as well.
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, reader)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("child of the killed process is still running")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd
+
+package ffmpeg
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func isolateProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcess(process *os.Process) error {
+	if err := syscall.Kill(-process.Pid, syscall.SIGKILL); err != nil {
+		return process.Kill()
+	}
+	return nil
+}
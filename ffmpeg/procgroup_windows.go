@@ -0,0 +1,27 @@
+//go:build windows
+
+package ffmpeg
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+func isolateProcess(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// killProcess kills ffmpeg and every process it started. Windows has no
+// process group signal that can't be ignored, so the tree is ended with
+// taskkill, falling back to ffmpeg alone.
+func killProcess(process *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid)).Run(); err != nil {
+		return process.Kill()
+	}
+	return nil
+}
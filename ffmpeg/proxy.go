@@ -0,0 +1,99 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	proxyDir = "proxy"
+
+	ProxyProRes = "prores"
+	ProxyDNxHR  = "dnxhr"
+	ProxyH264   = "h264"
+
+	DefaultProxyHeight = 720
+)
+
+var (
+	ProxyFormats  = []string{ProxyProRes, ProxyDNxHR, ProxyH264}
+	proxyEncoders = map[string][]string{
+		ProxyProRes: {"-c:v", "prores_ks", "-profile:v", "proxy", "-vendor", "apl0", "-pix_fmt", "yuv422p10le"},
+		ProxyDNxHR:  {"-c:v", "dnxhd", "-profile:v", "dnxhr_lb", "-pix_fmt", "yuv422p"},
+		ProxyH264:   {"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p", "-g", "12", "-bf", "0"},
+	}
+)
+
+func ValidateProxy(format string, height int) error {
+	if !slices.Contains(ProxyFormats, format) {
+		return fmt.Errorf("unknown proxy format %q (expected %s)", format, strings.Join(ProxyFormats, ", "))
+	}
+	if height < 0 || height%2 != 0 {
+		return fmt.Errorf("invalid proxy height %d (expected an even number, or 0 for the source height)", height)
+	}
+	return nil
+}
+
+func sourceTimecode(info *types.ProbeInfo) string {
+	for _, stream := range info.Streams {
+		if tc := stream.Tags["timecode"]; tc != "" && (stream.CodecType == "video" || stream.CodecTagString == "tmcd") {
+			return tc
+		}
+	}
+	return info.Format.Tags["timecode"]
+}
+
+func (vp *VideoProcessor) GenerateProxy(format string, height int) error {
+	if err := ValidateProxy(format, height); err != nil {
+		return types.NewExitError(types.ExitValidation, err)
+	}
+	dir := filepath.Join(vp.OutputDir, proxyDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		vp.Logger.Error("Failed to create proxy directory", "dir", dir, "error", err)
+		return fmt.Errorf("failed to create proxy directory: %w", err)
+	}
+	if err := vp.ownOutputDir(proxyDir); err != nil {
+		return fmt.Errorf("failed to record proxy directory: %w", err)
+	}
+	output := filepath.Join(dir, vp.videoID()+".mov")
+
+	if vp.Fake {
+		vp.Logger.Info("Fake mode: writing a synthetic proxy instead of running ffmpeg")
+		return os.WriteFile(output, []byte("fake proxy\n"), 0644)
+	}
+
+	info, err := vp.Probe()
+	if err != nil {
+		return err
+	}
+	vp.source = info
+
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, vp.Config.ExtraInputArgs...)
+	args = append(args, vp.inputArgs()...)
+	args = append(args, "-map", "0:"+vp.videoSpecifier(), "-map", "0:a?", "-map_metadata", "0")
+	args = append(args, proxyEncoders[format]...)
+	if height > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", height))
+	}
+	args = append(args, "-c:a", "pcm_s16le")
+	if timecode := sourceTimecode(info); timecode != "" {
+		vp.Logger.Debug("Keeping source timecode", "timecode", timecode)
+		args = append(args, "-timecode", timecode)
+	}
+	args = append(args, output)
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Info("Generating editing proxy", "output", output, "format", format, "height", height)
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, proxyDir); err != nil {
+		vp.Logger.Error("Error generating proxy", "error", err)
+		return fmt.Errorf("error generating proxy: %w", err)
+	}
+	return nil
+}
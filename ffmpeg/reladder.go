@@ -0,0 +1,191 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+func (vp *VideoProcessor) outputPlaylists() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(vp.workingDir(), "*.m3u8"))
+	if err != nil || vp.onlyOutputs == nil {
+		return paths, err
+	}
+	var filtered []string
+	for _, path := range paths {
+		if vp.onlyOutputs[vp.outputStem(filepath.Base(path))] {
+			filtered = append(filtered, path)
+		}
+	}
+	return filtered, nil
+}
+
+func topVariant(master *hls.MasterPlaylist) hls.Variant {
+	top := master.Variants[0]
+	best, _ := strconv.Atoi(top.Attributes["BANDWIDTH"])
+	for _, variant := range master.Variants[1:] {
+		if bandwidth, _ := strconv.Atoi(variant.Attributes["BANDWIDTH"]); bandwidth > best {
+			top, best = variant, bandwidth
+		}
+	}
+	return top
+}
+
+func (vp *VideoProcessor) Reladder() ([]string, error) {
+	masterPath := filepath.Join(vp.OutputDir, masterPlaylistName)
+	data, err := os.ReadFile(masterPath)
+	if err != nil {
+		vp.Logger.Error("Failed to read master playlist", "path", masterPath, "error", err)
+		return nil, fmt.Errorf("failed to read master playlist: %w", err)
+	}
+	master, err := hls.ParseMaster(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", masterPath, err)
+	}
+	if len(master.Variants) == 0 {
+		return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("%s has no variants", masterPath))
+	}
+	if len(master.Renditions) > 0 || vp.Config.DASH || vp.Config.SurroundAudio || vp.Config.DolbyAudio != "" || vp.Config.AudioDescription != "" {
+		return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("reladder only supports outputs with muxed audio, not separate audio renditions or DASH"))
+	}
+
+	top := topVariant(master)
+	if strings.Contains(top.URI, "://") {
+		return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("top variant %s is remote; pass a local mezzanine instead", top.URI))
+	}
+	topSide := 0
+	if w, h, err := types.ParseResolution(top.Attributes["RESOLUTION"]); err == nil {
+		topSide = min(w, h)
+	}
+	if vp.InputFile == "" {
+		vp.InputFile = filepath.Join(vp.OutputDir, filepath.FromSlash(top.URI))
+	}
+
+	existing := map[string]bool{}
+	for _, variant := range master.Variants {
+		existing[strings.TrimSuffix(path.Base(variant.URI), ".m3u8")] = true
+	}
+	var rungs []types.Rendition
+	for _, rendition := range vp.Config.Renditions {
+		switch {
+		case existing[rendition.Name]:
+			vp.Logger.Debug("Rendition already in the master playlist", "rendition", rendition.Name)
+		case topSide > 0 && min(rendition.Width, rendition.Height) > topSide:
+			vp.Logger.Info("Skipping rendition larger than the source", "rendition", rendition.Name, "resolution", rendition.Resolution(), "source", top.Attributes["RESOLUTION"])
+		default:
+			rungs = append(rungs, rendition)
+		}
+	}
+	if len(rungs) == 0 {
+		vp.Logger.Info("No new renditions to add", "masterPlaylist", masterPath)
+		return nil, nil
+	}
+
+	var added []string
+	vp.onlyOutputs = map[string]bool{}
+	for _, rendition := range rungs {
+		added = append(added, rendition.Name)
+		vp.onlyOutputs[rendition.Name] = true
+	}
+	vp.Logger.Info("Adding renditions", "input", vp.InputFile, "renditions", added)
+
+	vp.Config.Renditions = rungs
+	outputsBefore, err := vp.snapshotOutputs()
+	if err != nil {
+		return nil, err
+	}
+	vp.encodeStarted = time.Now()
+	if vp.Fake {
+		if err := vp.writeFakeRenditions(); err != nil {
+			vp.Logger.Error("Failed to generate fake renditions", "error", err)
+			return nil, fmt.Errorf("failed to generate fake renditions: %w", err)
+		}
+	} else if err := vp.encodeRenditions(); err != nil {
+		return nil, err
+	}
+	for _, rendition := range rungs {
+		if _, failed := vp.Report.FailedRenditions[rendition.Name]; failed {
+			added = slices.DeleteFunc(added, func(name string) bool { return name == rendition.Name })
+			delete(vp.onlyOutputs, rendition.Name)
+		}
+	}
+	rungs = vp.Config.Renditions
+
+	var entries bytes.Buffer
+	for _, rendition := range rungs {
+		resolution := rendition.Resolution()
+		if size, ok := vp.outputSizes[rendition.Name]; ok {
+			resolution = size
+		}
+		variant := vp.variantBandwidth(rendition.Name, (rendition.VideoKbps()+128)*1000).variant(rendition.Name + ".m3u8")
+		variant.Resolution = resolution
+		entries.WriteString(variant.String())
+	}
+	if err := vp.rewriteSegmentURIs(); err != nil {
+		vp.Logger.Error("Failed to rewrite segment URIs", "error", err)
+		return nil, fmt.Errorf("failed to rewrite segment URIs: %w", err)
+	}
+	if err := vp.postProcessPlaylists(); err != nil {
+		vp.Logger.Error("Failed to post-process playlists", "error", err)
+		return nil, fmt.Errorf("failed to post-process playlists: %w", err)
+	}
+
+	merged := append(bytes.TrimRight(data, "\n"), '\n')
+	if err := utils.WriteFileAtomic(masterPath, append(merged, entries.Bytes()...), 0644); err != nil {
+		vp.Logger.Error("Failed to update master playlist", "path", masterPath, "error", err)
+		return nil, fmt.Errorf("failed to update master playlist: %w", err)
+	}
+	vp.onlyOutputs[outputStem(masterPlaylistName)] = true
+	if err := vp.writeOutputManifest(outputsBefore); err != nil {
+		vp.Logger.Error("Failed to write output manifest", "error", err)
+		return nil, fmt.Errorf("failed to write output manifest: %w", err)
+	}
+	vp.Logger.Info("Re-laddered output", "masterPlaylist", masterPath, "added", added)
+	return added, nil
+}
+
+func (vp *VideoProcessor) previousUploads() ([]types.UploadedFile, error) {
+	if vp.onlyOutputs == nil {
+		return nil, nil
+	}
+	path := filepath.Join(vp.OutputDir, UploadManifestName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if vp.DeleteStale {
+			return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("no local %s to merge with; refusing to delete stale objects", UploadManifestName))
+		}
+		vp.Logger.Warn("No previous upload manifest, the new one will only list the added files", "path", path)
+		return nil, nil
+	}
+	manifest, err := ReadUploadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Files, nil
+}
+
+func mergeUploads(previous, uploaded []types.UploadedFile) []types.UploadedFile {
+	if len(previous) == 0 {
+		return uploaded
+	}
+	replaced := map[string]bool{}
+	for _, file := range uploaded {
+		replaced[file.Path] = true
+	}
+	var merged []types.UploadedFile
+	for _, file := range previous {
+		if !replaced[file.Path] {
+			merged = append(merged, file)
+		}
+	}
+	return append(merged, uploaded...)
+}
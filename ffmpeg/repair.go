@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const repairedInputName = "repaired-input.mkv"
+
+func (vp *VideoProcessor) resilienceArgs() []string {
+	var args []string
+	if vp.ExitOnError {
+		args = append(args, "-xerror")
+	}
+	if vp.ErrDetect != "" {
+		args = append(args, "-err_detect", vp.ErrDetect)
+	}
+	if vp.DiscardCorrupt {
+		args = append(args, "-fflags", "+discardcorrupt")
+	}
+	return args
+}
+
+func (vp *VideoProcessor) sourceFile() string {
+	if vp.repaired != "" {
+		return vp.repaired
+	}
+	if vp.composed != "" {
+		return vp.composed
+	}
+	if vp.concatenated != "" {
+		return vp.concatenated
+	}
+	return vp.InputFile
+}
+
+func (vp *VideoProcessor) repairInput() error {
+	repaired := filepath.Join(vp.workingDir(), repairedInputName)
+	repairCmd := vp.command(vp.FFmpegPath, "-y", "-v", "warning",
+		"-err_detect", "ignore_err", "-fflags", "+discardcorrupt+genpts",
+		"-i", vp.sourceFile(), "-map", "0", "-ignore_unknown", "-c", "copy", repaired)
+
+	var stderr bytes.Buffer
+	repairCmd.Stderr = &stderr
+	vp.Logger.Info("Repairing input", "file", vp.InputFile, "repaired", repaired)
+	vp.Logger.Debug("Running ffmpeg", "command", repairCmd.String())
+	if err := repairCmd.Run(); err != nil {
+		vp.Logger.Error("Failed to repair input", "file", vp.InputFile, "error", err, "output", strings.TrimSpace(stderr.String()))
+		return types.NewExitError(types.ExitProbe, fmt.Errorf("failed to repair input %s: %w", vp.InputFile, err))
+	}
+	if problems := strings.Count(strings.TrimSpace(stderr.String()), "\n"); stderr.Len() > 0 {
+		vp.Logger.Warn("Input is damaged, continuing with the repaired copy", "file", vp.InputFile, "problems", problems+1,
+			"output", strings.TrimSpace(stderr.String()))
+	}
+	vp.repaired = repaired
+	return nil
+}
+
+func (vp *VideoProcessor) removeRepairedInput() {
+	if vp.repaired == "" {
+		return
+	}
+	if err := os.Remove(vp.repaired); err != nil && !os.IsNotExist(err) {
+		vp.Logger.Warn("Failed to remove repaired input", "path", vp.repaired, "error", err)
+	}
+	vp.repaired = ""
+}
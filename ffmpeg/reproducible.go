@@ -0,0 +1,101 @@
+package ffmpeg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	ChecksumsName       = "checksums.sha256"
+	ReproducibleThreads = 4
+)
+
+var bitexactArgs = []string{"-map_metadata", "-1", "-map_chapters", "-1", "-fflags", "+bitexact", "-flags:v", "+bitexact", "-flags:a", "+bitexact"}
+
+func (vp *VideoProcessor) validateReproducible() error {
+	if !vp.Config.Reproducible {
+		return nil
+	}
+	switch {
+	case vp.Config.VideoCodec != DefaultVideoCodec:
+		return fmt.Errorf("reproducible output needs the %s encoder, %s output isn't deterministic", DefaultVideoCodec, vp.Config.VideoCodec)
+	case vp.Config.ProgramDateTime != "" || slices.Contains(vp.Config.HLSFlags, "program_date_time"):
+		return fmt.Errorf("reproducible output can't be combined with program date times")
+	case vp.Config.Encrypt:
+		return fmt.Errorf("reproducible output can't be combined with encryption, which uses a random key")
+	}
+	if vp.Threads == 0 {
+		vp.Threads = ReproducibleThreads
+	}
+	return nil
+}
+
+func reproducibleArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	pinned := append(slices.Clone(args[:len(args)-1]), bitexactArgs...)
+	return append(pinned, args[len(args)-1])
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (vp *VideoProcessor) writeChecksums() error {
+	files, err := utils.ReadOutputManifest(vp.OutputDir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, name := range files {
+		switch name {
+		case UploadManifestName, ChecksumsName, checkpointFile:
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(vp.OutputDir, name)); err == nil && info.Mode().IsRegular() {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var checksums strings.Builder
+	for _, name := range names {
+		sum, err := fileChecksum(filepath.Join(vp.OutputDir, name))
+		if err != nil {
+			return fmt.Errorf("failed to checksum %s: %w", name, err)
+		}
+		fmt.Fprintf(&checksums, "%s  %s\n", sum, name)
+	}
+	data := []byte(checksums.String())
+	if err := utils.WriteFileAtomic(filepath.Join(vp.OutputDir, ChecksumsName), data, 0644); err != nil {
+		return err
+	}
+	if !slices.Contains(files, ChecksumsName) {
+		if err := utils.WriteOutputManifest(vp.OutputDir, append(files, ChecksumsName)); err != nil {
+			return err
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	vp.Report.OutputChecksum = hex.EncodeToString(sum[:])
+	vp.Logger.Info("Wrote output checksums", "path", filepath.Join(vp.OutputDir, ChecksumsName), "files", len(names), "outputChecksum", vp.Report.OutputChecksum)
+	return nil
+}
@@ -0,0 +1,109 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+func (vp *VideoProcessor) verifySizes(manifest *types.UploadManifest) error {
+	for _, file := range manifest.Files {
+		size, err := vp.Storage.Stat(context.Background(), file.Key)
+		if err != nil {
+			vp.Logger.Error("Failed to stat uploaded object", "key", file.Key, "error", err)
+			return fmt.Errorf("failed to stat uploaded object %s: %w", file.Key, err)
+		}
+		if size != file.Size {
+			vp.Logger.Error("Uploaded object size mismatch", "key", file.Key, "expectedSize", file.Size, "size", size)
+			return fmt.Errorf("uploaded object %s is %d bytes, expected %d", file.Key, size, file.Size)
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) deleteLocalOutputs(manifest *types.UploadManifest) error {
+	if err := vp.verifySizes(manifest); err != nil {
+		vp.Logger.Warn("Keeping local outputs because the upload could not be verified", "outputDir", vp.OutputDir)
+		return err
+	}
+
+	paths := []string{UploadManifestName, utils.OutputManifest, checkpointFile}
+	for _, file := range manifest.Files {
+		paths = append(paths, filepath.FromSlash(file.Path))
+	}
+	dirs := map[string]bool{}
+	for _, rel := range paths {
+		path := filepath.Join(vp.OutputDir, rel)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			vp.Logger.Error("Failed to delete local output", "path", path, "error", err)
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, dir := range append(sorted, ".") {
+		os.Remove(filepath.Join(vp.OutputDir, dir))
+	}
+	vp.Logger.Info("Deleted local outputs after upload", "outputDir", vp.OutputDir, "files", len(manifest.Files))
+	return nil
+}
+
+func (vp *VideoProcessor) PruneOutputs() error {
+	if vp.KeepOutputs <= 0 {
+		return nil
+	}
+	parent := filepath.Dir(filepath.Clean(vp.OutputDir))
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		vp.Logger.Error("Failed to read output parent directory", "dir", parent, "error", err)
+		return fmt.Errorf("failed to read %s: %w", parent, err)
+	}
+
+	type output struct {
+		path    string
+		modTime time.Time
+	}
+	var outputs []output
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(parent, entry.Name())
+		info, err := os.Stat(filepath.Join(path, utils.OutputManifest))
+		if err != nil {
+			continue
+		}
+		outputs = append(outputs, output{path: path, modTime: info.ModTime()})
+	}
+	if len(outputs) <= vp.KeepOutputs {
+		return nil
+	}
+
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].modTime.After(outputs[j].modTime) })
+	current, _ := filepath.Abs(vp.OutputDir)
+	for _, old := range outputs[vp.KeepOutputs:] {
+		if abs, _ := filepath.Abs(old.path); abs == current {
+			continue
+		}
+		if err := os.RemoveAll(old.path); err != nil {
+			vp.Logger.Error("Failed to prune old output", "dir", old.path, "error", err)
+			return fmt.Errorf("failed to prune %s: %w", old.path, err)
+		}
+		vp.Logger.Info("Pruned old output", "dir", old.path, "finishedAt", old.modTime)
+	}
+	return nil
+}
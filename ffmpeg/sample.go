@@ -0,0 +1,52 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const SampleMiddle = "middle"
+
+func ValidateSampleStart(start string) error {
+	if start == "" || start == SampleMiddle {
+		return nil
+	}
+	if d, err := time.ParseDuration(start); err != nil || d < 0 {
+		return fmt.Errorf("invalid sample start %q (expected a duration such as 90s, or %s)", start, SampleMiddle)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) sampleWindow() (float64, float64) {
+	length := vp.Sample.Seconds()
+	switch vp.SampleStart {
+	case "":
+		return 0, length
+	case SampleMiddle:
+		if vp.source == nil {
+			return 0, length
+		}
+		return math.Max(0, (probeDuration(vp.source)-length)/2), length
+	}
+	start, _ := time.ParseDuration(vp.SampleStart)
+	return start.Seconds(), length
+}
+
+func (vp *VideoProcessor) windowArgs() []string {
+	start, length := vp.trimStart, vp.trimLength
+	if vp.Sample > 0 {
+		sampleStart, sampleLength := vp.sampleWindow()
+		start, length = start+sampleStart, sampleLength
+	}
+	if length <= 0 {
+		return nil
+	}
+	return []string{"-ss", strconv.FormatFloat(start, 'f', 3, 64), "-t", strconv.FormatFloat(length, 'f', 3, 64)}
+}
+
+func (vp *VideoProcessor) sampleLabel() string {
+	start, length := vp.sampleWindow()
+	return fmt.Sprintf("%gs from %gs", length, math.Round(start*1000)/1000)
+}
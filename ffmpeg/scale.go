@@ -0,0 +1,108 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+func rotationOf(stream *types.ProbeStream) int {
+	rotation := 0
+	if rotate, ok := stream.Tags["rotate"]; ok {
+		rotation, _ = strconv.Atoi(rotate)
+	}
+	for _, sideData := range stream.SideDataList {
+		if sideData.Rotation != 0 {
+			rotation = sideData.Rotation
+		}
+	}
+	return rotation
+}
+
+func displaySize(stream *types.ProbeStream) (int, int) {
+	width, height := stream.Width, stream.Height
+	if abs(rotationOf(stream))%180 == 90 {
+		width, height = height, width
+	}
+	return width, height
+}
+
+func fitSize(srcWidth, srcHeight, boxWidth, boxHeight int) (int, int) {
+	scale := math.Min(float64(boxWidth)/float64(srcWidth), float64(boxHeight)/float64(srcHeight))
+	return even(float64(srcWidth) * scale), even(float64(srcHeight) * scale)
+}
+
+func even(v float64) int {
+	return int(math.Round(v/2)) * 2
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+const (
+	ScaleFit     = "fit"
+	ScalePad     = "pad"
+	ScaleCrop    = "crop"
+	ScaleStretch = "stretch"
+)
+
+func sampleAspectRatio(stream *types.ProbeStream) (int, int) {
+	num, den, ok := strings.Cut(stream.SampleAspectRatio, ":")
+	if !ok {
+		return 1, 1
+	}
+	n, errN := strconv.Atoi(num)
+	d, errD := strconv.Atoi(den)
+	if errN != nil || errD != nil || n <= 0 || d <= 0 {
+		return 1, 1
+	}
+	return n, d
+}
+
+func (vp *VideoProcessor) renditionScale(width, height int) (int, int, []string, error) {
+
+	stream := vp.sourceVideo()
+	if stream == nil || stream.Width == 0 || stream.Height == 0 {
+		return width, height, []string{fmt.Sprintf("scale=%d:%d", width, height), "setsar=1"}, nil
+	}
+
+	var filters []string
+	srcWidth, srcHeight := displaySize(stream)
+	if num, den := sampleAspectRatio(stream); num != den {
+		filters = append(filters, "scale=trunc(iw*sar/2)*2:ih", "setsar=1")
+		if abs(rotationOf(stream))%180 == 90 {
+			srcHeight = srcHeight * num / den
+		} else {
+			srcWidth = srcWidth * num / den
+		}
+	}
+	if srcHeight > srcWidth {
+		width, height = height, width
+	}
+
+	switch vp.Config.ScalePolicy {
+	case "", ScaleFit:
+		width, height = fitSize(srcWidth, srcHeight, width, height)
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", width, height))
+	case ScalePad:
+		filters = append(filters,
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", width, height),
+			fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2", width, height))
+	case ScaleCrop:
+		filters = append(filters,
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase", width, height),
+			fmt.Sprintf("crop=%d:%d", width, height))
+	case ScaleStretch:
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", width, height))
+	default:
+		return 0, 0, nil, fmt.Errorf("unknown scale policy %q (expected %s, %s, %s or %s)", vp.Config.ScalePolicy, ScaleFit, ScalePad, ScaleCrop, ScaleStretch)
+	}
+	return width, height, append(filters, "setsar=1"), nil
+}
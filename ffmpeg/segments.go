@@ -0,0 +1,113 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	SegmentTS  = "ts"
+	SegmentM4S = "m4s"
+	SegmentMP4 = "mp4"
+
+	DefaultInitSegmentName = "{output}_init.mp4"
+	DefaultSegmentDigits   = 5
+	maxSegmentDigits       = 9
+)
+
+var SegmentFormats = []string{SegmentTS, SegmentM4S, SegmentMP4}
+
+// validateSegmentFormat checks the segment container and init segment name.
+// The init segment must sit next to its playlist and name its rendition, so
+// renditions don't overwrite each other's.
+func (vp *VideoProcessor) validateSegmentFormat() error {
+	format := vp.Config.SegmentFormat
+	if format != "" && !slices.Contains(SegmentFormats, format) {
+		return fmt.Errorf("unknown segment format %q (expected %s)", format, strings.Join(SegmentFormats, ", "))
+	}
+	if format == SegmentTS && vp.Config.DASH {
+		return fmt.Errorf("DASH needs fMP4 segments; use --segment-format %s or %s", SegmentM4S, SegmentMP4)
+	}
+	if vp.Config.SegmentDigits < 1 || vp.Config.SegmentDigits > maxSegmentDigits {
+		return fmt.Errorf("invalid segment index width %d (expected 1-%d digits)", vp.Config.SegmentDigits, maxSegmentDigits)
+	}
+	name := vp.Config.InitSegmentName
+	if name == "" {
+		return nil
+	}
+	switch {
+	case format == SegmentTS:
+		return fmt.Errorf("MPEG-TS segments have no init segment to name")
+	case strings.Count(name, "{output}") != 1:
+		return fmt.Errorf("init segment name %q must contain {output} once", name)
+	case strings.ContainsAny(name, `/\%`):
+		return fmt.Errorf("init segment name %q can't contain /, \\ or %%", name)
+	case !strings.Contains(strings.Replace(name, "{output}", "", 1), "."):
+		return fmt.Errorf("init segment name %q needs a file extension", name)
+	}
+	return nil
+}
+
+// segmentExtension returns the media segment extension. Sources that need
+// fMP4, such as Dolby Vision or HEVC, get .m4s even when TS was asked for.
+func (vp *VideoProcessor) segmentExtension() string {
+	if !vp.fragmentedMP4() {
+		return "." + SegmentTS
+	}
+	if vp.Config.SegmentFormat == SegmentMP4 {
+		return "." + SegmentMP4
+	}
+	return "." + SegmentM4S
+}
+
+func (vp *VideoProcessor) initSegmentName(outputName string) string {
+	name := vp.Config.InitSegmentName
+	if name == "" {
+		name = DefaultInitSegmentName
+	}
+	return strings.Replace(name, "{output}", outputName, 1)
+}
+
+// outputStem returns the rendition a file in the output belongs to, also for
+// init segments with a custom name.
+func (vp *VideoProcessor) outputStem(name string) string {
+	if pattern := vp.Config.InitSegmentName; pattern != "" {
+		prefix, suffix, _ := strings.Cut(pattern, "{output}")
+		if len(name) > len(prefix)+len(suffix) && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		}
+	}
+	return outputStem(name)
+}
+
+// segmentPattern is the ffmpeg filename pattern of a rendition's segments,
+// e.g. 720_%05d.
+func (vp *VideoProcessor) segmentPattern(outputName string) string {
+	return outputName + "_%0" + strconv.Itoa(vp.Config.SegmentDigits) + "d"
+}
+
+func (vp *VideoProcessor) segmentName(outputName string, index int, ext string) string {
+	return fmt.Sprintf(vp.segmentPattern(outputName), index) + ext
+}
+
+// checkSegmentCount fails before encoding when the source is long enough for
+// segment indexes to outgrow SegmentDigits. ffmpeg would keep counting with
+// wider names, which then sort before the earlier segments.
+func (vp *VideoProcessor) checkSegmentCount() error {
+	duration := vp.progressDuration()
+	if vp.Config.SingleFile || duration <= 0 || vp.Config.SegmentTime <= 0 {
+		return nil
+	}
+	// Segments are cut at the first keyframe after SegmentTime, so they are
+	// never shorter, except the last.
+	expected := int(math.Ceil(duration / float64(vp.Config.SegmentTime)))
+	limit := int(math.Pow10(vp.Config.SegmentDigits))
+	if expected <= limit {
+		return nil
+	}
+	return fmt.Errorf("%.0fs at %ds segments needs about %d segments, more than %d-digit indexes allow (%d); use --segment-digits %d or a longer --segment-time",
+		duration, vp.Config.SegmentTime, expected, vp.Config.SegmentDigits, limit, len(strconv.Itoa(expected-1)))
+}
@@ -0,0 +1,32 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+func (vp *VideoProcessor) sessionTags() ([]string, error) {
+	var tags []string
+	if vp.Config.StartOffset != "" {
+		start := "#EXT-X-START:TIME-OFFSET=" + vp.Config.StartOffset
+		if vp.Config.StartPrecise {
+			start += ",PRECISE=YES"
+		}
+		tags = append(tags, start)
+	}
+	for _, data := range vp.Config.SessionData {
+		if data.JSON == nil {
+			tags = append(tags, fmt.Sprintf("#EXT-X-SESSION-DATA:DATA-ID=\"%s\",VALUE=\"%s\"", data.ID, vp.expandTemplate(data.Value)))
+			continue
+		}
+		name := "session-" + data.ID + ".json"
+		if err := utils.WriteFileAtomic(filepath.Join(vp.workingDir(), name), data.JSON, 0644); err != nil {
+			vp.Logger.Error("Failed to write session data", "dataID", data.ID, "error", err)
+			return nil, fmt.Errorf("failed to write session data %s: %w", data.ID, err)
+		}
+		tags = append(tags, fmt.Sprintf("#EXT-X-SESSION-DATA:DATA-ID=\"%s\",URI=\"%s\"", data.ID, name))
+	}
+	return tags, nil
+}
@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const DefaultStatsInterval = time.Second
+
+func (vp *VideoProcessor) readsProgress() bool {
+	return vp.Progress != nil || vp.StatsInterval > 0 || vp.StallTimeout > 0
+}
+
+func parseKbps(bitrate string) float64 {
+	kbps, err := strconv.ParseFloat(strings.TrimSuffix(bitrate, "kbits/s"), 64)
+	if err != nil {
+		return 0
+	}
+	return kbps
+}
+
+func (vp *VideoProcessor) recordStats(output string, elapsed time.Duration, event ProgressEvent) {
+	speed, _ := strconv.ParseFloat(strings.TrimSuffix(event.Speed, "x"), 64)
+	sample := types.StatsSample{
+		Elapsed:     math.Round(elapsed.Seconds()*10) / 10,
+		OutTime:     math.Round(event.Seconds*1000) / 1000,
+		FPS:         event.FPS,
+		BitrateKbps: parseKbps(event.Bitrate),
+		Q:           event.Q,
+		Speed:       speed,
+	}
+
+	vp.statsMu.Lock()
+	defer vp.statsMu.Unlock()
+	if vp.Report.EncodingStats == nil {
+		vp.Report.EncodingStats = map[string][]types.StatsSample{}
+	}
+	vp.Report.EncodingStats[output] = append(vp.Report.EncodingStats[output], sample)
+}
+
+func (vp *VideoProcessor) WriteStatsCSV(path string) error {
+	vp.statsMu.Lock()
+	defer vp.statsMu.Unlock()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create stats file: %w", err)
+	}
+	outputs := make([]string, 0, len(vp.Report.EncodingStats))
+	for output := range vp.Report.EncodingStats {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"output", "elapsed", "out_time", "fps", "bitrate_kbps", "q", "speed"})
+	for _, output := range outputs {
+		for _, sample := range vp.Report.EncodingStats[output] {
+			writer.Write([]string{
+				output,
+				strconv.FormatFloat(sample.Elapsed, 'f', -1, 64),
+				strconv.FormatFloat(sample.OutTime, 'f', -1, 64),
+				strconv.FormatFloat(sample.FPS, 'f', -1, 64),
+				strconv.FormatFloat(sample.BitrateKbps, 'f', -1, 64),
+				strconv.FormatFloat(sample.Q, 'f', -1, 64),
+				strconv.FormatFloat(sample.Speed, 'f', -1, 64),
+			})
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ffmpeg
+
+import (
+	"errors"
+	"os"
+)
+
+func suspendProcess(process *os.Process) error {
+	return errors.New("pausing is not supported on this platform")
+}
+
+func resumeProcess(process *os.Process) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+
+package ffmpeg
+
+import (
+	"os"
+	"syscall"
+)
+
+// suspendProcess stops ffmpeg's whole process group, like killProcess, so
+// the processes it started pause with it.
+func suspendProcess(process *os.Process) error {
+	if err := syscall.Kill(-process.Pid, syscall.SIGSTOP); err != nil {
+		return process.Signal(syscall.SIGSTOP)
+	}
+	return nil
+}
+
+func resumeProcess(process *os.Process) error {
+	if err := syscall.Kill(-process.Pid, syscall.SIGCONT); err != nil {
+		return process.Signal(syscall.SIGCONT)
+	}
+	return nil
+}
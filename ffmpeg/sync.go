@@ -0,0 +1,145 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".mpd":  "application/dash+xml",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".chk":  "video/webm",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".vtt":  "text/vtt",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".avif": "image/avif",
+	".json": "application/json",
+	".key":  "application/octet-stream",
+}
+
+func contentType(path string) string {
+	return contentTypes[strings.ToLower(filepath.Ext(path))]
+}
+
+func isPlaylist(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".m3u8" || ext == ".mpd" || name == UploadManifestName
+}
+
+func isMasterPlaylist(path string) bool {
+	if strings.ToLower(filepath.Ext(path)) != ".m3u8" {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Contains(data, []byte("#EXT-X-STREAM-INF"))
+}
+
+func (vp *VideoProcessor) cacheControl(path string) string {
+	if isPlaylist(filepath.Base(path)) {
+		return vp.PlaylistCacheControl
+	}
+	return vp.SegmentCacheControl
+}
+
+func objectType(path string) string {
+	name := filepath.Base(path)
+	switch {
+	case name == UploadManifestName:
+		return "manifest"
+	case isPlaylist(name):
+		return "playlist"
+	case filepath.Base(filepath.Dir(path)) == proxyDir:
+		return "proxy"
+	case filepath.Base(filepath.Dir(path)) == thumbnailDir || strings.HasPrefix(contentType(path), "image/"):
+		return "thumbnail"
+	default:
+		return "segment"
+	}
+}
+
+func (vp *VideoProcessor) objectTags(path string) map[string]string {
+	if !vp.TagObjectType {
+		return vp.ObjectTags
+	}
+	tags := map[string]string{"type": objectType(path)}
+	for key, value := range vp.ObjectTags {
+		tags[key] = value
+	}
+	return tags
+}
+
+func ContainsPlaylist(dir string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && isPlaylist(entry.Name()) && entry.Name() != UploadManifestName {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (vp *VideoProcessor) syncPrefix() string {
+	if vp.KeyPrefix != "" {
+		return strings.TrimSuffix(vp.KeyPrefix, "/") + "/"
+	}
+	if key := storageKey(vp.OutputDir); key != "" {
+		return key + "/"
+	}
+	return ""
+}
+
+func (vp *VideoProcessor) deleteStale(manifest *types.UploadManifest) error {
+	keep := map[string]bool{}
+	for _, file := range manifest.Files {
+		keep[file.Key] = true
+	}
+	manifestKey, err := vp.objectKey(filepath.Join(vp.OutputDir, UploadManifestName))
+	if err != nil {
+		return err
+	}
+	keep[manifestKey] = true
+
+	prefix := vp.syncPrefix()
+	if prefix == "" {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("refusing to delete stale objects across the whole bucket; set a key prefix"))
+	}
+	keys, err := vp.Storage.List(context.Background(), prefix)
+	if err != nil {
+		vp.Logger.Error("Failed to list objects", "storage", vp.Storage.String(), "prefix", prefix, "error", err)
+		return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+	}
+	deleted := 0
+	for _, key := range keys {
+		if keep[key] {
+			continue
+		}
+		if err := vp.Storage.Delete(context.Background(), key); err != nil {
+			vp.Logger.Error("Failed to delete object", "key", key, "error", err)
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		vp.Logger.Debug("Deleted stale object", "storage", vp.Storage.String(), "key", key)
+		deleted++
+	}
+	vp.Logger.Info("Deleted stale objects", "storage", vp.Storage.String(), "prefix", prefix, "deleted", deleted)
+	return nil
+}
@@ -0,0 +1,56 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const thumbnailDir = "thumbnails"
+
+func (vp *VideoProcessor) GenerateThumbnails(interval float64, height int) (err error) {
+	done := vp.startStage(StageThumbnails, "")
+	defer func() { done(err) }()
+	if interval <= 0 || height <= 0 {
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid thumbnail interval %g or height %d", interval, height))
+	}
+	dir := filepath.Join(vp.OutputDir, thumbnailDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		vp.Logger.Error("Failed to create thumbnail directory", "dir", dir, "error", err)
+		return fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+	if err := vp.ownOutputDir(thumbnailDir); err != nil {
+		return fmt.Errorf("failed to record thumbnail directory: %w", err)
+	}
+
+	if vp.Fake {
+		vp.Logger.Info("Fake mode: writing synthetic thumbnails instead of running ffmpeg")
+		for i := 1; i <= fakeSegments; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("thumb_%04d.jpg", i))
+			if err := os.WriteFile(path, []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, vp.Config.ExtraInputArgs...)
+	args = append(args, vp.windowArgs()...)
+	args = append(args, vp.inputArgs()...)
+	args = append(args, "-map", "0:"+vp.videoSpecifier(),
+		"-vf", fmt.Sprintf("fps=1/%s,scale=-2:%d", strconv.FormatFloat(interval, 'f', -1, 64), height),
+		"-q:v", "3", filepath.Join(dir, "thumb_%04d.jpg"))
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Info("Generating thumbnails", "dir", dir, "interval", interval, "height", height)
+	vp.Logger.Debug("Running ffmpeg", "command", ffmpegCmd.String())
+	if err := vp.runEncoder(ffmpegCmd, thumbnailDir); err != nil {
+		vp.Logger.Error("Error generating thumbnails", "error", err)
+		return fmt.Errorf("error generating thumbnails: %w", err)
+	}
+	return nil
+}
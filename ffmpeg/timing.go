@@ -0,0 +1,50 @@
+package ffmpeg
+
+import (
+	"math"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	StageProbe      = "probe"
+	StageEncode     = "encode"
+	StagePlaylists  = "playlists"
+	StageThumbnails = "thumbnails"
+	StagePoster     = "poster"
+	StageUpload     = "upload"
+	StageVerify     = "verify"
+)
+
+// startStage logs the start of a stage and returns a function that records
+// its duration in the log and the job report once it has finished. Output
+// names the rendition for per-rendition stages.
+func (vp *VideoProcessor) startStage(stage, output string) func(error) {
+	started := time.Now()
+	attrs := []any{"stage", stage}
+	if output != "" {
+		attrs = append(attrs, "output", output)
+	}
+	vp.Logger.Debug("Stage started", attrs...)
+	return func(err error) {
+		finished := time.Now()
+		seconds := math.Round(finished.Sub(started).Seconds()*1000) / 1000
+		vp.Logger.Info("Stage finished", append(attrs, "duration", finished.Sub(started).Round(time.Millisecond), "failed", err != nil)...)
+
+		vp.statsMu.Lock()
+		defer vp.statsMu.Unlock()
+		vp.Report.Stages = append(vp.Report.Stages, types.StageTiming{
+			Stage:      stage,
+			Output:     output,
+			StartedAt:  started.UTC(),
+			FinishedAt: finished.UTC(),
+			Seconds:    seconds,
+			Failed:     err != nil,
+		})
+		if vp.Report.StageSeconds == nil {
+			vp.Report.StageSeconds = map[string]float64{}
+		}
+		vp.Report.StageSeconds[stage] = math.Round((vp.Report.StageSeconds[stage]+seconds)*1000) / 1000
+	}
+}
@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	DefaultBlackThreshold   = 0.10
+	DefaultSilenceThreshold = "-50dB"
+	DefaultTrimMinDuration  = 500 * time.Millisecond
+
+	edgeTolerance = 0.25
+)
+
+var (
+	blackPattern        = regexp.MustCompile(`black_start:\s*([\d.]+)\s+black_end:\s*([\d.]+)`)
+	silenceStartPattern = regexp.MustCompile(`silence_start:\s*(-?[\d.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end:\s*([\d.]+)`)
+)
+
+type interval struct {
+	start, end float64
+}
+
+func parseDetections(output []byte, duration float64) (black, silence []interval) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	silenceStart := -1.0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := blackPattern.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.ParseFloat(m[1], 64)
+			end, _ := strconv.ParseFloat(m[2], 64)
+			black = append(black, interval{start, end})
+		}
+		if m := silenceStartPattern.FindStringSubmatch(line); m != nil {
+			silenceStart, _ = strconv.ParseFloat(m[1], 64)
+		}
+		if m := silenceEndPattern.FindStringSubmatch(line); m != nil && silenceStart >= -edgeTolerance {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			silence = append(silence, interval{math.Max(0, silenceStart), end})
+			silenceStart = -1
+		}
+	}
+	if silenceStart >= -edgeTolerance {
+		silence = append(silence, interval{math.Max(0, silenceStart), duration})
+	}
+	return black, silence
+}
+
+func leadingEnd(intervals []interval) float64 {
+	for _, i := range intervals {
+		if i.start <= edgeTolerance {
+			return i.end
+		}
+	}
+	return 0
+}
+
+func trailingStart(intervals []interval, duration float64) float64 {
+	for _, i := range intervals {
+		if i.end >= duration-edgeTolerance {
+			return i.start
+		}
+	}
+	return duration
+}
+
+func (vp *VideoProcessor) trimSettings() (float64, string, float64) {
+	black, silence, minDuration := vp.Config.BlackThreshold, vp.Config.SilenceThreshold, vp.Config.TrimMinDuration
+	if black == 0 {
+		black = DefaultBlackThreshold
+	}
+	if silence == "" {
+		silence = DefaultSilenceThreshold
+	}
+	if minDuration == 0 {
+		minDuration = DefaultTrimMinDuration
+	}
+	return black, silence, minDuration.Seconds()
+}
+
+func (vp *VideoProcessor) detectTrim() error {
+	duration := probeDuration(vp.source)
+	if duration <= 0 {
+		vp.Logger.Warn("Source duration is unknown, not trimming black and silence")
+		return nil
+	}
+	blackThreshold, silenceThreshold, minDuration := vp.trimSettings()
+	hasAudio := audioStream(vp.source) != nil
+
+	args := append(vp.resilienceArgs(), "-v", "info", "-nostats")
+	args = append(args, vp.inputArgs()...)
+	args = append(args, "-map", "0:"+vp.videoSpecifier(),
+		"-vf", fmt.Sprintf("scale=320:-2,blackdetect=d=%g:pix_th=%g", minDuration, blackThreshold))
+	if hasAudio {
+		args = append(args, "-map", "0:a:0", "-af", fmt.Sprintf("silencedetect=n=%s:d=%g", silenceThreshold, minDuration))
+	}
+	args = append(args, "-f", "null", "-")
+	cmd := vp.command(vp.FFmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	vp.Logger.Info("Detecting black frames and silence to trim")
+	vp.Logger.Debug("Running ffmpeg", "command", cmd.String())
+	if err := cmd.Run(); err != nil {
+		vp.Logger.Error("Failed to detect black frames and silence", "error", err)
+		return types.NewExitError(types.ExitProbe, fmt.Errorf("failed to detect black frames and silence: %w", err))
+	}
+
+	black, silence := parseDetections(stderr.Bytes(), duration)
+	start, end := leadingEnd(black), trailingStart(black, duration)
+	if hasAudio {
+		start = math.Min(start, leadingEnd(silence))
+		end = math.Max(end, trailingStart(silence, duration))
+	}
+	if end-start < minDuration {
+		vp.Logger.Warn("Source is black and silent throughout, not trimming")
+		return nil
+	}
+	if start == 0 && end == duration {
+		vp.Logger.Info("No leading or trailing black and silence found")
+		return nil
+	}
+
+	vp.trimStart, vp.trimLength = start, end-start
+	vp.source.Format.Duration = strconv.FormatFloat(end-start, 'f', 3, 64)
+	vp.Report.TrimmedStart = math.Round(start*1000) / 1000
+	vp.Report.TrimmedEnd = math.Round((duration-end)*1000) / 1000
+	vp.Logger.Info("Trimming black and silence", "start", vp.Report.TrimmedStart, "end", vp.Report.TrimmedEnd)
+	return nil
+}
@@ -0,0 +1,217 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+type UpdatePlan struct {
+	Added     []string
+	Changed   []string
+	Unchanged []string
+	Removed   []string
+}
+
+func (vp *VideoProcessor) remoteManifest() (*types.UploadManifest, error) {
+	manifestKey, err := vp.objectKey(filepath.Join(vp.OutputDir, UploadManifestName))
+	if err != nil {
+		return nil, err
+	}
+	body, err := vp.Storage.Get(context.Background(), manifestKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("no %s in %s; use upload for the first publish", manifestKey, vp.Storage))
+	}
+	if err != nil {
+		vp.Logger.Error("Failed to read upload manifest", "key", manifestKey, "error", err)
+		return nil, fmt.Errorf("failed to read upload manifest %s: %w", manifestKey, err)
+	}
+	defer body.Close()
+
+	var manifest types.UploadManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse upload manifest %s: %w", manifestKey, err)
+	}
+	return &manifest, nil
+}
+
+// PlanUpdate compares the output directory with the upload manifest already
+// in storage, matching files by path, key, size and SHA-256.
+func (vp *VideoProcessor) PlanUpdate() (*UpdatePlan, error) {
+	remote, err := vp.remoteManifest()
+	if err != nil {
+		return nil, err
+	}
+	vp.remoteFiles = map[string]types.UploadedFile{}
+	for _, file := range remote.Files {
+		vp.remoteFiles[file.Path] = file
+	}
+
+	plan := &UpdatePlan{}
+	local := map[string]bool{}
+	err = filepath.Walk(vp.OutputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walking through files: %w", err)
+		}
+		if info.IsDir() || info.Name() == checkpointFile || info.Name() == utils.OutputManifest || info.Name() == UploadManifestName {
+			return nil
+		}
+		relPath, err := filepath.Rel(vp.OutputDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		local[relPath] = true
+		previous, ok := vp.remoteFiles[relPath]
+		if !ok {
+			plan.Added = append(plan.Added, relPath)
+			return nil
+		}
+		key, err := vp.objectKey(path)
+		if err != nil {
+			return err
+		}
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		if previous.Key == key && previous.Size == info.Size() && previous.SHA256 == sum {
+			plan.Unchanged = append(plan.Unchanged, relPath)
+		} else {
+			plan.Changed = append(plan.Changed, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range remote.Files {
+		if !local[file.Path] {
+			plan.Removed = append(plan.Removed, file.Path)
+		}
+	}
+	slices.Sort(plan.Removed)
+	return plan, nil
+}
+
+// Update uploads only the files that are new or differ from what is already
+// published, so unchanged segments keep their objects and CDN cache entries.
+// Files that are no longer in the output are left in storage, since cached
+// playlists may still reference them, unless DeleteStale is set.
+func (vp *VideoProcessor) Update(dryRun bool) error {
+	if vp.Storage == nil {
+		vp.Storage = vp.NewS3Storage(vp.S3Client, vp.S3Bucket)
+	}
+	plan, err := vp.PlanUpdate()
+	if err != nil {
+		return err
+	}
+	for _, path := range plan.Added {
+		vp.Logger.Info("Added", "path", path)
+	}
+	for _, path := range plan.Changed {
+		vp.logChange(path)
+	}
+	for _, path := range plan.Removed {
+		vp.Logger.Info("Removed", "path", path, "deleted", vp.DeleteStale && !dryRun)
+	}
+	vp.Logger.Info("Compared output with published files", "storage", vp.Storage.String(),
+		"added", len(plan.Added), "changed", len(plan.Changed), "unchanged", len(plan.Unchanged), "removed", len(plan.Removed))
+	if dryRun {
+		return nil
+	}
+	if len(plan.Added) == 0 && len(plan.Changed) == 0 && (len(plan.Removed) == 0 || !vp.DeleteStale) {
+		vp.Logger.Info("Published output is up to date")
+		return nil
+	}
+	return vp.UploadToS3()
+}
+
+func (vp *VideoProcessor) logChange(relPath string) {
+	path := filepath.Join(vp.OutputDir, filepath.FromSlash(relPath))
+	if !isPlaylist(filepath.Base(path)) || filepath.Ext(path) != ".m3u8" {
+		vp.Logger.Warn("Changed under the same URL; CDN caches may serve the old content until it expires", "path", relPath)
+		return
+	}
+	added, removed, err := vp.diffPlaylist(path, vp.remoteFiles[relPath].Key)
+	if err != nil {
+		vp.Logger.Info("Changed", "path", relPath)
+		vp.Logger.Debug("Failed to diff playlist", "path", relPath, "error", err)
+		return
+	}
+	vp.Logger.Info("Changed", "path", relPath, "urisAdded", added, "urisRemoved", removed)
+}
+
+// diffPlaylist counts the segment or variant URIs the local playlist adds to
+// and removes from the published one.
+func (vp *VideoProcessor) diffPlaylist(path, key string) (added, removed int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	body, err := vp.Storage.Get(context.Background(), key)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer body.Close()
+	published, err := io.ReadAll(body)
+	if err != nil {
+		return 0, 0, err
+	}
+	before, err := playlistURIs(published)
+	if err != nil {
+		return 0, 0, err
+	}
+	after, err := playlistURIs(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	for uri := range after {
+		if !before[uri] {
+			added++
+		}
+	}
+	for uri := range before {
+		if !after[uri] {
+			removed++
+		}
+	}
+	return added, removed, nil
+}
+
+func playlistURIs(data []byte) (map[string]bool, error) {
+	uris := map[string]bool{}
+	if hls.IsMaster(data) {
+		master, err := hls.ParseMaster(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, variant := range master.Variants {
+			uris[variant.URI] = true
+		}
+		for _, rendition := range master.Renditions {
+			if uri := rendition.Attributes["URI"]; uri != "" {
+				uris[uri] = true
+			}
+		}
+		return uris, nil
+	}
+	media, err := hls.ParseMedia(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range media.Segments {
+		uris[segment.URI] = true
+	}
+	return uris, nil
+}
@@ -0,0 +1,85 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	minInputDimension = 16
+	maxInputDimension = 8192
+)
+
+var (
+	ErrNoVideoStream     = errors.New("input has no video or audio stream")
+	ErrImageInput        = errors.New("input is a still image")
+	ErrZeroDuration      = errors.New("input has zero duration")
+	ErrEncryptedInput    = errors.New("input is encrypted (DRM-protected)")
+	ErrInvalidResolution = errors.New("input resolution is out of range")
+)
+
+var imageFormats = map[string]bool{
+	"image2":    true,
+	"png_pipe":  true,
+	"jpeg_pipe": true,
+	"webp_pipe": true,
+	"bmp_pipe":  true,
+	"tiff_pipe": true,
+}
+
+var encryptedCodecTags = map[string]bool{
+	"encv": true,
+	"enca": true,
+	"drmi": true,
+	"drms": true,
+}
+
+func (vp *VideoProcessor) ValidateInput(info *types.ProbeInfo) error {
+	if err := validateProbeInfo(info, vp.Config.VideoStream, vp.imageInput()); err != nil {
+		vp.Logger.Error("Unsupported input", "file", vp.InputFile, "format", info.Format.FormatName, "error", err)
+		return types.NewExitError(types.ExitValidation, fmt.Errorf("unsupported input %s: %w", vp.InputFile, err))
+	}
+	return nil
+}
+
+func validateProbeInfo(info *types.ProbeInfo, videoSpec string, allowImages bool) error {
+	for _, stream := range info.Streams {
+		if encryptedCodecTags[stream.CodecTagString] {
+			return fmt.Errorf("%w: stream %d uses %s", ErrEncryptedInput, stream.Index, stream.CodecTagString)
+		}
+		for _, sideData := range stream.SideDataList {
+			if strings.Contains(strings.ToLower(sideData.SideDataType), "encryption") {
+				return fmt.Errorf("%w: stream %d carries %s", ErrEncryptedInput, stream.Index, sideData.SideDataType)
+			}
+		}
+	}
+
+	for _, format := range strings.Split(info.Format.FormatName, ",") {
+		if imageFormats[format] && !allowImages {
+			return fmt.Errorf("%w (%s)", ErrImageInput, info.Format.FormatName)
+		}
+	}
+
+	stream, err := selectVideoStream(info, videoSpec)
+	if err != nil {
+		return err
+	}
+	if stream == nil && audioStream(info) == nil {
+		return ErrNoVideoStream
+	}
+
+	if stream != nil && (stream.Width < minInputDimension || stream.Height < minInputDimension ||
+		stream.Width > maxInputDimension || stream.Height > maxInputDimension) {
+		return fmt.Errorf("%w: %dx%d (supported %d-%d pixels per side)", ErrInvalidResolution,
+			stream.Width, stream.Height, minInputDimension, maxInputDimension)
+	}
+
+	if duration, err := strconv.ParseFloat(info.Format.Duration, 64); err == nil && duration <= 0 {
+		return ErrZeroDuration
+	}
+	return nil
+}
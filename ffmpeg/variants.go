@@ -0,0 +1,110 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const (
+	VariantOrderLadder     = ""
+	VariantOrderDescending = "desc"
+	VariantOrderAscending  = "asc"
+)
+
+func parseResolutionLimit(limit string) (int, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	if lines, ok := strings.CutSuffix(strings.ToLower(limit), "p"); ok {
+		if n, err := strconv.Atoi(lines); err == nil && n > 0 {
+			return n, nil
+		}
+	}
+	w, h, err := types.ParseResolution(limit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resolution %q (expected e.g. 720p or 1280x720)", limit)
+	}
+	return min(w, h), nil
+}
+
+func (vp *VideoProcessor) validateVariantOptions() error {
+	if _, err := parseResolutionLimit(vp.Config.MinResolution); err != nil {
+		return err
+	}
+	if _, err := parseResolutionLimit(vp.Config.MaxResolution); err != nil {
+		return err
+	}
+	switch vp.Config.VariantOrder {
+	case VariantOrderLadder, VariantOrderDescending, VariantOrderAscending:
+		return nil
+	}
+	for _, name := range strings.Split(vp.Config.VariantOrder, ",") {
+		if !slices.ContainsFunc(vp.Config.Renditions, func(r types.Rendition) bool { return r.Name == name }) {
+			return fmt.Errorf("variant order names unknown rendition %q", name)
+		}
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) shortSide(rendition types.Rendition) int {
+	if size, ok := vp.outputSizes[rendition.Name]; ok {
+		if w, h, err := types.ParseResolution(size); err == nil {
+			return min(w, h)
+		}
+	}
+	return min(rendition.Width, rendition.Height)
+}
+
+func (vp *VideoProcessor) masterVariants() ([]types.Rendition, error) {
+	minSide, err := parseResolutionLimit(vp.Config.MinResolution)
+	if err != nil {
+		return nil, err
+	}
+	maxSide, err := parseResolutionLimit(vp.Config.MaxResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var variants []types.Rendition
+	for _, rendition := range vp.Config.Renditions {
+		if rendition.WebM() {
+			continue
+		}
+		side := vp.shortSide(rendition)
+		if (minSide > 0 && side < minSide) || (maxSide > 0 && side > maxSide) {
+			vp.Logger.Info("Leaving rendition out of the master playlist", "rendition", rendition.Name, "resolution", rendition.Resolution())
+			continue
+		}
+		variants = append(variants, rendition)
+	}
+	if len(variants) == 0 {
+		return nil, types.NewExitError(types.ExitValidation, fmt.Errorf("no renditions between --min-resolution %q and --max-resolution %q", vp.Config.MinResolution, vp.Config.MaxResolution))
+	}
+
+	switch vp.Config.VariantOrder {
+	case VariantOrderLadder:
+	case VariantOrderDescending, VariantOrderAscending:
+		sort.SliceStable(variants, func(i, j int) bool {
+			a, b := variants[i].VideoKbps(), variants[j].VideoKbps()
+			if vp.Config.VariantOrder == VariantOrderAscending {
+				return a < b
+			}
+			return a > b
+		})
+	default:
+		order := strings.Split(vp.Config.VariantOrder, ",")
+		rank := func(name string) int {
+			if i := slices.Index(order, name); i >= 0 {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(variants, func(i, j int) bool { return rank(variants[i].Name) < rank(variants[j].Name) })
+	}
+	return variants, nil
+}
@@ -0,0 +1,95 @@
+package ffmpeg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const UploadManifestName = "upload-manifest.json"
+
+func (vp *VideoProcessor) writeUploadManifest(manifest *types.UploadManifest) error {
+	manifest.UploadedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode upload manifest: %w", err)
+	}
+
+	path := filepath.Join(vp.OutputDir, UploadManifestName)
+	if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
+		vp.Logger.Error("Failed to write upload manifest", "path", path, "error", err)
+		return fmt.Errorf("failed to write upload manifest: %w", err)
+	}
+	if _, err := vp.uploadFile(path); err != nil {
+		return err
+	}
+	vp.Logger.Info("Wrote upload manifest", "path", path, "files", len(manifest.Files))
+	return nil
+}
+
+func ReadUploadManifest(path string) (*types.UploadManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload manifest: %w", err)
+	}
+	var manifest types.UploadManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse upload manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+func (vp *VideoProcessor) VerifyUpload(manifest *types.UploadManifest) (err error) {
+	done := vp.startStage(StageVerify, "")
+	defer func() { done(err) }()
+	var missing, corrupted int
+	for _, file := range manifest.Files {
+		size, sum, err := vp.remoteChecksum(file.Key)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			vp.Logger.Warn("Object is missing", "key", file.Key)
+			missing++
+		case err != nil:
+			vp.Logger.Error("Failed to read object", "key", file.Key, "error", err)
+			return fmt.Errorf("failed to read object %s: %w", file.Key, err)
+		case size != file.Size || sum != file.SHA256:
+			vp.Logger.Warn("Object does not match manifest", "key", file.Key,
+				"expectedSize", file.Size, "size", size, "expectedSHA256", file.SHA256, "sha256", sum)
+			corrupted++
+		default:
+			vp.Logger.Debug("Object verified", "key", file.Key, "bytes", size)
+		}
+	}
+
+	vp.Logger.Info("Verified upload", "storage", vp.Storage.String(), "files", len(manifest.Files), "missing", missing, "corrupted", corrupted)
+	if missing > 0 || corrupted > 0 {
+		return fmt.Errorf("%d of %d objects in %s are missing or corrupted", missing+corrupted, len(manifest.Files), vp.Storage)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) remoteChecksum(key string) (int64, string, error) {
+	body, err := vp.Storage.Get(context.Background(), key)
+	if err != nil {
+		return 0, "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, body)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
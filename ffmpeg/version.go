@@ -0,0 +1,119 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+var versionPattern = regexp.MustCompile(`^\S+ version [nN]?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+type ToolVersion struct {
+	Raw           string
+	Major         int
+	Minor         int
+	Patch         int
+	Known         bool
+	Configuration string
+}
+
+func (v ToolVersion) String() string {
+	return v.Raw
+}
+
+func ParseToolVersion(output string) ToolVersion {
+	lines := strings.Split(output, "\n")
+	first := strings.TrimSpace(lines[0])
+
+	var version ToolVersion
+	if fields := strings.Fields(first); len(fields) >= 3 {
+		version.Raw = fields[2]
+	}
+	if m := versionPattern.FindStringSubmatch(first); m != nil {
+		version.Major, _ = strconv.Atoi(m[1])
+		version.Minor, _ = strconv.Atoi(m[2])
+		version.Patch, _ = strconv.Atoi(m[3])
+		version.Known = true
+	}
+	for _, line := range lines {
+		if config, ok := strings.CutPrefix(strings.TrimSpace(line), "configuration:"); ok {
+			version.Configuration = strings.TrimSpace(config)
+			break
+		}
+	}
+	return version
+}
+
+func (v ToolVersion) AtLeast(minimum string) (bool, error) {
+	if minimum == "" {
+		return true, nil
+	}
+	m := versionPattern.FindStringSubmatch("x version " + minimum)
+	if m == nil {
+		return false, fmt.Errorf("invalid minimum version %q", minimum)
+	}
+	want := [3]int{}
+	for i := range want {
+		want[i], _ = strconv.Atoi(m[i+1])
+	}
+	have := [3]int{v.Major, v.Minor, v.Patch}
+	for i := range have {
+		if have[i] != want[i] {
+			return have[i] > want[i], nil
+		}
+	}
+	return true, nil
+}
+
+func (vp *VideoProcessor) toolVersion(path string) (ToolVersion, error) {
+	cmd := vp.command(path, "-version")
+	vp.Logger.Debug("Checking tool version", "command", cmd.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return ToolVersion{}, fmt.Errorf("failed to run %s -version: %w", path, err)
+	}
+	return ParseToolVersion(string(output)), nil
+}
+
+func (vp *VideoProcessor) CheckToolVersions() error {
+	ffmpegVersion, err := vp.toolVersion(vp.FFmpegPath)
+	if err != nil {
+		vp.Logger.Error("Failed to get ffmpeg version", "path", vp.FFmpegPath, "error", err)
+		return types.NewExitError(types.ExitMissingTools, err)
+	}
+	ffprobeVersion, err := vp.toolVersion(vp.FFprobePath)
+	if err != nil {
+		vp.Logger.Error("Failed to get ffprobe version", "path", vp.FFprobePath, "error", err)
+		return types.NewExitError(types.ExitMissingTools, err)
+	}
+
+	vp.Report.FFmpegPath = vp.FFmpegPath
+	vp.Report.FFmpegVersion = ffmpegVersion.Raw
+	vp.Report.FFmpegConfig = ffmpegVersion.Configuration
+	vp.Report.FFprobePath = vp.FFprobePath
+	vp.Report.FFprobeVer = ffprobeVersion.Raw
+	vp.Logger.Info("Detected ffmpeg", "path", vp.FFmpegPath, "version", ffmpegVersion.Raw)
+
+	for _, tool := range []struct {
+		name    string
+		version ToolVersion
+	}{{"ffmpeg", ffmpegVersion}, {"ffprobe", ffprobeVersion}} {
+		if !tool.version.Known {
+			vp.Logger.Warn("Could not parse tool version, skipping minimum version check", "tool", tool.name, "version", tool.version.Raw)
+			continue
+		}
+		ok, err := tool.version.AtLeast(vp.MinFFmpegVersion)
+		if err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		if !ok {
+			vp.Logger.Error("Tool version too old", "tool", tool.name, "version", tool.version.Raw, "minimum", vp.MinFFmpegVersion)
+			return types.NewExitError(types.ExitMissingTools,
+				fmt.Errorf("%s %s is older than the required minimum %s", tool.name, tool.version.Raw, vp.MinFFmpegVersion))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+const VideoStreamBest = "best"
+
+func videoStreams(info *types.ProbeInfo) []*types.ProbeStream {
+	var streams []*types.ProbeStream
+	for i := range info.Streams {
+		if info.Streams[i].CodecType == "video" && info.Streams[i].Disposition["attached_pic"] == 0 {
+			streams = append(streams, &info.Streams[i])
+		}
+	}
+	return streams
+}
+
+func selectVideoStream(info *types.ProbeInfo, spec string) (*types.ProbeStream, error) {
+	streams := videoStreams(info)
+	if len(streams) == 0 {
+		return nil, nil
+	}
+	switch spec {
+	case "":
+		return streams[0], nil
+	case VideoStreamBest:
+		best := streams[0]
+		for _, stream := range streams[1:] {
+			pixels, bestPixels := stream.Width*stream.Height, best.Width*best.Height
+			bitrate, _ := strconv.Atoi(stream.BitRate)
+			bestBitrate, _ := strconv.Atoi(best.BitRate)
+			if pixels > bestPixels || pixels == bestPixels && bitrate > bestBitrate {
+				best = stream
+			}
+		}
+		return best, nil
+	}
+	n, err := strconv.Atoi(spec)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid video stream %q (expected an index or %s)", spec, VideoStreamBest)
+	}
+	if n >= len(streams) {
+		return nil, fmt.Errorf("video stream %d not found (input has %d)", n, len(streams))
+	}
+	return streams[n], nil
+}
+
+func ValidateVideoStream(spec string) error {
+	if spec == "" || spec == VideoStreamBest {
+		return nil
+	}
+	if n, err := strconv.Atoi(spec); err != nil || n < 0 {
+		return fmt.Errorf("%q is not a stream index or %s", spec, VideoStreamBest)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) sourceVideo() *types.ProbeStream {
+	if vp.source == nil {
+		return nil
+	}
+	stream, _ := selectVideoStream(vp.source, vp.Config.VideoStream)
+	return stream
+}
+
+func (vp *VideoProcessor) videoSpecifier() string {
+	if stream := vp.sourceVideo(); stream != nil {
+		return strconv.Itoa(stream.Index)
+	}
+	return "v:0"
+}
+
+func (vp *VideoProcessor) streamMaps() []string {
+	maps := []string{"-map", "0:" + vp.videoSpecifier()}
+	switch {
+	case vp.separateAudio || vp.noAudio:
+	case vp.silentAudio:
+		maps = append(vp.silentAudioArgs(), append(maps, "-map", "1:a:0", "-shortest")...)
+	default:
+		maps = append(maps, "-map", "0:a:0")
+	}
+	return maps
+}
@@ -0,0 +1,137 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/types"
+)
+
+var (
+	ErrTimeout = errors.New("timed out")
+	ErrStalled = errors.New("stalled")
+)
+
+const maxWatchdogInterval = 5 * time.Second
+
+type watchdog struct {
+	mu       sync.Mutex
+	control  *control
+	advanced time.Time
+	// pausedAtAdvance is the total paused time when position last advanced,
+	// so a pause doesn't count as a stall.
+	pausedAtAdvance time.Duration
+	position        float64
+	reason          error
+	stopped         bool
+	done            chan struct{}
+}
+
+func (vp *VideoProcessor) watching() bool {
+	return vp.JobTimeout > 0 || vp.RenditionTimeout > 0 || vp.StallTimeout > 0
+}
+
+// jobDeadline is when the job runs out of time. Time spent paused doesn't
+// count, so the deadline moves back by it.
+func (vp *VideoProcessor) jobDeadline() time.Time {
+	if vp.JobTimeout <= 0 || vp.encodeStarted.IsZero() {
+		return time.Time{}
+	}
+	paused, _ := vp.control.pausedTime()
+	return vp.encodeStarted.Add(vp.JobTimeout + paused - vp.encodePaused)
+}
+
+func (vp *VideoProcessor) checkJobDeadline() error {
+	if deadline := vp.jobDeadline(); !deadline.IsZero() && time.Now().After(deadline) {
+		return types.NewExitError(types.ExitTimeout, fmt.Errorf("job %w after %s", ErrTimeout, vp.JobTimeout))
+	}
+	return nil
+}
+
+// watch kills process when the job or rendition runs out of time, or when
+// ffmpeg's reported position hasn't advanced for StallTimeout. Neither clock
+// runs while encoding is paused. It returns nil when no timeout is
+// configured.
+func (vp *VideoProcessor) watch(process *os.Process, output string) *watchdog {
+	if !vp.watching() {
+		return nil
+	}
+	started := time.Now()
+	startPaused, _ := vp.control.pausedTime()
+	w := &watchdog{control: vp.control, advanced: started, pausedAtAdvance: startPaused, done: make(chan struct{})}
+	deadline := vp.jobDeadline()
+	limit := vp.JobTimeout
+	if vp.RenditionTimeout > 0 && (deadline.IsZero() || started.Add(vp.RenditionTimeout).Before(deadline)) {
+		deadline, limit = started.Add(vp.RenditionTimeout), vp.RenditionTimeout
+	}
+	interval := maxWatchdogInterval
+	if vp.StallTimeout > 0 && vp.StallTimeout/4 < interval {
+		interval = vp.StallTimeout / 4
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case now := <-ticker.C:
+				w.mu.Lock()
+				if w.stopped {
+					w.mu.Unlock()
+					return
+				}
+				paused, isPaused := vp.control.pausedTime()
+				if isPaused {
+					w.mu.Unlock()
+					continue
+				}
+				switch {
+				case !deadline.IsZero() && now.After(deadline.Add(paused-startPaused)):
+					w.reason = types.NewExitError(types.ExitTimeout, fmt.Errorf("ffmpeg for %s %w after %s", output, ErrTimeout, limit))
+				case vp.StallTimeout > 0 && now.Sub(w.advanced)-(paused-w.pausedAtAdvance) > vp.StallTimeout:
+					w.reason = types.NewExitError(types.ExitTimeout, fmt.Errorf("ffmpeg for %s %w at %.1fs: no progress for %s", output, ErrStalled, w.position, vp.StallTimeout))
+				}
+				if w.reason != nil {
+					vp.Logger.Error("Killing ffmpeg", "output", output, "pid", process.Pid, "reason", w.reason)
+					killProcess(process)
+					w.mu.Unlock()
+					return
+				}
+				w.mu.Unlock()
+			}
+		}
+	}()
+	return w
+}
+
+func (w *watchdog) advance(position float64) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if position > w.position {
+		w.position = position
+		w.advanced = time.Now()
+		w.pausedAtAdvance, _ = w.control.pausedTime()
+	}
+}
+
+// stop ends the watch and returns why the process was killed, if it was.
+func (w *watchdog) stop() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.done)
+	}
+	return w.reason
+}
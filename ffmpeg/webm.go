@@ -0,0 +1,298 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/types"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const webmAudioOutput = "audio_webm"
+
+type vp9Level struct {
+	name       string
+	maxPicture int
+	maxRate    int
+}
+
+var vp9Levels = []vp9Level{
+	{"10", 36864, 829440},
+	{"11", 73728, 2764800},
+	{"20", 122880, 4608000},
+	{"21", 245760, 9216000},
+	{"30", 552960, 20736000},
+	{"31", 983040, 36864000},
+	{"40", 2228224, 83558400},
+	{"41", 2228224, 160432128},
+	{"50", 8912896, 311951360},
+	{"51", 8912896, 588251136},
+	{"52", 8912896, 1176502272},
+	{"60", 35651584, 1176502272},
+	{"61", 35651584, 2353004544},
+	{"62", 35651584, 4706009088},
+}
+
+func minimumVP9Level(width, height int, fps float64) string {
+	picture := width * height
+	rate := int(float64(picture) * fps)
+	for _, level := range vp9Levels {
+		if picture <= level.maxPicture && rate <= level.maxRate {
+			return level.name
+		}
+	}
+	return vp9Levels[len(vp9Levels)-1].name
+}
+
+func vp9Codec(level string) string {
+	return fmt.Sprintf("vp09.00.%s.08", level)
+}
+
+func (vp *VideoProcessor) webmRenditions() []types.Rendition {
+	var renditions []types.Rendition
+	for _, rendition := range vp.Config.Renditions {
+		if rendition.WebM() {
+			renditions = append(renditions, rendition)
+		}
+	}
+	return renditions
+}
+
+func (vp *VideoProcessor) validateWebM() error {
+	webm := vp.webmRenditions()
+	if len(webm) == 0 || vp.PackageOnly {
+		return nil
+	}
+	switch {
+	case !vp.Config.DASH:
+		return fmt.Errorf("VP9 rendition %s is only delivered over DASH and needs --dash", webm[0].Name)
+	case len(webm) == len(vp.Config.Renditions):
+		return fmt.Errorf("at least one H.264 rendition is needed for the HLS master playlist")
+	case vp.Config.SingleFile:
+		return fmt.Errorf("VP9 renditions can't be written with --single-file")
+	case vp.Config.Reproducible:
+		return fmt.Errorf("reproducible output needs %s, VP9 rendition %s isn't deterministic", DefaultVideoCodec, webm[0].Name)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) webmChunkArgs(outputName string) []string {
+	return []string{
+		"-f", "webm_chunk",
+		"-header", filepath.Join(vp.workingDir(), outputName+"_init.webm"),
+		"-chunk_start_index", "0",
+		filepath.Join(vp.workingDir(), vp.segmentPattern(outputName)+".chk"),
+	}
+}
+
+func (vp *VideoProcessor) encodeWebM(checkpoint *Checkpoint, rendition types.Rendition, filters []string, fps float64) error {
+	outputName := rendition.Name
+	if checkpoint.rendition(outputName).Segments > 0 {
+		vp.Logger.Info("WebM renditions can't be resumed, restarting", "output", outputName)
+	}
+
+	extra := vp.extraArgs(outputName)
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, extra.ExtraInputArgs...)
+	args = append(args, vp.windowArgs()...)
+	args = append(args, vp.inputArgs()...)
+	args = append(args, "-map", "0:"+vp.videoSpecifier(), "-an",
+		"-c:v", "libvpx-vp9", "-deadline", "good", "-cpu-used", "2", "-row-mt", "1",
+		"-vf", strings.Join(filters, ","), "-pix_fmt", "yuv420p",
+		"-b:v", rendition.VideoBitrate, "-maxrate", rendition.EffectiveMaxRate(), "-bufsize", rendition.EffectiveBufSize())
+	args = append(args, vp.keyframeArgs(fps)...)
+	args = append(args, extra.ExtraOutputArgs...)
+	args = append(args, vp.webmChunkArgs(outputName)...)
+
+	done := vp.startStage(StageEncode, outputName)
+	err := vp.runRetryingEncoder(args, outputName, vp.Threads)
+	if err == nil {
+		err = vp.writeWebMIndex(outputName)
+	}
+	done(err)
+	return vp.finishWebM(checkpoint, outputName, err)
+}
+
+func (vp *VideoProcessor) encodeWebMAudio(checkpoint *Checkpoint, bitrate string) error {
+	outputName := webmAudioOutput
+	if checkpoint.rendition(outputName).Completed {
+		vp.Logger.Info("Skipping completed rendition", "output", outputName)
+		return nil
+	}
+
+	args := append([]string{"-y"}, vp.resilienceArgs()...)
+	args = append(args, vp.Config.ExtraInputArgs...)
+	args = append(args, vp.windowArgs()...)
+	args = append(args, vp.inputArgs()...)
+	if vp.silentAudio {
+		args = append(args, vp.silentAudioArgs()...)
+		args = append(args, "-map", "1:a:0", "-t", strconv.FormatFloat(vp.progressDuration(), 'f', 3, 64))
+	} else {
+		args = append(args, "-map", "0:a:0")
+	}
+	args = append(args, "-vn", "-c:a", "libopus", "-b:a", bitrate, "-ac", "2", "-ar", "48000",
+		"-audio_chunk_duration", strconv.Itoa(vp.Config.SegmentTime*1000))
+	args = append(args, vp.webmChunkArgs(outputName)...)
+
+	ffmpegCmd := vp.encoderCommand(args...)
+	vp.Logger.Debug("Running ffmpeg", "output", outputName, "command", ffmpegCmd.String())
+	done := vp.startStage(StageEncode, outputName)
+	err := vp.runEncoder(ffmpegCmd, outputName)
+	if err == nil {
+		err = vp.writeWebMIndex(outputName)
+	}
+	done(err)
+	return vp.finishWebM(checkpoint, outputName, err)
+}
+
+func (vp *VideoProcessor) finishWebM(checkpoint *Checkpoint, outputName string, err error) error {
+	playlist := filepath.Join(vp.workingDir(), outputName+".m3u8")
+	if cpErr := checkpoint.update(outputName, playlist, err == nil); cpErr != nil {
+		vp.Logger.Error("Failed to write checkpoint", "output", outputName, "error", cpErr)
+	}
+	if err != nil {
+		vp.Logger.Error("Error processing WebM rendition", "output", outputName, "error", err)
+		return fmt.Errorf("error processing WebM rendition %s: %w", outputName, err)
+	}
+	return nil
+}
+
+func (vp *VideoProcessor) webmChunks(outputName string) []string {
+	var chunks []string
+	for i := 0; ; i++ {
+		path := filepath.Join(vp.workingDir(), vp.segmentName(outputName, i, ".chk"))
+		if _, err := os.Stat(path); err != nil {
+			return chunks
+		}
+		chunks = append(chunks, path)
+	}
+}
+
+func (vp *VideoProcessor) writeWebMIndex(outputName string) error {
+	header := filepath.Join(vp.workingDir(), outputName+"_init.webm")
+	chunks := vp.webmChunks(outputName)
+	if len(chunks) == 0 {
+		return fmt.Errorf("no WebM chunks were written for %s", outputName)
+	}
+	durations, err := vp.chunkDurations(append([]string{header}, chunks...))
+	if err != nil {
+		return err
+	}
+
+	var target float64
+	for _, d := range durations {
+		target = math.Max(target, d)
+	}
+	var playlist bytes.Buffer
+	playlist.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n")
+	playlist.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target))))
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+	playlist.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=\"%s\"\n", filepath.Base(header)))
+	for i, chunk := range chunks {
+		playlist.WriteString(fmt.Sprintf("#EXTINF:%.6f,\n%s\n", durations[i], filepath.Base(chunk)))
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+	return utils.WriteFileAtomic(filepath.Join(vp.workingDir(), outputName+".m3u8"), playlist.Bytes(), 0644)
+}
+
+type chainedFiles struct {
+	paths   []string
+	current *os.File
+}
+
+func (c *chainedFiles) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.paths) == 0 {
+				return 0, io.EOF
+			}
+			file, err := os.Open(c.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			c.current, c.paths = file, c.paths[1:]
+		}
+		n, err := c.current.Read(p)
+		if err == io.EOF {
+			c.current.Close()
+			c.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (vp *VideoProcessor) chunkDurations(files []string) ([]float64, error) {
+	ends := make([]int64, len(files))
+	var offset int64
+	for i, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		offset += info.Size()
+		ends[i] = offset
+	}
+
+	cmd := vp.command(vp.FFprobePath, "-v", "error", "-show_entries", "packet=pts_time,duration_time,pos",
+		"-of", "csv=p=0", "-i", "pipe:0")
+	cmd.Stdin = &chainedFiles{paths: files}
+	vp.Logger.Debug("Running ffprobe", "command", cmd.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WebM chunk timing: %w", err)
+	}
+
+	chunks := len(files) - 1
+	starts := make([]float64, chunks)
+	for i := range starts {
+		starts[i] = math.Inf(1)
+	}
+	var end float64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		pts, errPTS := strconv.ParseFloat(fields[0], 64)
+		duration, _ := strconv.ParseFloat(fields[1], 64)
+		pos, errPos := strconv.ParseInt(fields[2], 10, 64)
+		if errPTS != nil || errPos != nil {
+			continue
+		}
+		file := sort.Search(len(ends), func(i int) bool { return pos < ends[i] })
+		if file == 0 || file > chunks {
+			continue
+		}
+		starts[file-1] = math.Min(starts[file-1], pts)
+		end = math.Max(end, pts+duration)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	durations := make([]float64, chunks)
+	for i := range starts {
+		if math.IsInf(starts[i], 1) {
+			return nil, fmt.Errorf("WebM chunk %s has no packets", filepath.Base(files[i+1]))
+		}
+		next := end
+		if i+1 < chunks {
+			next = starts[i+1]
+		}
+		durations[i] = next - starts[i]
+	}
+	return durations, nil
+}
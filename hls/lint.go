@@ -0,0 +1,314 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/storage"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type Finding struct {
+	Severity string
+	File     string
+	Line     int
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s: %s:%d: %s", f.Severity, f.File, f.Line, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.File, f.Message)
+}
+
+type Source interface {
+	Read(name string) ([]byte, error)
+	Exists(name string) (bool, error)
+	String() string
+}
+
+type DirSource struct {
+	Dir string
+}
+
+func (d DirSource) Read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(d.Dir, filepath.FromSlash(name)))
+}
+
+func (d DirSource) Exists(name string) (bool, error) {
+	_, err := os.Stat(filepath.Join(d.Dir, filepath.FromSlash(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d DirSource) String() string {
+	return d.Dir
+}
+
+type StorageSource struct {
+	Storage storage.Storage
+	Prefix  string
+}
+
+func (s StorageSource) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(s.Prefix, "/") + "/" + name
+}
+
+func (s StorageSource) Read(name string) ([]byte, error) {
+	body, err := s.Storage.Get(context.Background(), s.key(name))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (s StorageSource) Exists(name string) (bool, error) {
+	_, err := s.Storage.Stat(context.Background(), s.key(name))
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s StorageSource) String() string {
+	return s.Storage.String() + "/" + s.Prefix
+}
+
+var (
+	avcCodec  = regexp.MustCompile(`^(avc1|avc3)\.[0-9A-Fa-f]{6}$`)
+	hevcCodec = regexp.MustCompile(`^(hvc1|hev1)\.[0-9A-Za-z.]+$`)
+	mp4aCodec = regexp.MustCompile(`^mp4a\.40\.(2|5|29|34)$`)
+
+	resolutionPattern = regexp.MustCompile(`^\d+x\d+$`)
+)
+
+var simpleCodecs = map[string]bool{"ac-3": true, "ec-3": true, "opus": true, "fLaC": true, "wvtt": true, "stpp.ttml.im1t": true}
+
+func validCodec(codec string) bool {
+	return avcCodec.MatchString(codec) || hevcCodec.MatchString(codec) || mp4aCodec.MatchString(codec) ||
+		strings.HasPrefix(codec, "vp09.") || strings.HasPrefix(codec, "av01.") || simpleCodecs[codec]
+}
+
+func isAudioCodec(codec string) bool {
+	return strings.HasPrefix(codec, "mp4a.") || codec == "ac-3" || codec == "ec-3" || codec == "opus" || codec == "fLaC"
+}
+
+type linter struct {
+	source   Source
+	findings []Finding
+}
+
+func (l *linter) add(severity, file string, line int, format string, args ...any) {
+	l.findings = append(l.findings, Finding{Severity: severity, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func resolve(base, uri string) string {
+	return path.Join(path.Dir(base), uri)
+}
+
+func isRemote(uri string) bool {
+	return strings.Contains(uri, "://")
+}
+
+func Lint(source Source, master string) ([]Finding, error) {
+	l := &linter{source: source}
+	data, err := source.Read(master)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", master, err)
+	}
+	if !IsMaster(data) {
+		l.lintMedia(master, data)
+		return l.findings, nil
+	}
+
+	playlist, err := ParseMaster(data)
+	if err != nil {
+		l.add(SeverityError, master, 0, "%v", err)
+		return l.findings, nil
+	}
+	if len(playlist.Variants) == 0 {
+		l.add(SeverityError, master, 0, "master playlist has no variants")
+	}
+
+	audioGroups := map[string]bool{}
+	for _, rendition := range playlist.Renditions {
+		for _, attr := range []string{"TYPE", "GROUP-ID", "NAME"} {
+			if rendition.Attributes[attr] == "" {
+				l.add(SeverityError, master, rendition.Line, "EXT-X-MEDIA is missing %s", attr)
+			}
+		}
+		if rendition.Attributes["TYPE"] == "AUDIO" {
+			audioGroups[rendition.Attributes["GROUP-ID"]] = true
+		}
+	}
+
+	versions := map[int][]string{}
+	durations := map[string]float64{}
+	linted := map[string]*MediaPlaylist{}
+	lintOnce := func(uri string, line int) *MediaPlaylist {
+		if isRemote(uri) {
+			return nil
+		}
+		name := resolve(master, uri)
+		if media, ok := linted[name]; ok {
+			return media
+		}
+		media := l.readMedia(master, line, name)
+		linted[name] = media
+		if media != nil {
+			versions[media.Version] = append(versions[media.Version], name)
+		}
+		return media
+	}
+
+	for _, rendition := range playlist.Renditions {
+		if uri := rendition.Attributes["URI"]; uri != "" {
+			lintOnce(uri, rendition.Line)
+		}
+	}
+
+	for _, variant := range playlist.Variants {
+		if variant.Attributes["BANDWIDTH"] == "" {
+			l.add(SeverityError, master, variant.Line, "EXT-X-STREAM-INF is missing BANDWIDTH")
+		}
+		if resolution := variant.Attributes["RESOLUTION"]; resolution != "" && !resolutionPattern.MatchString(resolution) {
+			l.add(SeverityError, master, variant.Line, "invalid RESOLUTION %q", resolution)
+		}
+		group := variant.Attributes["AUDIO"]
+		if group != "" && !audioGroups[group] {
+			l.add(SeverityError, master, variant.Line, "AUDIO group %q is not defined by any EXT-X-MEDIA", group)
+		}
+
+		codecs := variant.Attributes["CODECS"]
+		hasAudioCodec := false
+		for _, codec := range strings.Split(codecs, ",") {
+			codec = strings.TrimSpace(codec)
+			if codec == "" {
+				continue
+			}
+			if !validCodec(codec) {
+				l.add(SeverityError, master, variant.Line, "invalid codec %q in CODECS", codec)
+			}
+			hasAudioCodec = hasAudioCodec || isAudioCodec(codec)
+		}
+		if codecs != "" && group != "" && !hasAudioCodec {
+			l.add(SeverityWarning, master, variant.Line, "CODECS should include the audio codec of group %q", group)
+		}
+
+		media := lintOnce(variant.URI, variant.Line)
+		if media == nil {
+			continue
+		}
+		if codecs == "" && media.Map != "" {
+			l.add(SeverityWarning, master, variant.Line, "CODECS is recommended for fMP4 variants")
+		}
+		var total float64
+		for _, segment := range media.Segments {
+			total += segment.Duration
+		}
+		durations[resolve(master, variant.URI)] = total
+	}
+
+	if len(versions) > 1 {
+		var summary []string
+		for version, names := range versions {
+			summary = append(summary, fmt.Sprintf("%d (%s)", version, strings.Join(names, ", ")))
+		}
+		l.add(SeverityWarning, master, 0, "variant playlists use mismatched versions: %s", strings.Join(summary, "; "))
+	}
+
+	var shortest, longest float64 = math.MaxFloat64, 0
+	for _, total := range durations {
+		shortest = math.Min(shortest, total)
+		longest = math.Max(longest, total)
+	}
+	if len(durations) > 1 && longest-shortest > 1 {
+		l.add(SeverityWarning, master, 0, "variant durations differ by %.3fs (%.3fs to %.3fs)", longest-shortest, shortest, longest)
+	}
+	return l.findings, nil
+}
+
+func (l *linter) readMedia(master string, line int, name string) *MediaPlaylist {
+	data, err := l.source.Read(name)
+	if err != nil {
+		l.add(SeverityError, master, line, "cannot read %s: %v", name, err)
+		return nil
+	}
+	return l.lintMedia(name, data)
+}
+
+func (l *linter) lintMedia(name string, data []byte) *MediaPlaylist {
+	playlist, err := ParseMedia(data)
+	if err != nil {
+		l.add(SeverityError, name, 0, "%v", err)
+		return nil
+	}
+
+	if playlist.TargetDuration == 0 {
+		l.add(SeverityError, name, 0, "EXT-X-TARGETDURATION is missing")
+	}
+	if playlist.PlaylistType == "VOD" && !playlist.EndList {
+		l.add(SeverityError, name, 0, "VOD playlist has no EXT-X-ENDLIST")
+	}
+	if len(playlist.Segments) == 0 {
+		l.add(SeverityError, name, 0, "playlist has no segments")
+	}
+
+	required := 1
+	if playlist.Map != "" {
+		required = 6
+		l.checkExists(name, 0, playlist.Map)
+	}
+
+	var total float64
+	for _, segment := range playlist.Segments {
+		total += segment.Duration
+		if playlist.TargetDuration > 0 && int(math.Round(segment.Duration)) > playlist.TargetDuration {
+			l.add(SeverityError, name, segment.Line, "EXTINF %.3f exceeds EXT-X-TARGETDURATION %d", segment.Duration, playlist.TargetDuration)
+		}
+		if segment.Duration != math.Trunc(segment.Duration) && required < 3 {
+			required = 3
+		}
+		if segment.ByteRange != "" && required < 4 {
+			required = 4
+		}
+		l.checkExists(name, segment.Line, segment.URI)
+	}
+	if version := max(playlist.Version, 1); version < required {
+		l.add(SeverityError, name, 0, "EXT-X-VERSION %d is too low for the features used (need %d)", version, required)
+	}
+	return playlist
+}
+
+func (l *linter) checkExists(playlist string, line int, uri string) {
+	if isRemote(uri) {
+		return
+	}
+	name := resolve(playlist, uri)
+	ok, err := l.source.Exists(name)
+	switch {
+	case err != nil:
+		l.add(SeverityError, playlist, line, "cannot check %s: %v", name, err)
+	case !ok:
+		l.add(SeverityError, playlist, line, "missing %s", name)
+	}
+}
@@ -0,0 +1,234 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Variant is an EXT-X-STREAM-INF entry. Attributes holds every attribute as
+// parsed; the typed fields take precedence over it when rendering, and
+// attributes without a field are written after them.
+type Variant struct {
+	URI              string
+	Bandwidth        int
+	AverageBandwidth int
+	Resolution       string
+	Codecs           string
+	FrameRate        string
+	VideoRange       string
+	HDCPLevel        string
+	Audio            string
+	Video            string
+	Subtitles        string
+	ClosedCaptions   string
+	Attributes       map[string]string
+	Line             int
+}
+
+// MediaRendition is an EXT-X-MEDIA entry, such as an alternative audio track.
+// Attributes works as for Variant.
+type MediaRendition struct {
+	Type            string
+	GroupID         string
+	Language        string
+	AssocLanguage   string
+	Name            string
+	Default         bool
+	Autoselect      bool
+	Forced          bool
+	InstreamID      string
+	Characteristics string
+	Channels        string
+	URI             string
+	Attributes      map[string]string
+	Line            int
+}
+
+// MasterPlaylist is a multivariant playlist. Tags holds the other tags and
+// comments, such as EXT-X-START or EXT-X-SESSION-DATA, verbatim and in
+// order; they are rendered before the renditions.
+type MasterPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	Tags                []string
+	Renditions          []MediaRendition
+	Variants            []Variant
+}
+
+type attribute struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+var (
+	variantKeys   = []string{"BANDWIDTH", "AVERAGE-BANDWIDTH", "RESOLUTION", "CODECS", "FRAME-RATE", "VIDEO-RANGE", "HDCP-LEVEL", "AUDIO", "VIDEO", "SUBTITLES", "CLOSED-CAPTIONS"}
+	renditionKeys = []string{"TYPE", "GROUP-ID", "LANGUAGE", "ASSOC-LANGUAGE", "NAME", "DEFAULT", "AUTOSELECT", "FORCED", "INSTREAM-ID", "CHARACTERISTICS", "CHANNELS", "URI"}
+
+	unquotedAttributes = map[string]bool{
+		"TYPE": true, "DEFAULT": true, "AUTOSELECT": true, "FORCED": true,
+		"BANDWIDTH": true, "AVERAGE-BANDWIDTH": true, "RESOLUTION": true, "FRAME-RATE": true,
+		"VIDEO-RANGE": true, "HDCP-LEVEL": true, "SCORE": true, "BIT-DEPTH": true, "SAMPLE-RATE": true,
+	}
+	numericValue = regexp.MustCompile(`^(\d+(\.\d+)?|0[xX][0-9A-Fa-f]+)$`)
+)
+
+// quoted reports whether an attribute without a typed field is written as a
+// quoted string. Client attributes (X-) are quoted unless they are numbers.
+func quoted(key, value string) bool {
+	if unquotedAttributes[key] {
+		return false
+	}
+	return !strings.HasPrefix(key, "X-") || !numericValue.MatchString(value)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
+
+func renderAttributes(typed []attribute, known []string, all map[string]string) string {
+	var parts []string
+	for _, attr := range typed {
+		if attr.value == "" {
+			continue
+		}
+		if attr.quoted {
+			parts = append(parts, fmt.Sprintf(`%s="%s"`, attr.key, attr.value))
+		} else {
+			parts = append(parts, attr.key+"="+attr.value)
+		}
+	}
+	var extra []string
+	for key := range all {
+		if !slices.Contains(known, key) {
+			extra = append(extra, key)
+		}
+	}
+	slices.Sort(extra)
+	for _, key := range extra {
+		if quoted(key, all[key]) {
+			parts = append(parts, fmt.Sprintf(`%s="%s"`, key, all[key]))
+		} else {
+			parts = append(parts, key+"="+all[key])
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func positive(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// String renders the EXT-X-STREAM-INF tag and URI line.
+func (v Variant) String() string {
+	typed := []attribute{
+		{"BANDWIDTH", positive(v.Bandwidth), false},
+		{"AVERAGE-BANDWIDTH", positive(v.AverageBandwidth), false},
+		{"RESOLUTION", v.Resolution, false},
+		{"CODECS", v.Codecs, true},
+		{"FRAME-RATE", v.FrameRate, false},
+		{"VIDEO-RANGE", v.VideoRange, false},
+		{"HDCP-LEVEL", v.HDCPLevel, false},
+		{"AUDIO", v.Audio, true},
+		{"VIDEO", v.Video, true},
+		{"SUBTITLES", v.Subtitles, true},
+		{"CLOSED-CAPTIONS", v.ClosedCaptions, v.ClosedCaptions != "NONE"},
+	}
+	return fmt.Sprintf("#EXT-X-STREAM-INF:%s\n%s\n", renderAttributes(typed, variantKeys, v.Attributes), v.URI)
+}
+
+// String renders the EXT-X-MEDIA tag. DEFAULT and AUTOSELECT are always
+// written; FORCED only when set.
+func (r MediaRendition) String() string {
+	forced := ""
+	if r.Forced {
+		forced = "YES"
+	}
+	typed := []attribute{
+		{"TYPE", r.Type, false},
+		{"GROUP-ID", r.GroupID, true},
+		{"LANGUAGE", r.Language, true},
+		{"ASSOC-LANGUAGE", r.AssocLanguage, true},
+		{"NAME", r.Name, true},
+		{"DEFAULT", yesNo(r.Default), false},
+		{"AUTOSELECT", yesNo(r.Autoselect), false},
+		{"FORCED", forced, false},
+		{"INSTREAM-ID", r.InstreamID, true},
+		{"CHARACTERISTICS", r.Characteristics, true},
+		{"CHANNELS", r.Channels, true},
+		{"URI", r.URI, true},
+	}
+	return fmt.Sprintf("#EXT-X-MEDIA:%s\n", renderAttributes(typed, renditionKeys, r.Attributes))
+}
+
+// Render writes the playlist: the header, the tags, the renditions and then
+// the variants.
+func (p *MasterPlaylist) Render() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		fmt.Fprintf(&buffer, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	if p.IndependentSegments {
+		buffer.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	for _, tag := range p.Tags {
+		buffer.WriteString(tag + "\n")
+	}
+	for _, rendition := range p.Renditions {
+		buffer.WriteString(rendition.String())
+	}
+	for _, variant := range p.Variants {
+		buffer.WriteString(variant.String())
+	}
+	return buffer.Bytes()
+}
+
+func newVariant(attributes map[string]string, line int) Variant {
+	bandwidth, _ := strconv.Atoi(attributes["BANDWIDTH"])
+	average, _ := strconv.Atoi(attributes["AVERAGE-BANDWIDTH"])
+	return Variant{
+		Bandwidth:        bandwidth,
+		AverageBandwidth: average,
+		Resolution:       attributes["RESOLUTION"],
+		Codecs:           attributes["CODECS"],
+		FrameRate:        attributes["FRAME-RATE"],
+		VideoRange:       attributes["VIDEO-RANGE"],
+		HDCPLevel:        attributes["HDCP-LEVEL"],
+		Audio:            attributes["AUDIO"],
+		Video:            attributes["VIDEO"],
+		Subtitles:        attributes["SUBTITLES"],
+		ClosedCaptions:   attributes["CLOSED-CAPTIONS"],
+		Attributes:       attributes,
+		Line:             line,
+	}
+}
+
+func newMediaRendition(attributes map[string]string, line int) MediaRendition {
+	return MediaRendition{
+		Type:            attributes["TYPE"],
+		GroupID:         attributes["GROUP-ID"],
+		Language:        attributes["LANGUAGE"],
+		AssocLanguage:   attributes["ASSOC-LANGUAGE"],
+		Name:            attributes["NAME"],
+		Default:         attributes["DEFAULT"] == "YES",
+		Autoselect:      attributes["AUTOSELECT"] == "YES",
+		Forced:          attributes["FORCED"] == "YES",
+		InstreamID:      attributes["INSTREAM-ID"],
+		Characteristics: attributes["CHARACTERISTICS"],
+		Channels:        attributes["CHANNELS"],
+		URI:             attributes["URI"],
+		Attributes:      attributes,
+		Line:            line,
+	}
+}
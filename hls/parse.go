@@ -0,0 +1,159 @@
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Segment struct {
+	Duration  float64
+	URI       string
+	ByteRange string
+	Line      int
+}
+
+type MediaPlaylist struct {
+	Version        int
+	TargetDuration int
+	MediaSequence  int
+	PlaylistType   string
+	Map            string
+	EndList        bool
+	Segments       []Segment
+}
+
+func IsMaster(data []byte) bool {
+	return bytes.Contains(data, []byte("#EXT-X-STREAM-INF"))
+}
+
+func ParseAttributes(value string) map[string]string {
+	attributes := map[string]string{}
+	for len(value) > 0 {
+		key, rest, ok := strings.Cut(value, "=")
+		if !ok {
+			break
+		}
+		var attr string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				attr, rest = rest[1:], ""
+			} else {
+				attr, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			attr, rest, _ = strings.Cut(rest, ",")
+		}
+		attributes[strings.TrimSpace(key)] = attr
+		value = rest
+	}
+	return attributes
+}
+
+func scanLines(data []byte, fn func(line string, number int) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if number == 1 && line != "#EXTM3U" {
+			return fmt.Errorf("line 1: playlist must start with #EXTM3U")
+		}
+		if line == "" {
+			continue
+		}
+		if err := fn(line, number); err != nil {
+			return fmt.Errorf("line %d: %w", number, err)
+		}
+	}
+	if number == 0 {
+		return fmt.Errorf("playlist is empty")
+	}
+	return scanner.Err()
+}
+
+func ParseMaster(data []byte) (*MasterPlaylist, error) {
+	playlist := &MasterPlaylist{}
+	var pending *Variant
+	err := scanLines(data, func(line string, number int) error {
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case tag == "#EXT-X-VERSION":
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid EXT-X-VERSION %q", value)
+			}
+			playlist.Version = version
+		case tag == "#EXT-X-STREAM-INF":
+			variant := newVariant(ParseAttributes(value), number)
+			pending = &variant
+		case tag == "#EXT-X-MEDIA":
+			playlist.Renditions = append(playlist.Renditions, newMediaRendition(ParseAttributes(value), number))
+		case tag == "#EXT-X-INDEPENDENT-SEGMENTS":
+			playlist.IndependentSegments = true
+		case tag == "#EXTM3U":
+		case strings.HasPrefix(line, "#"):
+			playlist.Tags = append(playlist.Tags, line)
+		default:
+			if pending == nil {
+				return fmt.Errorf("URI %s without EXT-X-STREAM-INF", line)
+			}
+			pending.URI = line
+			playlist.Variants = append(playlist.Variants, *pending)
+			pending = nil
+		}
+		return nil
+	})
+	if err == nil && pending != nil {
+		err = fmt.Errorf("line %d: EXT-X-STREAM-INF without URI", pending.Line)
+	}
+	return playlist, err
+}
+
+func ParseMedia(data []byte) (*MediaPlaylist, error) {
+	playlist := &MediaPlaylist{}
+	var pending *Segment
+	err := scanLines(data, func(line string, number int) error {
+		tag, value, _ := strings.Cut(line, ":")
+		var err error
+		switch {
+		case tag == "#EXT-X-VERSION":
+			playlist.Version, err = strconv.Atoi(value)
+		case tag == "#EXT-X-TARGETDURATION":
+			playlist.TargetDuration, err = strconv.Atoi(value)
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			playlist.MediaSequence, err = strconv.Atoi(value)
+		case tag == "#EXT-X-PLAYLIST-TYPE":
+			playlist.PlaylistType = value
+		case tag == "#EXT-X-MAP":
+			playlist.Map = ParseAttributes(value)["URI"]
+		case tag == "#EXT-X-ENDLIST":
+			playlist.EndList = true
+		case tag == "#EXTINF":
+			duration, _, _ := strings.Cut(value, ",")
+			pending = &Segment{Line: number}
+			pending.Duration, err = strconv.ParseFloat(duration, 64)
+		case tag == "#EXT-X-BYTERANGE":
+			if pending == nil {
+				pending = &Segment{Line: number}
+			}
+			pending.ByteRange = value
+		case !strings.HasPrefix(line, "#"):
+			if pending == nil {
+				return fmt.Errorf("segment %s without EXTINF", line)
+			}
+			pending.URI = line
+			playlist.Segments = append(playlist.Segments, *pending)
+			pending = nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q", strings.TrimPrefix(tag, "#"), value)
+		}
+		return nil
+	})
+	return playlist, err
+}
@@ -0,0 +1,145 @@
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const writeTimeout = time.Second
+
+type Controller interface {
+	Pause() error
+	Unpause() error
+	Cancel()
+}
+
+type Command struct {
+	Command string `json:"command"`
+}
+
+type Reply struct {
+	Type    string `json:"type"`
+	Command string `json:"command"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Server struct {
+	listener   net.Listener
+	controller Controller
+	logger     *slog.Logger
+
+	mu      sync.Mutex
+	clients map[net.Conn]*json.Encoder
+}
+
+func Listen(path string, controller Controller, logger *slog.Logger) (*Server, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket %s: %w", path, err)
+	}
+	s := &Server{
+		listener:   listener,
+		controller: controller,
+		logger:     logger,
+		clients:    map[net.Conn]*json.Encoder{},
+	}
+	go s.accept()
+	logger.Info("Listening on control socket", "path", path)
+	return s, nil
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.clients[conn] = json.NewEncoder(conn)
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.drop(conn)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var command Command
+		reply := Reply{Type: "reply"}
+		if err := json.Unmarshal(scanner.Bytes(), &command); err != nil {
+			reply.Error = fmt.Sprintf("invalid command: %v", err)
+		} else {
+			reply.Command = command.Command
+			s.logger.Info("Control command", "command", command.Command)
+			switch command.Command {
+			case "pause":
+				err = s.controller.Pause()
+			case "resume":
+				err = s.controller.Unpause()
+			case "cancel":
+				s.controller.Cancel()
+			default:
+				err = fmt.Errorf("unknown command %q (expected pause, resume or cancel)", command.Command)
+			}
+			if err != nil {
+				reply.Error = err.Error()
+			}
+		}
+		s.send(conn, reply)
+	}
+}
+
+func (s *Server) send(conn net.Conn, message any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	encoder, ok := s.clients[conn]
+	if !ok {
+		return
+	}
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := encoder.Encode(message); err != nil {
+		conn.Close()
+		delete(s.clients, conn)
+	}
+}
+
+func (s *Server) drop(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn.Close()
+	delete(s.clients, conn)
+}
+
+func (s *Server) Broadcast(event any) {
+	s.mu.Lock()
+	conns := make([]net.Conn, 0, len(s.clients))
+	for conn := range s.clients {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		s.send(conn, event)
+	}
+}
+
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.Close()
+		delete(s.clients, conn)
+	}
+	return err
+}
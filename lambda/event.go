@@ -0,0 +1,26 @@
+package lambda
+
+type S3Event struct {
+	Records []S3EventRecord `json:"Records"`
+}
+
+type S3EventRecord struct {
+	EventSource string   `json:"eventSource"`
+	EventName   string   `json:"eventName"`
+	AWSRegion   string   `json:"awsRegion"`
+	S3          S3Entity `json:"s3"`
+}
+
+type S3Entity struct {
+	Bucket S3Bucket `json:"bucket"`
+	Object S3Object `json:"object"`
+}
+
+type S3Bucket struct {
+	Name string `json:"name"`
+}
+
+type S3Object struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
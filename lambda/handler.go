@@ -0,0 +1,143 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gastrader/go_ffmpeg/ffmpeg"
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+func Handler(ctx context.Context, event S3Event) error {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load AWS config: %w", err)
+	}
+	processor := ffmpeg.NewVideoProcessor(logger)
+	processor.AssumeRoleARN = os.Getenv("ASSUME_ROLE_ARN")
+	processor.ExternalID = os.Getenv("EXTERNAL_ID")
+	processor.RequesterPays = os.Getenv("REQUESTER_PAYS") == "true"
+	if err := processor.AssumeRole(&cfg); err != nil {
+		return err
+	}
+	client := s3.NewFromConfig(cfg)
+	processor.S3Client = client
+
+	for _, record := range event.Records {
+		key, err := url.QueryUnescape(strings.ReplaceAll(record.S3.Object.Key, "+", " "))
+		if err != nil {
+			key = record.S3.Object.Key
+		}
+		if reason := skipReason(record.S3.Bucket.Name, key); reason != "" {
+			logger.Info("Skipping object", "bucket", record.S3.Bucket.Name, "key", key, "reason", reason)
+			continue
+		}
+		if err := processObject(ctx, processor, record.S3.Bucket.Name, key); err != nil {
+			logger.Error("Failed to process object", "bucket", record.S3.Bucket.Name, "key", key, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// skipReason returns why an object isn't transcoded, or "" if it is. Without
+// OUTPUT_BUCKET the output goes to the source bucket, so the handler's own
+// uploads would otherwise trigger it again.
+func skipReason(bucket, key string) string {
+	if envOr("OUTPUT_BUCKET", bucket) == bucket {
+		if strings.HasPrefix(key, strings.Trim(outputPrefix(), "/")+"/") {
+			return "under the output prefix"
+		}
+	}
+	extensions := ffmpeg.BackfillExtensions
+	if value := os.Getenv("INPUT_EXTENSIONS"); value != "" {
+		extensions = strings.Split(value, ",")
+	}
+	ext := strings.ToLower(path.Ext(key))
+	for _, allowed := range extensions {
+		if ext == strings.ToLower(strings.TrimSpace(allowed)) {
+			return ""
+		}
+	}
+	return "not a video file"
+}
+
+func outputPrefix() string {
+	return envOr("OUTPUT_PREFIX", "hls")
+}
+
+func processObject(ctx context.Context, processor *ffmpeg.VideoProcessor, bucket, key string) error {
+	client, logger := processor.S3Client, processor.Logger
+
+	workDir, err := os.MkdirTemp("", "go-ffmpeg-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputFile := filepath.Join(workDir, "input"+path.Ext(key))
+	if err := download(ctx, processor.NewS3Storage(client, bucket), key, inputFile); err != nil {
+		return err
+	}
+	logger.Info("Downloaded input", "bucket", bucket, "key", key)
+
+	outputBucket := envOr("OUTPUT_BUCKET", bucket)
+	job := ffmpeg.Job{
+		InputFile: inputFile,
+		OutputDir: filepath.Join(workDir, "output"),
+		S3Bucket:  outputBucket,
+		KeyPrefix: path.Join(outputPrefix(), strings.TrimSuffix(key, path.Ext(key))),
+		VideoID:   path.Base(strings.TrimSuffix(key, path.Ext(key))),
+		Storage:   processor.NewS3Storage(client, outputBucket),
+	}
+
+	if err := utils.PrepareOutputDir(job.OutputDir, utils.CleanAlways, false, logger); err != nil {
+		return err
+	}
+	// Stop ffmpeg when the invocation runs out of time instead of letting
+	// it run on in a frozen sandbox.
+	stop := context.AfterFunc(ctx, processor.Cancel)
+	defer stop()
+	if _, err := processor.Run(job); err != nil {
+		return err
+	}
+	logger.Info("Processed object", "bucket", bucket, "key", key, "outputBucket", job.S3Bucket, "outputPrefix", job.KeyPrefix)
+	return nil
+}
+
+func download(ctx context.Context, source *storage.S3, key, dst string) error {
+	body, err := source.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to download %s/%s: %w", source, key, err)
+	}
+	defer body.Close()
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to download %s/%s: %w", source, key, err)
+	}
+	return file.Close()
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
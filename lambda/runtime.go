@@ -0,0 +1,63 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const runtimeAPIVersion = "2018-06-01"
+
+func Start(handler func(context.Context, S3Event) error) error {
+	api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if api == "" {
+		return fmt.Errorf("AWS_LAMBDA_RUNTIME_API is not set; this binary must run inside AWS Lambda")
+	}
+	base := fmt.Sprintf("http://%s/%s/runtime/invocation/", api, runtimeAPIVersion)
+
+	for {
+		resp, err := http.Get(base + "next")
+		if err != nil {
+			return fmt.Errorf("failed to fetch next invocation: %w", err)
+		}
+		payload, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read invocation: %w", err)
+		}
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+
+		ctx := context.Background()
+		var cancel context.CancelFunc = func() {}
+		if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+			ctx, cancel = context.WithDeadline(ctx, time.UnixMilli(ms))
+		}
+
+		var event S3Event
+		err = json.Unmarshal(payload, &event)
+		if err == nil {
+			err = handler(ctx, event)
+		}
+		cancel()
+
+		if err != nil {
+			body, _ := json.Marshal(map[string]string{"errorMessage": err.Error(), "errorType": "ProcessingError"})
+			post(base+requestID+"/error", body)
+			continue
+		}
+		post(base+requestID+"/response", []byte(`{"status":"ok"}`))
+	}
+}
+
+func post(url string, body []byte) {
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+	}
+}
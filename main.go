@@ -1,19 +1,50 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/gastrader/go_ffmpeg/events"
 	"github.com/gastrader/go_ffmpeg/ffmpeg"
+	"github.com/gastrader/go_ffmpeg/hls"
+	"github.com/gastrader/go_ffmpeg/ipc"
+	"github.com/gastrader/go_ffmpeg/preview"
+	"github.com/gastrader/go_ffmpeg/storage"
+	"github.com/gastrader/go_ffmpeg/tui"
+	"github.com/gastrader/go_ffmpeg/types"
 	"github.com/gastrader/go_ffmpeg/utils"
+	"github.com/gastrader/go_ffmpeg/wizard"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+var jsonErrors bool
+
+var version, commit, buildDate string
+
 func main() {
 	if err := run(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		if jsonErrors {
+			json.NewEncoder(os.Stderr).Encode(map[string]any{
+				"error": err.Error(),
+				"code":  types.ExitCode(err),
+				"class": types.ExitClass(err),
+			})
+		} else {
+			fmt.Println(err)
+		}
+		os.Exit(types.ExitCode(err))
 	}
 }
 
@@ -25,55 +56,1334 @@ func run() error {
 
 	processor := ffmpeg.NewVideoProcessor(logger)
 
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		processor.Logger.Warn("Interrupted, stopping (interrupt again to exit immediately)")
+		processor.Cancel()
+		<-signals
+		os.Exit(types.ExitCancelled)
+	}()
+
+	var configFile string
+	var logLevel, logFormat string
+	var reportPath string
+	var costReport bool
+	var statsCSV string
+	unitPrices := ffmpeg.DefaultUnitPrices
+	var fakeStorageDir string
+	var ladderPreset string
+	var renditionFilters, renditionPostFilters []string
+	var renditionInputArgs, renditionOutputArgs []string
+	var audioCodecs []string
+	var renditionSpecs, resolutions, bitrates, audioBitrates []string
+	var uploadBandwidthLimit string
+	var memoryLimit string
+
+	var extraInputArgs, extraOutputArgs string
+	var clean string
+	var force bool
+	var thumbnailInterval float64
+	var thumbnailHeight int
+	var withThumbnails bool
+	var posterTime string
+	var posterSizes []int
+	var posterFormats []string
+	var withPoster bool
+	var proxyFormat string
+	var proxyHeight int
+	var withProxy bool
+	var hookSpecs []string
+	var notifySpecs []string
+	var eventBusSpecs []string
+	var eventSource string
+	var smtpServer ffmpeg.SMTPServer
+	var uploadSample bool
+	var controlSocket string
+	var playlistTemplate string
+	var sessionData, sessionDataJSON []string
+	var dashboard bool
+	allFlags := pflag.NewFlagSet("all", pflag.ContinueOnError)
+	outputDirFlags := pflag.NewFlagSet("output-dir", pflag.ContinueOnError)
+
+	checkTools := func() error {
+		if processor.Nice < 0 || processor.Nice > 19 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --nice %d (expected 0-19)", processor.Nice))
+		}
+		if processor.IOClass != "" && processor.IOClass != ffmpeg.IOClassBestEffort && processor.IOClass != ffmpeg.IOClassIdle {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --ionice %q (expected best-effort or idle)", processor.IOClass))
+		}
+		if processor.CPULimit > 0 && processor.ContainerRuntime == "" && runtime.GOOS != "linux" {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--cpu-limit requires Linux (systemd-run) or --container-runtime"))
+		}
+		if processor.Threads < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --threads %d (expected 0 or more)", processor.Threads))
+		}
+		if processor.JobTimeout < 0 || processor.RenditionTimeout < 0 || processor.StallTimeout < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--job-timeout, --rendition-timeout and --stall-timeout can't be negative"))
+		}
+		if processor.StallRetries < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --stall-retries %d (expected 0 or more)", processor.StallRetries))
+		}
+		if processor.RenditionRetries < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --rendition-retries %d (expected 0 or more)", processor.RenditionRetries))
+		}
+		if memoryLimit != "" {
+			limit, err := utils.ParseByteSize(memoryLimit)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --memory-limit: %v", err))
+			}
+			if processor.ContainerRuntime == "" && runtime.GOOS != "linux" {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("--memory-limit requires Linux (systemd-run) or --container-runtime"))
+			}
+			processor.MemoryLimit = limit
+		}
+		if processor.ContainerRuntime != "" && processor.ContainerImage == "" {
+			processor.ContainerImage = ffmpeg.DefaultContainerImage
+		}
+		processor.UseManagedTools()
+		if processor.Fake {
+			return nil
+		}
+		if err := utils.CheckRequiredTools(logger, processor.RequiredTools()...); err != nil {
+			return types.NewExitError(types.ExitMissingTools, err)
+		}
+		return processor.CheckToolVersions()
+	}
+
+	setupHooks := func() error {
+		for _, spec := range hookSpecs {
+			hook, err := ffmpeg.ParseExecHook(spec)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --hook: %v", err))
+			}
+			processor.Hooks = append(processor.Hooks, hook)
+		}
+		for _, spec := range eventBusSpecs {
+			publisher, err := events.Parse(spec, processor.LoadAWSConfig)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --event-bus: %v", err))
+			}
+			processor.Hooks = append(processor.Hooks, ffmpeg.EventHook{Publisher: publisher, Source: eventSource, Logger: logger})
+		}
+		return nil
+	}
+
+	checkInput := func(inputs ...string) error {
+		processor.InputFile = inputs[0]
+		processor.Report.InputFile = inputs[0]
+		processor.ConcatInputs = nil
+		if len(inputs) > 1 {
+			processor.ConcatInputs = inputs
+		}
+		args, err := utils.SplitArgs(extraInputArgs)
+		if err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --extra-input-args: %v", err))
+		}
+		processor.Config.ExtraInputArgs = args
+		for _, input := range inputs {
+			if ffmpeg.IsImageSequence(input) {
+				if len(ffmpeg.SequenceFrames(input)) == 0 && !processor.Fake {
+					logger.Error("No frames match the input pattern", "pattern", input)
+					return types.NewExitError(types.ExitValidation, fmt.Errorf("no frames match %s", input))
+				}
+			} else if _, err := os.Stat(input); os.IsNotExist(err) && !processor.Fake {
+				logger.Error("Input file does not exist", "file", input, "error", err)
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("input file %s does not exist", input))
+			}
+		}
+		return nil
+	}
+
+	prepareEncode := func() error {
+		for i, specs := range [][]string{sessionData, sessionDataJSON} {
+			for _, spec := range specs {
+				data, err := types.ParseSessionData(spec, i == 1)
+				if err != nil {
+					return types.NewExitError(types.ExitValidation, err)
+				}
+				processor.Config.SessionData = append(processor.Config.SessionData, data)
+			}
+		}
+		if playlistTemplate != "" {
+			processors, err := ffmpeg.LoadPlaylistTemplate(playlistTemplate)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			processor.PlaylistProcessors = append(processor.PlaylistProcessors, processors...)
+		}
+		if processor.KeepOutputs < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --keep-outputs %d (expected 0 or more)", processor.KeepOutputs))
+		}
+		if len(renditionSpecs) > 0 && (ladderPreset != "" || len(resolutions) > 0) {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--rendition can't be combined with --preset-ladder or --resolutions"))
+		}
+		if len(resolutions) > 0 && ladderPreset != "" {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--resolutions can't be combined with --preset-ladder"))
+		}
+		if ladderPreset != "" {
+			if err := processor.ApplyLadderPreset(ladderPreset); err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+		}
+		if len(renditionSpecs) > 0 {
+			processor.Config.Renditions = nil
+			for _, spec := range renditionSpecs {
+				rendition, err := types.ParseRenditionSpec(spec)
+				if err != nil {
+					return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --rendition: %v", err))
+				}
+				processor.Config.Renditions = append(processor.Config.Renditions, rendition)
+			}
+		}
+		if len(resolutions) > 0 || len(bitrates) > 0 {
+			renditions, err := types.RenditionsFromSizes(resolutions, bitrates)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --resolutions/--bitrates: %v", err))
+			}
+			processor.Config.Renditions = renditions
+		}
+		if err := processor.Config.SetAudioBitrates(audioBitrates); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --audio-bitrate: %v", err))
+		}
+		if err := types.ValidateRenditions(processor.Config.Renditions); err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		if err := ffmpeg.ValidateVideoCodec(processor.Config.VideoCodec, processor.Config.Preset); err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		if processor.Config.CRF < 0 || processor.Config.CRF > 51 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --crf %d (expected 0-51)", processor.Config.CRF))
+		}
+		if processor.Config.SegmentTime < 1 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --segment-time %d (expected 1 or more seconds)", processor.Config.SegmentTime))
+		}
+		for i, specs := range [][]string{renditionFilters, renditionPostFilters} {
+			if err := processor.Config.SetRenditionFilters(specs, i == 1); err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+		}
+		for i, specs := range [][]string{renditionInputArgs, renditionOutputArgs} {
+			if err := processor.Config.SetRenditionExtraArgs(specs, i == 1); err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+		}
+		if err := processor.Config.SetAudioCodecs(audioCodecs); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --audio-codec: %v", err))
+		}
+		switch processor.Config.ScalePolicy {
+		case ffmpeg.ScaleFit, ffmpeg.ScalePad, ffmpeg.ScaleCrop, ffmpeg.ScaleStretch:
+		default:
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --scale-policy %q (expected fit, pad, crop or stretch)", processor.Config.ScalePolicy))
+		}
+		switch processor.Config.HDRPolicy {
+		case ffmpeg.HDRStrip, ffmpeg.HDRPreserve, ffmpeg.HDRFail:
+		default:
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --hdr-policy %q (expected strip, preserve or fail)", processor.Config.HDRPolicy))
+		}
+		switch processor.Config.VFRPolicy {
+		case ffmpeg.VFRConform, ffmpeg.VFRKeep:
+		default:
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --vfr %q (expected conform or keep)", processor.Config.VFRPolicy))
+		}
+		if fps, err := utils.ParseFrameRate(processor.Config.ImageFrameRate); err != nil || fps <= 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --image-fps %q", processor.Config.ImageFrameRate))
+		}
+		if processor.Config.StillDuration <= 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--still-duration must be positive"))
+		}
+		for _, bitrate := range processor.Config.AudioLadder {
+			if _, err := utils.ParseBitrate(bitrate); err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --audio-ladder: %v", err))
+			}
+		}
+		if processor.Config.AudioPoster != "" {
+			if _, err := os.Stat(processor.Config.AudioPoster); err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --audio-poster: %v", err))
+			}
+		}
+		if err := ffmpeg.ValidateBurnin(processor.Config.Burnin); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --burnin: %v", err))
+		}
+		if processor.Config.Trim && processor.Resume {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--trim can't be combined with --resume"))
+		}
+		if processor.Config.BlackThreshold < 0 || processor.Config.BlackThreshold > 1 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--black-threshold must be between 0 and 1"))
+		}
+		if err := ffmpeg.ValidateComposition(processor.Config); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid composition: %v", err))
+		}
+		if processor.Config.ComposeWith != "" {
+			if _, err := os.Stat(processor.Config.ComposeWith); err != nil && !processor.Fake {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --compose-with: %v", err))
+			}
+		}
+		if err := ffmpeg.ValidateVideoStream(processor.Config.VideoStream); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --video-stream: %v", err))
+		}
+		if err := ffmpeg.ValidateProgramDateTime(processor.Config.ProgramDateTime); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --program-date-time: %v", err))
+		}
+		if err := ffmpeg.ValidateHLSFlags(processor.Config.HLSFlags); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --hls-flags: %v", err))
+		}
+		switch processor.Config.NoAudioPolicy {
+		case ffmpeg.NoAudioSkip, ffmpeg.NoAudioSilence:
+		default:
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --no-audio %q (expected skip or silence)", processor.Config.NoAudioPolicy))
+		}
+		outputArgs, err := utils.SplitArgs(extraOutputArgs)
+		if err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --extra-output-args: %v", err))
+		}
+		processor.Config.ExtraOutputArgs = outputArgs
+		if processor.Sample > 0 {
+			if err := ffmpeg.ValidateSampleStart(processor.SampleStart); err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --sample-start: %v", err))
+			}
+			if processor.Resume {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("--sample can't be combined with --resume"))
+			}
+			if !outputDirFlags.Changed("output") {
+				processor.OutputDir = "./sample-output"
+			}
+		}
+		if costReport {
+			if unitPrices.ComputePerHour < 0 || unitPrices.StoragePerGBMonth < 0 || unitPrices.DeliveryPerGB < 0 {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("--compute-price, --storage-price and --delivery-price can't be negative"))
+			}
+			processor.Prices = &unitPrices
+		}
+		if processor.StatsInterval < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--stats-interval can't be negative"))
+		}
+		if statsCSV != "" && processor.StatsInterval == 0 {
+			processor.StatsInterval = ffmpeg.DefaultStatsInterval
+		}
+		return nil
+	}
+
+	prepareOutputDir := func() error {
+		processor.Report.OutputDir = processor.OutputDir
+		if processor.Resume {
+			if err := os.MkdirAll(processor.OutputDir, os.ModePerm); err != nil {
+				logger.Error("Failed to create output directory", "outputDir", processor.OutputDir, "error", err)
+				return fmt.Errorf("failed to create output directory: %v", err)
+			}
+		} else if err := utils.PrepareOutputDir(processor.OutputDir, clean, force, logger); err != nil {
+			return types.NewExitError(types.ExitValidation, err)
+		}
+		return nil
+	}
+
+	transcode := func() error {
+		if err := processor.ProcessVideo(); err != nil {
+			logger.Error("Error processing video", "inputFile", processor.InputFile, "error", err)
+			return types.NewExitError(types.ExitEncode, fmt.Errorf("error processing video: %w", err))
+		}
+		return nil
+	}
+
+	thumbnails := func() error {
+		if err := processor.GenerateThumbnails(thumbnailInterval, thumbnailHeight); err != nil {
+			return types.NewExitError(types.ExitEncode, err)
+		}
+		return nil
+	}
+
+	proxy := func() error {
+		if err := processor.GenerateProxy(proxyFormat, proxyHeight); err != nil {
+			return types.NewExitError(types.ExitEncode, err)
+		}
+		return nil
+	}
+
+	poster := func() error {
+		if err := processor.GeneratePoster(posterTime, posterSizes, posterFormats); err != nil {
+			return types.NewExitError(types.ExitEncode, err)
+		}
+		return nil
+	}
+
+	initStorage := func() error {
+		if processor.Storage != nil {
+			return nil
+		}
+		if processor.Fake {
+			processor.Storage = storage.NewFS(filepath.Join(fakeStorageDir, processor.S3Bucket))
+		} else {
+			client, err := processor.InitAWSClient()
+			if err != nil {
+				logger.Error("Failed to initialize AWS client", "error", err)
+				return types.NewExitError(types.ExitUpload, fmt.Errorf("failed to initialize AWS client: %w", err))
+			}
+			processor.S3Client = client
+			processor.Storage = processor.NewS3Storage(client, processor.S3Bucket)
+		}
+
+		if uploadBandwidthLimit != "" {
+			limit, err := utils.ParseByteRate(uploadBandwidthLimit)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			processor.Storage = storage.NewThrottled(processor.Storage, limit)
+		}
+		return nil
+	}
+
+	setupNotifiers := func() error {
+		if err := ffmpeg.ValidateNotifyTemplates(processor.NotifyTemplates); err != nil {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --notify-template: %v", err))
+		}
+		if processor.NotifyLongRunning < 0 {
+			return types.NewExitError(types.ExitValidation, fmt.Errorf("--notify-long-running can't be negative"))
+		}
+		for _, spec := range notifySpecs {
+			notifier, err := ffmpeg.ParseNotifier(spec, smtpServer)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --notify: %v", err))
+			}
+			processor.Notifiers = append(processor.Notifiers, notifier)
+		}
+		return nil
+	}
+
+	deduplicate := func() error {
+		if processor.S3Bucket == "" || !processor.SkipDuplicates && processor.IdempotencyKey == "" {
+			return nil
+		}
+		if err := initStorage(); err != nil {
+			return err
+		}
+		return processor.CheckDuplicate()
+	}
+
+	upload := func() error {
+		if processor.S3Bucket == "" {
+			processor.Logger.Info("No bucket given, skipping upload.")
+			return nil
+		}
+		if processor.Sample > 0 && !uploadSample {
+			processor.Logger.Info("Sample encode, skipping upload (use --upload-sample to upload it).")
+			return nil
+		}
+		if err := initStorage(); err != nil {
+			return err
+		}
+
+		if err := processor.UploadToS3(); err != nil {
+			logger.Error("Error uploading to S3", "bucket", processor.S3Bucket, "error", err)
+			return types.NewExitError(types.ExitUpload, fmt.Errorf("error uploading to S3: %w", err))
+		}
+		return nil
+	}
+
+	runStages := func(inputs []string, stages ...func() error) (err error) {
+		processor.Report.StartedAt = time.Now().UTC()
+		if err := setupNotifiers(); err != nil {
+			return err
+		}
+		notified := processor.StartNotifications()
+		defer func() { notified(err) }()
+		var listeners []func(ffmpeg.ProgressEvent)
+		if dashboard {
+			if !tui.IsTerminal(os.Stdout) {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("--tui requires stdout to be a terminal"))
+			}
+			dash := tui.New(os.Stdout)
+			configured, err := utils.NewLogger(dash, logLevel, "text")
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			logger = configured
+			processor.Logger = configured
+			dash.Start()
+			defer dash.Stop()
+			listeners = append(listeners, dash.Handle)
+		}
+		if controlSocket != "" {
+			server, err := ipc.Listen(controlSocket, processor, logger)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			defer server.Close()
+			listeners = append(listeners, func(event ffmpeg.ProgressEvent) {
+				server.Broadcast(event)
+			})
+		}
+		if len(listeners) > 0 {
+			processor.Progress = func(event ffmpeg.ProgressEvent) {
+				for _, listener := range listeners {
+					listener(event)
+				}
+			}
+		}
+		if reportPath != "" {
+			defer func() {
+				processor.Report.FinishedAt = time.Now().UTC()
+				if err != nil {
+					processor.Report.Error = err.Error()
+				}
+				if reportErr := processor.WriteReport(reportPath); reportErr != nil {
+					logger.Error("Failed to write job report", "path", reportPath, "error", reportErr)
+				}
+			}()
+		}
+		if statsCSV != "" {
+			defer func() {
+				if statsErr := processor.WriteStatsCSV(statsCSV); statsErr != nil {
+					logger.Error("Failed to write encoding statistics", "path", statsCSV, "error", statsErr)
+				}
+			}()
+		}
+		if err := checkInput(inputs...); err != nil {
+			return err
+		}
+		for _, stage := range append([]func() error{setupHooks, prepareEncode, prepareOutputDir, deduplicate, checkTools}, stages...) {
+			if err := stage(); errors.Is(err, ffmpeg.ErrDuplicateJob) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		return processor.PruneOutputs()
+	}
+
+	pipeline := func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		stages := []func() error{transcode}
+		if withThumbnails {
+			stages = append(stages, thumbnails)
+		}
+		if withPoster {
+			stages = append(stages, poster)
+		}
+		if withProxy {
+			stages = append(stages, proxy)
+		}
+		if err := runStages(args, append(stages, upload)...); err != nil {
+			return err
+		}
+		processor.Logger.Info("Processing and upload completed successfully.")
+		return nil
+	}
+
+	inputArgs := func(cmd *cobra.Command, args []string) error {
+		return types.NewExitError(types.ExitValidation, cobra.MinimumNArgs(1)(cmd, args))
+	}
+
 	rootCmd := &cobra.Command{
-		Use:   "video-processor [input.mp4]",
-		Short: "Process video and upload HLS segments to S3",
-		Args:  cobra.MinimumNArgs(1),
+		Use:           "video-processor [input.mp4...]",
+		Short:         "Process video and upload HLS segments to S3",
+		Args:          inputArgs,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if configFile == "" {
+				configFile = os.Getenv(utils.EnvName("config"))
+			}
+			if err := utils.ApplyConfig(cmd.Flags(), allFlags, configFile); err != nil {
+				cmd.SilenceUsage = true
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			configured, err := utils.NewLogger(os.Stdout, logLevel, logFormat)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			logger = configured
+			processor.Logger = configured
+			return nil
+		},
+		RunE: pipeline,
+	}
+
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return types.NewExitError(types.ExitValidation, err)
+	})
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "JSON file with default flag values, keyed by flag name (env "+utils.EnvName("config")+")")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "json", "Log format: text or json")
+	rootCmd.PersistentFlags().BoolVar(&jsonErrors, "json", false, "Print errors as JSON on stderr")
+
+	toolFlags := pflag.NewFlagSet("tools", pflag.ContinueOnError)
+	toolFlags.StringVar(&processor.FFmpegPath, "ffmpeg-path", processor.FFmpegPath, "Path to the ffmpeg binary (env FFMPEG_PATH)")
+	toolFlags.StringVar(&processor.FFprobePath, "ffprobe-path", processor.FFprobePath, "Path to the ffprobe binary (env FFPROBE_PATH)")
+	toolFlags.StringVar(&processor.ManagedToolsDir, "ffmpeg-dir", processor.ManagedToolsDir, "Directory install-ffmpeg downloads a static ffmpeg into; used when ffmpeg is not in PATH")
+	toolFlags.StringVar(&processor.MinFFmpegVersion, "min-ffmpeg-version", processor.MinFFmpegVersion, "Minimum required ffmpeg/ffprobe version")
+	toolFlags.StringVar(&processor.ContainerRuntime, "container-runtime", "", "Run ffmpeg/ffprobe inside a container using this runtime (docker or podman)")
+	toolFlags.StringVar(&processor.ContainerImage, "container-image", "", "Container image providing ffmpeg and ffprobe (default "+ffmpeg.DefaultContainerImage+")")
+	toolFlags.IntVar(&processor.Nice, "nice", 0, "Run ffmpeg with this niceness (1-19; below-normal or idle priority class on Windows)")
+	toolFlags.StringVar(&processor.IOClass, "ionice", "", "Run ffmpeg with reduced I/O priority on Linux: best-effort or idle")
+	toolFlags.Float64Var(&processor.CPULimit, "cpu-limit", 0, "Limit ffmpeg to this many CPUs via a systemd-run cgroup scope (or --cpus with a container runtime)")
+	toolFlags.StringVar(&memoryLimit, "memory-limit", "", "Limit each ffmpeg process's memory, e.g. 4GiB, via a systemd-run cgroup scope (or --memory with a container runtime)")
+	toolFlags.IntVar(&processor.Threads, "threads", 0, "Limit each ffmpeg process to this many threads (0 lets ffmpeg decide); halved and retried when an encode runs out of memory")
+	toolFlags.DurationVar(&processor.JobTimeout, "job-timeout", 0, "Kill ffmpeg and fail the job when it is still encoding this long after it started, e.g. 2h (0 disables)")
+	toolFlags.DurationVar(&processor.RenditionTimeout, "rendition-timeout", 0, "Kill and fail any single ffmpeg process that runs longer than this (0 disables)")
+	toolFlags.DurationVar(&processor.StallTimeout, "stall-timeout", 0, "Kill ffmpeg when its output position hasn't advanced for this long, e.g. 5m (0 disables)")
+	toolFlags.IntVar(&processor.StallRetries, "stall-retries", 0, "Retry a rendition this many times after --stall-timeout kills its encoder")
+	toolFlags.IntVar(&processor.RenditionRetries, "rendition-retries", 0, "Retry a failed rendition this many times with safer settings: software encoder, faster preset, then fewer threads")
+	toolFlags.BoolVar(&processor.PartialSuccess, "partial-success", false, "Publish the renditions that succeeded when others fail, leaving the failed ones out of the master playlist")
+
+	fakeFlags := pflag.NewFlagSet("fake", pflag.ContinueOnError)
+	fakeFlags.BoolVar(&processor.Fake, "fake", false, "Generate synthetic segments and upload to local storage instead of running ffmpeg and using S3")
+
+	inputFlags := pflag.NewFlagSet("input", pflag.ContinueOnError)
+	inputFlags.StringVar(&processor.ErrDetect, "err-detect", "", "ffmpeg -err_detect flags for the input (e.g. ignore_err, or crccheck+bitstream to be strict)")
+	inputFlags.BoolVar(&processor.DiscardCorrupt, "discard-corrupt", false, "Drop corrupt packets from the input instead of decoding them (-fflags +discardcorrupt)")
+	inputFlags.BoolVar(&processor.ExitOnError, "xerror", false, "Make ffmpeg stop at the first decoding error")
+	inputFlags.BoolVar(&processor.Repair, "repair", false, "Remux the input into a clean container before encoding, dropping corrupt packets")
+	inputFlags.StringVar(&extraInputArgs, "extra-input-args", "", "Additional ffmpeg arguments placed before -i, split like a shell command line (e.g. \"-thread_queue_size 512\")")
+
+	encodeFlags := pflag.NewFlagSet("encode", pflag.ContinueOnError)
+	encodeFlags.DurationVar(&processor.Sample, "sample", 0, "Only encode this much of the input (e.g. 60s) across the full ladder to try out settings; skips upload unless --upload-sample")
+	encodeFlags.StringVar(&processor.SampleStart, "sample-start", "", "Where the --sample window starts: a duration such as 5m, or middle (default: the beginning)")
+	encodeFlags.StringVar(&ladderPreset, "preset-ladder", "", "Use a named bitrate ladder: "+strings.Join(ffmpeg.LadderPresetNames(), ", "))
+	encodeFlags.StringArrayVar(&renditionSpecs, "rendition", nil, "Add a rung to a custom ladder, as name=720p,size=1280x720,vb=3000k[,ab=96k,maxrate=,bufsize=,ac=,profile=,level=,crf=,codec=vp9] (repeatable)")
+	encodeFlags.StringSliceVar(&resolutions, "resolutions", nil, "Custom ladder sizes, e.g. 1920x1080,1280x720 (rungs are named by height; needs --bitrates)")
+	encodeFlags.StringSliceVar(&bitrates, "bitrates", nil, "Video bitrates for --resolutions, one per size, e.g. 5000k,3000k")
+	encodeFlags.StringSliceVar(&audioBitrates, "audio-bitrate", nil, "Audio bitrate for all rungs, or a comma-separated list per rung (default from the ladder, 128k for --resolutions)")
+	encodeFlags.StringVar(&processor.Config.VideoCodec, "codec", processor.Config.VideoCodec, "H.264 encoder: "+strings.Join(ffmpeg.VideoCodecs, ", ")+" (hardware encoders use bitrate control and ignore --preset and --crf)")
+	encodeFlags.StringVar(&processor.Config.Preset, "preset", processor.Config.Preset, "x264 speed/quality preset, from ultrafast to placebo")
+	encodeFlags.IntVar(&processor.Config.CRF, "crf", processor.Config.CRF, "x264 constant rate factor (0-51, lower is better), capped by each rung's bitrate; rungs can override it")
+	encodeFlags.IntVar(&processor.Config.SegmentTime, "segment-time", processor.Config.SegmentTime, "Target segment duration in seconds")
+	encodeFlags.StringVar(&processor.Config.ScalePolicy, "scale-policy", processor.Config.ScalePolicy, "How to map the source onto a rung whose aspect ratio differs: fit, pad, crop or stretch")
+	encodeFlags.StringSliceVar(&audioCodecs, "audio-codec", nil, "Audio codec for all rungs, or a comma-separated list per rung: aac, he-aac, he-aacv2 or opus (default aac)")
+	encodeFlags.BoolVar(&processor.Config.SmartPassthrough, "smart-passthrough", false, "Copy the source video for rungs it already matches (H.264, same size, bitrate within tolerance) instead of re-encoding")
+	encodeFlags.Float64Var(&processor.Config.PassthroughTolerance, "passthrough-tolerance", 0.1, "How far the source bitrate may exceed a rung's bitrate for --smart-passthrough (0.1 = 10%)")
+	encodeFlags.BoolVar(&processor.Config.AudioPassthrough, "audio-passthrough", false, "Copy AAC, AC-3 or E-AC-3 source audio instead of re-encoding when its bitrate doesn't exceed the rung's")
+	encodeFlags.StringVar(&processor.Config.VFRPolicy, "vfr", ffmpeg.VFRConform, "What to do with variable frame rate sources: conform (to the nearest standard constant rate) or keep (place keyframes by time)")
+	encodeFlags.DurationVar(&processor.Config.StillDuration, "still-duration", ffmpeg.DefaultStillDuration, "How long to show a still image input for")
+	encodeFlags.StringVar(&processor.Config.ImageFrameRate, "image-fps", ffmpeg.DefaultImageFrameRate, "Frame rate for image sequence and still image inputs")
+	encodeFlags.BoolVar(&processor.Config.KenBurns, "ken-burns", false, "Slowly zoom into still image inputs instead of showing them static")
+	encodeFlags.StringSliceVar(&processor.Config.AudioLadder, "audio-ladder", ffmpeg.DefaultAudioLadder, "Audio bitrates to encode when the input has no video stream")
+	encodeFlags.StringVar(&processor.Config.AudioPoster, "audio-poster", "", "Image to add as a static video track to audio-only inputs")
+	encodeFlags.StringSliceVar(&processor.Config.Burnin, "burnin", nil, "Encode a single review rendition with these overlays burned in: timecode, framenumber, filename")
+	encodeFlags.StringVar(&processor.Config.BurninFont, "burnin-font", "", "Font file for --burnin text (default: the fontconfig default font)")
+	encodeFlags.BoolVar(&processor.Config.Trim, "trim", false, "Trim leading and trailing black frames and silence before encoding")
+	encodeFlags.Float64Var(&processor.Config.BlackThreshold, "black-threshold", ffmpeg.DefaultBlackThreshold, "Pixel brightness (0-1) below which a frame counts as black for --trim")
+	encodeFlags.StringVar(&processor.Config.SilenceThreshold, "silence-threshold", ffmpeg.DefaultSilenceThreshold, "Audio level below which sound counts as silence for --trim")
+	encodeFlags.DurationVar(&processor.Config.TrimMinDuration, "trim-min-duration", ffmpeg.DefaultTrimMinDuration, "Shortest run of black or silence that --trim removes")
+	encodeFlags.StringVar(&processor.Config.ComposeWith, "compose-with", "", "Second video (e.g. a camera) to combine with the input before encoding")
+	encodeFlags.StringVar(&processor.Config.ComposeLayout, "layout", ffmpeg.ComposePIP, "How to combine --compose-with with the input: pip (picture-in-picture) or side-by-side")
+	encodeFlags.StringVar(&processor.Config.PIPPosition, "pip-position", ffmpeg.DefaultPIPPosition, "Corner for the picture-in-picture: top-left, top-right, bottom-left or bottom-right")
+	encodeFlags.Float64Var(&processor.Config.PIPScale, "pip-scale", ffmpeg.DefaultPIPScale, "Width of the picture-in-picture as a fraction of the input's width")
+	encodeFlags.StringVar(&processor.Config.VideoStream, "video-stream", "", "Video stream to transcode when the source has several: an index among its video streams (0 is the first) or best (highest resolution)")
+	encodeFlags.StringVar(&processor.Config.NoAudioPolicy, "no-audio", ffmpeg.NoAudioSkip, "What to do with sources that have no audio stream: skip (encode video only) or silence (add a silent AAC track)")
+	encodeFlags.StringVar(&processor.Config.HDRPolicy, "hdr-policy", ffmpeg.HDRStrip, "What to do with Dolby Vision / HDR10+ dynamic metadata: strip, preserve or fail")
+	encodeFlags.BoolVar(&processor.Config.StrictLevels, "strict-levels", false, "Fail instead of raising a rung's H.264 level when its size, frame rate or bitrate exceeds the configured level")
+	encodeFlags.BoolVar(&processor.Config.Reproducible, "reproducible", false, fmt.Sprintf("Produce byte-identical output for the same input and settings: pin --threads (default %d), strip metadata and encoder tags, and write %s", ffmpeg.ReproducibleThreads, ffmpeg.ChecksumsName))
+	encodeFlags.StringVar(&processor.Config.SegmentFormat, "segment-format", "", "Segment container: ts (MPEG-TS), m4s or mp4 (fMP4 with that extension); default ts, or m4s when DASH or the source needs fMP4")
+	encodeFlags.IntVar(&processor.Config.SegmentDigits, "segment-digits", processor.Config.SegmentDigits, "Digits in segment file indexes, e.g. 5 for 720_00042.ts")
+	encodeFlags.StringVar(&processor.Config.InitSegmentName, "init-segment-name", "", "Name of each rendition's fMP4 init segment, with {output} for the rendition name (default "+ffmpeg.DefaultInitSegmentName+")")
+	encodeFlags.BoolVar(&processor.Config.SingleFile, "single-file", false, "Write each rendition as one media file addressed with EXT-X-BYTERANGE instead of many small segment files")
+	encodeFlags.StringSliceVar(&processor.Config.HLSFlags, "hls-flags", ffmpeg.DefaultHLSFlags, "ffmpeg hls_flags to combine, e.g. independent_segments,temp_file,program_date_time")
+	encodeFlags.StringVar(&processor.Config.ProgramDateTime, "program-date-time", "", "Stamp segments with EXT-X-PROGRAM-DATE-TIME starting at the input's mtime, now (the encode start) or an RFC 3339 time")
+	encodeFlags.BoolVar(&processor.Config.Encrypt, "encrypt", false, "Encrypt HLS segments with AES-128 using a random key per job")
+	encodeFlags.StringVar(&processor.Config.KeyURI, "key-uri", "", "Key URI written to playlists, e.g. https://keys.example.com/{videoID} (default enc.key next to the playlists)")
+	encodeFlags.StringVar(&processor.Config.KeyFile, "key-file", "", "Where to write the encryption key (default <output>/enc.key, or <output>.key with --key-uri)")
+	encodeFlags.StringVar(&processor.Config.SegmentBaseURL, "segment-base-url", "", "Rewrite segment URIs in media playlists to absolute URLs under this base, e.g. https://cdn.example.com/{videoID}")
+	encodeFlags.StringVar(&processor.Config.VariantOrder, "variant-order", "", "Order of variants in the master playlist: desc or asc by bandwidth, or a comma-separated list of rendition names (default ladder order)")
+	encodeFlags.StringVar(&processor.Config.MinResolution, "min-resolution", "", "Leave renditions smaller than this out of the master playlist, e.g. 360p or 640x360")
+	encodeFlags.StringVar(&processor.Config.MaxResolution, "max-resolution", "", "Leave renditions larger than this out of the master playlist, e.g. 1080p or 1920x1080")
+	encodeFlags.StringArrayVar(&sessionData, "session-data", nil, "Add EXT-X-SESSION-DATA to the master playlist, as DATA-ID=value, e.g. com.example.title=My Movie (repeatable)")
+	encodeFlags.StringArrayVar(&sessionDataJSON, "session-data-json", nil, "Add EXT-X-SESSION-DATA pointing at a JSON file written next to the master playlist, as DATA-ID=json (repeatable)")
+	encodeFlags.StringVar(&processor.Config.StartOffset, "start-offset", "", "Add EXT-X-START with this TIME-OFFSET in seconds to the master playlist (negative counts from the end)")
+	encodeFlags.BoolVar(&processor.Config.StartPrecise, "start-precise", false, "Set PRECISE=YES on EXT-X-START")
+	encodeFlags.StringVar(&playlistTemplate, "playlist-template", "", "File of extra playlist tags to insert, in [master], [media] or [all] sections")
+	encodeFlags.StringVar(&processor.VideoID, "video-id", "", "Value of {videoID} in --key-uri and --segment-base-url (default the input file name without extension)")
+	encodeFlags.BoolVar(&processor.Config.DASH, "dash", false, "Package CMAF (fMP4) segments and write a DASH manifest next to the HLS playlists")
+	encodeFlags.StringVar(&processor.Config.Downmix, "downmix", "", "Downmix matrix for multi-channel sources: itu or dialogue (default: ffmpeg's -ac 2)")
+	encodeFlags.StringVar(&processor.Config.DownmixFilter, "downmix-filter", "", "Custom ffmpeg audio filter used to downmix multi-channel sources to stereo (e.g. a pan filter)")
+	encodeFlags.BoolVar(&processor.Config.LoudnessCompensation, "loudness-compensation", false, "Normalize loudness after downmixing multi-channel sources")
+	encodeFlags.BoolVar(&processor.Config.SurroundAudio, "surround", false, "Also encode multi-channel source audio as a separate 5.1 audio rendition group")
+	encodeFlags.StringVar(&processor.Config.SurroundBitrate, "surround-bitrate", "384k", "Bitrate of the 5.1 audio rendition")
+	encodeFlags.StringVar(&processor.Config.DolbyAudio, "dolby-audio", "", "Also add an ac3 or eac3 audio rendition group for living-room devices, passing matching source audio through")
+	encodeFlags.StringVar(&processor.Config.DolbyBitrate, "dolby-bitrate", "", "Bitrate of the Dolby audio rendition (default 448k for ac3, 640k for eac3)")
+	encodeFlags.StringVar(&processor.Config.AudioLanguage, "audio-language", "", "Language of the audio renditions (e.g. en, pt-BR), overriding the source's language tag")
+	encodeFlags.StringVar(&processor.Config.AudioName, "audio-name", "", "Display name of the audio renditions, overriding the source's title tag")
+	encodeFlags.StringVar(&processor.Config.AudioDescription, "audio-description", "", "Source audio stream to add as an audio description track: an index among its audio streams (1 is the second) or auto (the stream flagged visual_impaired)")
+	encodeFlags.StringVar(&extraOutputArgs, "extra-output-args", "", "Additional ffmpeg output arguments, split like a shell command line (e.g. \"-metadata title='My Movie'\")")
+	encodeFlags.StringArrayVar(&processor.Config.ExtraFilterArgs, "extra-filter", nil, "Additional video filter applied to every rendition before scaling (repeatable)")
+	encodeFlags.StringArrayVar(&processor.Config.PostFilters, "post-filter", nil, "Video filter applied to every rendition after scaling, e.g. unsharp (repeatable)")
+	encodeFlags.StringArrayVar(&renditionFilters, "rendition-filter", nil, "Video filter for one rendition before scaling, as NAME=FILTERS, e.g. 360=hqdn3d (repeatable)")
+	encodeFlags.StringArrayVar(&renditionPostFilters, "rendition-post-filter", nil, "Video filter for one rendition after scaling, as NAME=FILTERS, e.g. 1080=unsharp=5:5:0.5 (repeatable)")
+	encodeFlags.StringArrayVar(&renditionInputArgs, "rendition-extra-input-args", nil, "Additional ffmpeg arguments before -i for one rendition, as NAME=ARGS, e.g. 1080=\"-hwaccel cuda\" (repeatable)")
+	encodeFlags.StringArrayVar(&renditionOutputArgs, "rendition-extra-output-args", nil, "Additional ffmpeg output arguments for one rendition, as NAME=ARGS, e.g. 720=\"-tune fastdecode\" (repeatable)")
+
+	outputDirFlags.StringVarP(&processor.OutputDir, "output", "o", "./output", "Output directory")
+
+	outputFlags := pflag.NewFlagSet("output", pflag.ContinueOnError)
+	outputFlags.StringVar(&reportPath, "report", "", "Write a JSON job report to this path")
+	outputFlags.DurationVar(&processor.StatsInterval, "stats-interval", 0, "Record ffmpeg's fps, bitrate, q and speed per rendition at this interval into the job report (0 disables)")
+	outputFlags.StringVar(&statsCSV, "stats-csv", "", "Also write the recorded encoding statistics to this CSV file (implies --stats-interval=1s)")
+	outputFlags.BoolVar(&costReport, "cost", false, "Estimate compute, storage and delivery cost and add it to the log and job report")
+	outputFlags.Float64Var(&unitPrices.ComputePerHour, "compute-price", ffmpeg.DefaultUnitPrices.ComputePerHour, "Compute price per encode hour for --cost")
+	outputFlags.Float64Var(&unitPrices.StoragePerGBMonth, "storage-price", ffmpeg.DefaultUnitPrices.StoragePerGBMonth, "Storage price per GB-month for --cost")
+	outputFlags.Float64Var(&unitPrices.DeliveryPerGB, "delivery-price", ffmpeg.DefaultUnitPrices.DeliveryPerGB, "Delivery (CDN egress) price per GB for --cost")
+	outputFlags.StringVar(&clean, "clean", utils.CleanAlways, "How to clean the output directory before encoding: never, stale (only files from the previous run) or always")
+	outputFlags.BoolVar(&force, "force", false, "Allow --clean=always to delete files that were not created by this tool")
+	outputFlags.StringVar(&processor.ScratchDir, "scratch-dir", "", "Encode into this directory (e.g. tmpfs or a fast SSD) and move the results to the output directory")
+	outputFlags.BoolVar(&processor.SkipDiskCheck, "skip-disk-check", false, "Skip the free disk space check before encoding")
+	outputFlags.BoolVar(&processor.Resume, "resume", false, "Resume an interrupted encode from the checkpoint in the output directory")
+	outputFlags.IntVar(&processor.KeepOutputs, "keep-outputs", 0, "After a successful run, delete all but the N most recent output directories next to the output directory (0 keeps everything)")
+
+	awsFlags := pflag.NewFlagSet("aws", pflag.ContinueOnError)
+	awsFlags.StringVar(&processor.AssumeRoleARN, "assume-role-arn", "", "Access S3 through this IAM role, assumed with STS using the configured credentials")
+	awsFlags.StringVar(&processor.ExternalID, "external-id", "", "External ID to pass when assuming --assume-role-arn")
+	awsFlags.BoolVar(&processor.RequesterPays, "requester-pays", false, "Accept requester-pays charges when reading, listing and writing buckets")
+
+	uploadFlags := pflag.NewFlagSet("upload", pflag.ContinueOnError)
+	uploadFlags.AddFlagSet(awsFlags)
+	uploadFlags.StringVarP(&processor.S3Bucket, "bucket", "b", "", "S3 bucket to upload files")
+	uploadFlags.StringVar(&processor.KeyPrefix, "key-prefix", "", "Upload under this key prefix instead of the output directory path")
+	uploadFlags.StringVar(&processor.PlaylistCacheControl, "playlist-cache-control", "max-age=60", "Cache-Control header for playlists and manifests (empty to omit)")
+	uploadFlags.StringVar(&processor.SegmentCacheControl, "segment-cache-control", "max-age=31536000, immutable", "Cache-Control header for segments and other media (empty to omit)")
+	uploadFlags.StringVar(&uploadBandwidthLimit, "upload-bandwidth-limit", "", "Limit upload throughput, e.g. 50MB/s or 512KiB/s (default unlimited)")
+	uploadFlags.BoolVar(&uploadSample, "upload-sample", false, "Upload --sample encodes instead of skipping the upload")
+	uploadFlags.BoolVar(&processor.IfNoneMatch, "if-none-match", false, "Fail instead of overwriting objects that already exist in the bucket")
+	uploadFlags.BoolVar(&processor.SkipDuplicates, "skip-duplicates", false, "Skip the job if the destination's upload manifest was written for the same input content and settings")
+	uploadFlags.StringVar(&processor.IdempotencyKey, "idempotency-key", "", "Use this key instead of a content hash to detect duplicate jobs (implies --skip-duplicates)")
+	uploadFlags.StringToStringVar(&processor.ObjectTags, "tag", nil, "S3 object tag added to every uploaded object, e.g. video-id=abc123 (repeatable)")
+	uploadFlags.BoolVar(&processor.TagObjectType, "tag-object-type", false, "Tag each object with type=playlist, segment, thumbnail, proxy or manifest")
+	uploadFlags.BoolVar(&processor.SkipUnchanged, "skip-unchanged", false, "Check each object with a HEAD request and skip uploading files whose size and SHA-256 already match")
+	uploadFlags.StringToStringVar(&processor.ObjectMetadata, "metadata", nil, "S3 user metadata (x-amz-meta-*) added to every uploaded object, e.g. source=camera1 (repeatable)")
+	uploadFlags.BoolVar(&processor.DeleteAfterUpload, "delete-after-upload", false, "Delete the local output files once the uploaded objects are verified")
+	uploadFlags.StringVar(&fakeStorageDir, "fake-storage-dir", "./fake-s3", "Directory used as the bucket store in --fake mode")
+
+	thumbnailFlags := pflag.NewFlagSet("thumbnails", pflag.ContinueOnError)
+	thumbnailFlags.Float64Var(&thumbnailInterval, "thumbnail-interval", 10, "Seconds between thumbnails")
+	thumbnailFlags.IntVar(&thumbnailHeight, "thumbnail-height", 180, "Thumbnail height in pixels (width follows the aspect ratio)")
+
+	controlFlags := pflag.NewFlagSet("control", pflag.ContinueOnError)
+	controlFlags.StringVar(&controlSocket, "control-socket", "", "Listen on this Unix domain socket, broadcasting JSON progress events and accepting pause, resume and cancel commands")
+	controlFlags.BoolVar(&dashboard, "tui", false, "Show a live terminal dashboard with per-rendition progress, fps/speed, upload throughput and the log tail")
+
+	hookFlags := pflag.NewFlagSet("hooks", pflag.ContinueOnError)
+	hookFlags.StringArrayVar(&hookSpecs, "hook", nil, "Run a shell command at a stage boundary, as stage=command with the job context as JSON on stdin; stages: "+strings.Join(ffmpeg.HookStages, ", ")+" (repeatable)")
+
+	hookFlags.StringArrayVar(&eventBusSpecs, "event-bus", nil, "Publish job lifecycle events to a bus, as kind=target; kinds: "+strings.Join(events.Buses, ", ")+" (repeatable)")
+	hookFlags.StringVar(&eventSource, "event-source", events.DefaultSource, "Source of published events")
+
+	notifyFlags := pflag.NewFlagSet("notify", pflag.ContinueOnError)
+	notifyFlags.StringArrayVar(&notifySpecs, "notify", nil, "Send a notification when the job finishes, as channel[:events]=target, e.g. slack:failure=https://hooks.slack.com/...; channels: "+strings.Join(ffmpeg.NotifyChannels, ", ")+"; events: "+strings.Join(ffmpeg.NotifyEvents, ", ")+" (default success,failure; repeatable)")
+	notifyFlags.StringToStringVar(&processor.NotifyTemplates, "notify-template", nil, "Go template for an event's message, as event=template, e.g. failure='{{.InputFile}} failed: {{.Error}}' (repeatable)")
+	notifyFlags.DurationVar(&processor.NotifyLongRunning, "notify-long-running", 0, "Send the long-running notification once the job has run this long, e.g. 2h")
+	notifyFlags.StringVar(&processor.PlaybackURL, "playback-url", "", "Playback URL included in notifications, e.g. https://cdn.example.com/{videoID}/playlist.m3u8 (default: the master playlist under --segment-base-url or in the bucket)")
+	notifyFlags.StringVar(&smtpServer.Addr, "smtp-server", "", "SMTP server for email notifications, as host:port")
+	notifyFlags.StringVar(&smtpServer.From, "smtp-from", "", "Sender address for email notifications")
+	notifyFlags.StringVar(&smtpServer.Username, "smtp-username", "", "SMTP username (optional)")
+	notifyFlags.StringVar(&smtpServer.Password, "smtp-password", "", "SMTP password (prefer "+utils.EnvName("smtp-password")+" over the flag)")
+
+	pipelineFlags := pflag.NewFlagSet("pipeline", pflag.ContinueOnError)
+	pipelineFlags.BoolVar(&withThumbnails, "thumbnails", false, "Also write JPEG thumbnails to <output>/thumbnails before uploading")
+	pipelineFlags.BoolVar(&withPoster, "poster", false, "Also write poster images to <output>/poster before uploading")
+	pipelineFlags.BoolVar(&withProxy, "proxy", false, "Also write an editing proxy to <output>/proxy before uploading")
+
+	posterFlags := pflag.NewFlagSet("poster", pflag.ContinueOnError)
+	posterFlags.StringVar(&posterTime, "poster-time", "", "Take the poster from this time, e.g. 00:01:23.456 (default: pick the most representative keyframe)")
+	posterFlags.IntSliceVar(&posterSizes, "poster-sizes", ffmpeg.DefaultPosterSizes, "Poster heights in pixels")
+	posterFlags.StringSliceVar(&posterFormats, "poster-formats", ffmpeg.DefaultPosterFormats, "Poster image formats: jpeg, webp, avif")
+
+	proxyFlags := pflag.NewFlagSet("proxy", pflag.ContinueOnError)
+	proxyFlags.StringVar(&proxyFormat, "proxy-format", ffmpeg.ProxyProRes, "Editing proxy codec: "+strings.Join(ffmpeg.ProxyFormats, ", "))
+	proxyFlags.IntVar(&proxyHeight, "proxy-height", ffmpeg.DefaultProxyHeight, "Editing proxy height in pixels (0 keeps the source size)")
+
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, inputFlags, encodeFlags, outputDirFlags, outputFlags, uploadFlags, thumbnailFlags, posterFlags, proxyFlags, controlFlags, hookFlags, notifyFlags, pipelineFlags} {
+		rootCmd.Flags().AddFlagSet(flags)
+		allFlags.AddFlagSet(flags)
+	}
+	allFlags.AddFlagSet(rootCmd.PersistentFlags())
+
+	pipelineCmd := &cobra.Command{
+		Use:   "pipeline [input.mp4...]",
+		Short: "Transcode, optionally write thumbnails, and upload (the default command)",
+		Args:  inputArgs,
+		RunE:  pipeline,
+	}
+	pipelineCmd.Flags().AddFlagSet(rootCmd.Flags())
+	rootCmd.AddCommand(pipelineCmd)
+
+	transcodeCmd := &cobra.Command{
+		Use:   "transcode [input.mp4...]",
+		Short: "Encode the HLS renditions into the output directory without uploading",
+		Args:  inputArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runStages(args, transcode)
+		},
+	}
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, inputFlags, encodeFlags, outputDirFlags, outputFlags, controlFlags, hookFlags, notifyFlags} {
+		transcodeCmd.Flags().AddFlagSet(flags)
+	}
+	rootCmd.AddCommand(transcodeCmd)
+
+	uploadCmd := &cobra.Command{
+		Use:   "upload [output-dir]",
+		Short: "Upload an existing output directory without re-encoding",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if len(args) > 0 {
+				processor.OutputDir = args[0]
+			}
+			if processor.S3Bucket == "" {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("upload requires --bucket"))
+			}
+			found, err := ffmpeg.ContainsPlaylist(processor.OutputDir)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("failed to read %s: %v", processor.OutputDir, err))
+			}
+			if !found {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("%s doesn't contain any HLS or DASH playlists", processor.OutputDir))
+			}
+			if err := setupHooks(); err != nil {
+				return err
+			}
+			return upload()
+		},
+	}
+	syncFlags := pflag.NewFlagSet("sync", pflag.ContinueOnError)
+	syncFlags.BoolVar(&processor.DeleteStale, "delete", false, "Delete objects under the key prefix that aren't in the uploaded directory")
+	allFlags.AddFlagSet(syncFlags)
+	uploadCmd.Flags().AddFlagSet(syncFlags)
+	uploadCmd.Flags().AddFlagSet(uploadFlags)
+	uploadCmd.Flags().AddFlagSet(fakeFlags)
+	uploadCmd.Flags().AddFlagSet(hookFlags)
+	rootCmd.AddCommand(uploadCmd)
+
+	var updateDryRun bool
+	updateCmd := &cobra.Command{
+		Use:   "update [output-dir]",
+		Short: "Republish an output directory, uploading only the files that differ from the published upload manifest",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if len(args) > 0 {
+				processor.OutputDir = args[0]
+			}
+			if processor.S3Bucket == "" {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("update requires --bucket"))
+			}
+			found, err := ffmpeg.ContainsPlaylist(processor.OutputDir)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("failed to read %s: %v", processor.OutputDir, err))
+			}
+			if !found {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("%s doesn't contain any HLS or DASH playlists", processor.OutputDir))
+			}
+			for _, stage := range []func() error{setupHooks, initStorage} {
+				if err := stage(); err != nil {
+					return err
+				}
+			}
+			if err := processor.Update(updateDryRun); err != nil {
+				logger.Error("Error updating published output", "bucket", processor.S3Bucket, "error", err)
+				return types.NewExitError(types.ExitUpload, fmt.Errorf("error updating published output: %w", err))
+			}
+			return nil
+		},
+	}
+	updateCmd.Flags().BoolVar(&updateDryRun, "dry-run", false, "Only log the added, changed and removed files without uploading")
+	updateCmd.Flags().AddFlagSet(syncFlags)
+	updateCmd.Flags().AddFlagSet(uploadFlags)
+	updateCmd.Flags().AddFlagSet(fakeFlags)
+	updateCmd.Flags().AddFlagSet(hookFlags)
+	rootCmd.AddCommand(updateCmd)
+
+	packageCmd := &cobra.Command{
+		Use:   "package [input.mp4...]",
+		Short: "Segment an already encoded H.264/HEVC file into HLS without re-encoding",
+		Args:  inputArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			processor.PackageOnly = true
+			return pipeline(cmd, args)
+		},
+	}
+	packageCmd.Flags().AddFlagSet(rootCmd.Flags())
+	rootCmd.AddCommand(packageCmd)
+
+	probeCmd := &cobra.Command{
+		Use:   "probe [input.mp4]",
+		Short: "Print the ffprobe streams and format of an input as JSON and check it can be transcoded",
+		Args:  inputArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
 			processor.InputFile = args[0]
+			if err := checkTools(); err != nil {
+				return err
+			}
+			info, err := processor.Probe()
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(info); err != nil {
+				return err
+			}
+			return processor.ValidateInput(info)
+		},
+	}
+	probeCmd.Flags().AddFlagSet(toolFlags)
+	probeCmd.Flags().AddFlagSet(inputFlags)
+	rootCmd.AddCommand(probeCmd)
+
+	thumbnailsCmd := &cobra.Command{
+		Use:   "thumbnails [input.mp4]",
+		Short: "Write JPEG thumbnails at a fixed interval to <output>/thumbnails",
+		Args:  inputArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if err := checkInput(args[0]); err != nil {
+				return err
+			}
+			if err := checkTools(); err != nil {
+				return err
+			}
+			return thumbnails()
+		},
+	}
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, inputFlags, outputDirFlags, thumbnailFlags} {
+		thumbnailsCmd.Flags().AddFlagSet(flags)
+	}
+	rootCmd.AddCommand(thumbnailsCmd)
+
+	proxyCmd := &cobra.Command{
+		Use:   "proxy [input.mp4...]",
+		Short: "Write an editing proxy (ProRes, DNxHR or H.264 with the source timecode) to <output>/proxy instead of HLS, then upload if --bucket is set",
+		Args:  inputArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runStages(args, proxy, upload)
+		},
+	}
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, inputFlags, outputDirFlags, outputFlags, uploadFlags, hookFlags, notifyFlags, proxyFlags} {
+		proxyCmd.Flags().AddFlagSet(flags)
+	}
+	rootCmd.AddCommand(proxyCmd)
+
+	posterCmd := &cobra.Command{
+		Use:   "poster [input.mp4]",
+		Short: "Write poster images at several sizes to <output>/poster",
+		Args:  inputArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if err := checkInput(args[0]); err != nil {
+				return err
+			}
+			if err := checkTools(); err != nil {
+				return err
+			}
+			return poster()
+		},
+	}
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, inputFlags, outputDirFlags, posterFlags} {
+		posterCmd.Flags().AddFlagSet(flags)
+	}
+	rootCmd.AddCommand(posterCmd)
 
-			if _, err := os.Stat(processor.InputFile); os.IsNotExist(err) {
-				logger.Error("Input file does not exist", "file", processor.InputFile, "error", err)
-				return fmt.Errorf("input file %s does not exist", processor.InputFile)
+	var previewAddr, previewPlaylist string
+	var previewNoPlayer bool
+	previewCmd := &cobra.Command{
+		Use:   "preview [output-dir]",
+		Short: "Serve an output directory over HTTP to check a transcode locally",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "./output"
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			server, err := preview.Listen(dir, previewAddr, previewPlaylist, !previewNoPlayer, logger)
+			if err != nil {
+				return err
 			}
+			fmt.Printf("Playlist: %s\n", server.PlaylistURL)
+			if server.PlayerURL != "" {
+				fmt.Printf("Player:   %s\n", server.PlayerURL)
+			}
+			return server.Serve()
+		},
+	}
+	previewCmd.Flags().StringVarP(&previewAddr, "addr", "a", "localhost:8080", "Address to listen on")
+	previewCmd.Flags().StringVarP(&previewPlaylist, "playlist", "p", "playlist.m3u8", "Master playlist to open in the player")
+	previewCmd.Flags().BoolVar(&previewNoPlayer, "no-player", false, "Don't serve the hls.js player page")
+	rootCmd.AddCommand(previewCmd)
 
-			if processor.OutputDir == "" {
-				processor.OutputDir = "./output"
+	var verifyBucket string
+	verifyCmd := &cobra.Command{
+		Use:   "verify [manifest]",
+		Short: "Check uploaded objects against an upload manifest",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			path := filepath.Join("./output", ffmpeg.UploadManifestName)
+			if len(args) > 0 {
+				path = args[0]
 			}
-			if err := utils.PrepareOutputDir(processor.OutputDir, logger); err != nil {
+			manifest, err := ffmpeg.ReadUploadManifest(path)
+			if err != nil {
+				logger.Error("Failed to read upload manifest", "path", path, "error", err)
+				return types.NewExitError(types.ExitValidation, err)
+			}
+
+			location := manifest.Storage
+			if verifyBucket != "" {
+				location = "s3://" + verifyBucket
+			}
+			switch {
+			case strings.HasPrefix(location, "file://"):
+				processor.Storage = storage.NewFS(filepath.FromSlash(strings.TrimPrefix(location, "file://")))
+			case strings.HasPrefix(location, "s3://"):
+				client, err := processor.InitAWSClient()
+				if err != nil {
+					logger.Error("Failed to initialize AWS client", "error", err)
+					return types.NewExitError(types.ExitUpload, fmt.Errorf("failed to initialize AWS client: %w", err))
+				}
+				processor.Storage = processor.NewS3Storage(client, strings.TrimPrefix(location, "s3://"))
+			default:
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("manifest %s has unsupported storage %q; pass --bucket", path, manifest.Storage))
+			}
+
+			return types.NewExitError(types.ExitVerify, processor.VerifyUpload(manifest))
+		},
+	}
+	verifyCmd.Flags().StringVarP(&verifyBucket, "bucket", "b", "", "Verify against this S3 bucket instead of the storage recorded in the manifest")
+	verifyCmd.Flags().AddFlagSet(awsFlags)
+	rootCmd.AddCommand(verifyCmd)
+
+	var backfillExtensions []string
+	backfillCmd := &cobra.Command{
+		Use:   "backfill s3://bucket/prefix",
+		Short: "Process every video under an S3 prefix that hasn't been processed yet",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if !strings.HasPrefix(args[0], "s3://") {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("backfill source %q must be an s3://bucket/prefix URL", args[0]))
+			}
+			if processor.S3Bucket == "" || processor.KeyPrefix == "" {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("backfill requires --bucket and --key-prefix"))
+			}
+			if processor.EncodeJobs < 1 || processor.UploadJobs < 1 {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("--encode-jobs and --upload-jobs must be at least 1"))
+			}
+			sourceBucket, sourcePrefix, _ := strings.Cut(strings.TrimPrefix(args[0], "s3://"), "/")
+			if err := setupHooks(); err != nil {
+				return err
+			}
+			if err := checkTools(); err != nil {
 				return err
 			}
 
-			if err := utils.CheckRequiredTools(logger); err != nil {
+			if err := prepareEncode(); err != nil {
+				return err
+			}
+			if err := initStorage(); err != nil {
 				return err
 			}
+			var source storage.Storage = storage.NewFS(filepath.Join(fakeStorageDir, sourceBucket))
+			if !processor.Fake {
+				source = processor.NewS3Storage(processor.S3Client, sourceBucket)
+			}
 
-			if err := processor.ProcessVideo(); err != nil {
-				logger.Error("Error processing video", "inputFile", processor.InputFile, "error", err)
-				return fmt.Errorf("error processing video: %v", err)
+			if err := processor.Backfill(source, sourcePrefix, backfillExtensions); err != nil {
+				return types.NewExitError(types.ExitEncode, err)
 			}
+			return nil
+		},
+	}
+	backfillCmd.Flags().StringSliceVar(&backfillExtensions, "extensions", ffmpeg.BackfillExtensions, "Only process keys with these file extensions")
+	backfillCmd.Flags().IntVar(&processor.EncodeJobs, "encode-jobs", 1, "How many objects to download and encode at the same time")
+	backfillCmd.Flags().IntVar(&processor.UploadJobs, "upload-jobs", 1, "How many encoded objects to upload at the same time, while the next ones are encoding")
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, encodeFlags, outputFlags, uploadFlags, hookFlags} {
+		backfillCmd.Flags().AddFlagSet(flags)
+	}
+	rootCmd.AddCommand(backfillCmd)
 
-			client, err := processor.InitAWSClient()
+	var clipStart, clipEnd, clipFormat, clipPlaylist, clipBaseURL, clipOutput string
+	clipCmd := &cobra.Command{
+		Use:   "clip [output-dir | s3://bucket/prefix]",
+		Short: "Cut a time range out of an existing HLS output as an MP4 or a new playlist",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			location := "./output"
+			if len(args) > 0 {
+				location = args[0]
+			}
+			processor.OutputDir = clipOutput
+			if clipEnd == "" {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("clip requires --end"))
+			}
+			start, err := ffmpeg.ParseClockTime(clipStart)
 			if err != nil {
-				logger.Error("Failed to initialize AWS client", "error", err)
-				return fmt.Errorf("failed to initialize AWS client: %v", err)
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --start: %v", err))
+			}
+			end, err := ffmpeg.ParseClockTime(clipEnd)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --end: %v", err))
 			}
-			processor.S3Client = client
 
-			if processor.S3Bucket != "" {
-				if err := processor.UploadToS3(); err != nil {
-					logger.Error("Error uploading to S3", "bucket", processor.S3Bucket, "error", err)
-					return fmt.Errorf("error uploading to S3: %v", err)
+			var source hls.Source = hls.DirSource{Dir: location}
+			if strings.HasPrefix(location, "s3://") {
+				bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+				if processor.Fake {
+					source = hls.StorageSource{Storage: storage.NewFS(filepath.Join(fakeStorageDir, bucket)), Prefix: strings.TrimSuffix(prefix, "/")}
+				} else {
+					client, err := processor.InitAWSClient()
+					if err != nil {
+						logger.Error("Failed to initialize AWS client", "error", err)
+						return types.NewExitError(types.ExitUpload, fmt.Errorf("failed to initialize AWS client: %w", err))
+					}
+					source = hls.StorageSource{Storage: processor.NewS3Storage(client, bucket), Prefix: strings.TrimSuffix(prefix, "/")}
+				}
+				if clipFormat == ffmpeg.ClipHLS && clipBaseURL == "" {
+					return types.NewExitError(types.ExitValidation, fmt.Errorf("clipping %s as HLS requires --base-url for the unchanged segments", location))
+				}
+			} else if clipFormat == ffmpeg.ClipHLS && clipBaseURL == "" {
+				rel, err := filepath.Rel(processor.OutputDir, location)
+				if err != nil {
+					return types.NewExitError(types.ExitValidation, fmt.Errorf("can't reference %s from %s; pass --base-url: %v", location, processor.OutputDir, err))
 				}
+				clipBaseURL = filepath.ToSlash(rel)
+			}
+			if err := checkTools(); err != nil {
+				return err
+			}
+			if err := processor.Clip(source, clipPlaylist, start, end, clipFormat, clipBaseURL); err != nil {
+				return types.NewExitError(types.ExitEncode, err)
+			}
+			return nil
+		},
+	}
+	clipCmd.Flags().StringVar(&clipStart, "start", "0", "Clip start, as [HH:]MM:SS[.mmm] or seconds")
+	clipCmd.Flags().StringVar(&clipEnd, "end", "", "Clip end, as [HH:]MM:SS[.mmm] or seconds")
+	clipCmd.Flags().StringVar(&clipFormat, "format", ffmpeg.ClipMP4, "Clip format: mp4 (a re-encoded file) or hls (a playlist that reuses the original segments)")
+	clipCmd.Flags().StringVarP(&clipPlaylist, "playlist", "p", "playlist.m3u8", "Playlist to clip, relative to the output directory or prefix")
+	clipCmd.Flags().StringVar(&clipBaseURL, "base-url", "", "URL or path the hls clip uses to reference unchanged segments (default: relative path to a local output)")
+	clipCmd.Flags().StringVarP(&clipOutput, "output", "o", "./clip", "Directory to write the clip to")
+	clipCmd.Flags().AddFlagSet(toolFlags)
+	clipCmd.Flags().AddFlagSet(fakeFlags)
+	clipCmd.Flags().AddFlagSet(awsFlags)
+	rootCmd.AddCommand(clipCmd)
+
+	var mezzanine string
+	reladderCmd := &cobra.Command{
+		Use:   "reladder [output-dir]",
+		Short: "Add missing rungs of the ladder to an existing output and upload only the new files",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if len(args) > 0 {
+				processor.OutputDir = args[0]
+			}
+			processor.InputFile = ""
+			if mezzanine != "" {
+				if err := checkInput(mezzanine); err != nil {
+					return err
+				}
+			}
+			for _, stage := range []func() error{setupHooks, prepareEncode, checkTools} {
+				if err := stage(); err != nil {
+					return err
+				}
+			}
+			added, err := processor.Reladder()
+			if err != nil {
+				logger.Error("Error adding renditions", "outputDir", processor.OutputDir, "error", err)
+				return types.NewExitError(types.ExitEncode, fmt.Errorf("error adding renditions: %w", err))
+			}
+			if len(added) == 0 {
+				return nil
+			}
+			return upload()
+		},
+	}
+	reladderCmd.Flags().StringVar(&mezzanine, "mezzanine", "", "Encode the new rungs from this file instead of the output's top rendition")
+	for _, flags := range []*pflag.FlagSet{toolFlags, fakeFlags, encodeFlags, uploadFlags, hookFlags} {
+		reladderCmd.Flags().AddFlagSet(flags)
+	}
+	reladderCmd.Flags().AddFlag(outputFlags.Lookup("skip-disk-check"))
+	rootCmd.AddCommand(reladderCmd)
+
+	var lintPlaylist string
+	lintCmd := &cobra.Command{
+		Use:   "lint [output-dir | s3://bucket/prefix]",
+		Short: "Check an HLS output tree for spec violations and missing files",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			location := "./output"
+			if len(args) > 0 {
+				location = args[0]
+			}
+
+			var source hls.Source = hls.DirSource{Dir: location}
+			if strings.HasPrefix(location, "s3://") {
+				bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+				client, err := processor.InitAWSClient()
+				if err != nil {
+					logger.Error("Failed to initialize AWS client", "error", err)
+					return types.NewExitError(types.ExitUpload, fmt.Errorf("failed to initialize AWS client: %w", err))
+				}
+				source = hls.StorageSource{Storage: processor.NewS3Storage(client, bucket), Prefix: strings.TrimSuffix(prefix, "/")}
+			}
+
+			findings, err := hls.Lint(source, lintPlaylist)
+			if err != nil {
+				logger.Error("Failed to lint playlist", "location", location, "error", err)
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			errorCount := 0
+			for _, finding := range findings {
+				fmt.Println(finding)
+				if finding.Severity == hls.SeverityError {
+					errorCount++
+				}
+			}
+			if errorCount > 0 {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("%d errors and %d warnings in %s", errorCount, len(findings)-errorCount, source))
+			}
+			fmt.Printf("%s: no errors, %d warnings\n", source, len(findings))
+			return nil
+		},
+	}
+	lintCmd.Flags().StringVarP(&lintPlaylist, "playlist", "p", "playlist.m3u8", "Playlist to start from, relative to the output directory or prefix")
+	lintCmd.Flags().AddFlagSet(awsFlags)
+	rootCmd.AddCommand(lintCmd)
+
+	var initForce bool
+	initCmd := &cobra.Command{
+		Use:   "init [config.json]",
+		Short: "Ask about target platforms, sources and storage, then write a starter config file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			path := "video-processor.json"
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if _, err := os.Stat(path); err == nil && !initForce {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("%s already exists; pass --force to overwrite it", path))
 			}
 
-			processor.Logger.Info("Processing and upload completed successfully.")
+			answers, err := wizard.Ask(os.Stdin, os.Stdout)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			config, err := wizard.Config(answers)
+			if err != nil {
+				return types.NewExitError(types.ExitValidation, err)
+			}
+			data, err := json.MarshalIndent(config, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteFileAtomic(path, append(data, '\n'), 0644); err != nil {
+				logger.Error("Failed to write config file", "path", path, "error", err)
+				return fmt.Errorf("failed to write config file: %v", err)
+			}
+			fmt.Printf("\nWrote %s with %d renditions. Use it with:\n  video-processor --config %s input.mp4\n", path, len(config["rendition"].([]string)), path)
 			return nil
 		},
 	}
+	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "Overwrite an existing config file")
+	rootCmd.AddCommand(initCmd)
+
+	var versionFormat string
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the build version and the detected ffmpeg/ffprobe capabilities",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if versionFormat != "text" && versionFormat != "json" {
+				return types.NewExitError(types.ExitValidation, fmt.Errorf("invalid --format %q (expected text or json)", versionFormat))
+			}
+			if processor.ContainerRuntime != "" && processor.ContainerImage == "" {
+				processor.ContainerImage = ffmpeg.DefaultContainerImage
+			}
+			processor.UseManagedTools()
+			build := utils.ReadBuildInfo(version, commit, buildDate)
+			caps := processor.ProbeCapabilities()
+			if versionFormat == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(map[string]any{
+					"build":   build,
+					"ffmpeg":  caps,
+					"storage": storage.Backends,
+					"codecs":  ffmpeg.VideoCodecs,
+					"ladders": ffmpeg.LadderPresetNames(),
+				})
+			}
 
-	rootCmd.Flags().StringVarP(&processor.OutputDir, "output", "o", "", "Output directory (default: ./output)")
-	rootCmd.Flags().StringVarP(&processor.S3Bucket, "bucket", "b", "", "S3 bucket to upload files")
+			fmt.Printf("video-processor %s", build.Version)
+			if build.Commit != "" {
+				fmt.Printf(" (%s", build.Commit[:min(len(build.Commit), 12)])
+				if build.Modified {
+					fmt.Print(", modified")
+				}
+				if build.Date != "" {
+					fmt.Printf(", %s", build.Date)
+				}
+				fmt.Print(")")
+			}
+			fmt.Printf(" %s %s\n", build.GoVersion, build.Platform)
+			fmt.Printf("ffmpeg:    %s %s\n", caps.FFmpegPath, caps.FFmpegVersion)
+			fmt.Printf("ffprobe:   %s %s\n", caps.FFprobePath, caps.FFprobeVersion)
+			var codecs []string
+			for _, codec := range ffmpeg.VideoCodecs {
+				if slices.Contains(caps.VideoEncoders, codec) {
+					codecs = append(codecs, codec)
+				}
+			}
+			if caps.FFmpegVersion != "" {
+				fmt.Printf("codecs:    %s (%d video and %d audio encoders in total)\n", strings.Join(codecs, ", "), len(caps.VideoEncoders), len(caps.AudioEncoders))
+				fmt.Printf("hwaccels:  %s\n", strings.Join(caps.HWAccels, ", "))
+			}
+			fmt.Printf("storage:   %s\n", strings.Join(storage.Backends, ", "))
+			for _, err := range caps.Errors {
+				fmt.Printf("warning:   %s\n", err)
+			}
+			return nil
+		},
+	}
+	versionCmd.Flags().StringVar(&versionFormat, "format", "text", "Output format: text or json")
+	versionCmd.Flags().AddFlag(toolFlags.Lookup("ffmpeg-path"))
+	versionCmd.Flags().AddFlag(toolFlags.Lookup("ffprobe-path"))
+	versionCmd.Flags().AddFlag(toolFlags.Lookup("ffmpeg-dir"))
+	versionCmd.Flags().AddFlag(toolFlags.Lookup("container-runtime"))
+	versionCmd.Flags().AddFlag(toolFlags.Lookup("container-image"))
+	rootCmd.AddCommand(versionCmd)
+
+	var installForce bool
+	var installMirror string
+	var installChecksums map[string]string
+	installCmd := &cobra.Command{
+		Use:   "install-ffmpeg",
+		Short: "Download a pinned static ffmpeg " + ffmpeg.ManagedFFmpegVersion + " build for this platform, used when ffmpeg is not in PATH",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if err := processor.InstallFFmpeg(installMirror, installChecksums, installForce); err != nil {
+				return err
+			}
+			fmt.Printf("ffmpeg %s installed in %s\n", ffmpeg.ManagedFFmpegVersion, processor.ManagedToolsDir)
+			if _, err := exec.LookPath("ffmpeg"); err == nil {
+				fmt.Println("An ffmpeg in PATH takes precedence; pass --ffmpeg-path " + processor.FFmpegPath + " --ffprobe-path " + processor.FFprobePath + " to use this build")
+			}
+			return nil
+		},
+	}
+	installCmd.Flags().BoolVarP(&installForce, "force", "f", false, "Download again even if the build is already installed")
+	installCmd.Flags().StringVar(&installMirror, "mirror", ffmpeg.ManagedFFmpegURL, "Base URL to download ffmpeg-<platform>.gz and ffprobe-<platform>.gz from")
+	installCmd.Flags().StringToStringVar(&installChecksums, "sha256", nil, "Expected SHA-256 of the downloads, as ffmpeg=<hex>,ffprobe=<hex>; required with --mirror")
+	installCmd.Flags().AddFlag(toolFlags.Lookup("ffmpeg-dir"))
+	installCmd.Flags().AddFlag(toolFlags.Lookup("min-ffmpeg-version"))
+	rootCmd.AddCommand(installCmd)
 
 	return rootCmd.Execute()
 }
@@ -0,0 +1,143 @@
+package preview
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var mimeTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".mpd":  "application/dash+xml",
+	".vtt":  "text/vtt",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+}
+
+var playerPage = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>HLS preview - {{.Playlist}}</title>
+<script src="https://cdn.jsdelivr.net/npm/hls.js@1"></script>
+<style>body{margin:0;background:#111;color:#eee;font-family:sans-serif}video{width:100%;max-height:90vh}p{padding:0 1em}</style>
+</head>
+<body>
+<video id="video" controls autoplay muted></video>
+<p id="info">{{.Playlist}}</p>
+<script>
+const video = document.getElementById('video');
+const src = '{{.Playlist}}';
+if (video.canPlayType('application/vnd.apple.mpegurl')) {
+  video.src = src;
+} else if (Hls.isSupported()) {
+  const hls = new Hls();
+  hls.loadSource(src);
+  hls.attachMedia(video);
+  hls.on(Hls.Events.LEVEL_SWITCHED, (_, data) => {
+    const level = hls.levels[data.level];
+    document.getElementById('info').textContent = src + ' - ' + level.width + 'x' + level.height + ' @ ' + level.bitrate + 'bps';
+  });
+}
+</script>
+</body>
+</html>
+`))
+
+func init() {
+	for ext, typ := range mimeTypes {
+		mime.AddExtensionType(ext, typ)
+	}
+}
+
+// Server serves an output directory. PlaylistURL and PlayerURL are set once
+// it listens; PlayerURL is empty without the player page.
+type Server struct {
+	PlaylistURL string
+	PlayerURL   string
+
+	listener net.Listener
+	handler  http.Handler
+}
+
+// Listen opens the listener for dir, so the caller can show the URLs before
+// calling Serve.
+func Listen(dir, addr, playlist string, player bool, logger *slog.Logger) (*Server, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		logger.Error("Preview directory does not exist", "dir", dir, "error", err)
+		return nil, fmt.Errorf("preview directory %s does not exist", dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, playlist)); err != nil {
+		logger.Warn("Playlist not found in preview directory", "dir", dir, "playlist", playlist)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Error("Failed to listen", "addr", addr, "error", err)
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	files := http.FileServer(http.Dir(dir))
+	mux.Handle("/", withCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if player && r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			playerPage.Execute(w, struct{ Playlist string }{"/" + playlist})
+			return
+		}
+		if typ, ok := mimeTypes[strings.ToLower(filepath.Ext(r.URL.Path))]; ok {
+			w.Header().Set("Content-Type", typ)
+		}
+		if strings.HasSuffix(r.URL.Path, ".m3u8") || strings.HasSuffix(r.URL.Path, ".mpd") {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})))
+
+	base := "http://" + displayAddr(listener.Addr().String())
+	logger.Info("Serving preview", "dir", dir, "addr", listener.Addr().String())
+	server := &Server{PlaylistURL: base + "/" + playlist, listener: listener, handler: mux}
+	if player {
+		server.PlayerURL = base + "/"
+	}
+	return server, nil
+}
+
+// Serve blocks serving requests until the listener fails.
+func (s *Server) Serve() error {
+	return http.Serve(s.listener, s.handler)
+}
+
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Range")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func displayAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "::" || host == "0.0.0.0" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type FS struct {
+	Root string
+}
+
+func NewFS(root string) *FS {
+	return &FS{Root: root}
+}
+
+func (f *FS) path(key string) (string, error) {
+	path := filepath.Join(f.Root, filepath.FromSlash(strings.TrimPrefix(key, "/")))
+	rel, err := filepath.Rel(f.Root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("key %s escapes storage root", key)
+	}
+	return path, nil
+}
+
+func (f *FS) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (PutResult, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return PutResult{}, err
+	}
+	if opts.IfNoneMatch {
+		if _, err := os.Stat(path); err == nil {
+			return PutResult{}, fmt.Errorf("%s: %w", key, ErrExists)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return PutResult{}, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return PutResult{}, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return PutResult{}, err
+	}
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		file.Close()
+		return PutResult{}, err
+	}
+	if err := file.Close(); err != nil {
+		return PutResult{}, err
+	}
+	return PutResult{ETag: `"` + hex.EncodeToString(hash.Sum(nil)) + `"`}, nil
+}
+
+func (f *FS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return file, err
+}
+
+func (f *FS) Stat(ctx context.Context, key string) (int64, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (f *FS) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	file, err := f.Get(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer file.Close()
+	sha, md := sha256.New(), md5.New()
+	size, err := io.Copy(io.MultiWriter(sha, md), file)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: size, SHA256: hex.EncodeToString(sha.Sum(nil)), ETag: `"` + hex.EncodeToString(md.Sum(nil)) + `"`}, nil
+}
+
+func (f *FS) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(f.Root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(f.Root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, strings.TrimPrefix(prefix, "/")) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (f *FS) Delete(ctx context.Context, key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *FS) String() string {
+	return "file://" + filepath.ToSlash(f.Root)
+}
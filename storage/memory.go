@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Memory struct {
+	mu      sync.Mutex
+	Objects map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{Objects: map[string][]byte{}}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (PutResult, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return PutResult{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.Objects[key]; ok && opts.IfNoneMatch {
+		return PutResult{}, fmt.Errorf("%s: %w", key, ErrExists)
+	}
+	m.Objects[key] = data
+	sum := md5.Sum(data)
+	return PutResult{ETag: `"` + hex.EncodeToString(sum[:]) + `"`}, nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.Objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.Objects[key]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return int64(len(data)), nil
+}
+
+func (m *Memory) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.Objects[key]
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	sha, md := sha256.Sum256(data), md5.Sum(data)
+	return ObjectInfo{Size: int64(len(data)), SHA256: hex.EncodeToString(sha[:]), ETag: `"` + hex.EncodeToString(md[:]) + `"`}, nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.Objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Objects, key)
+	return nil
+}
+
+func (m *Memory) String() string {
+	return "memory://"
+}
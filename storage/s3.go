@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+type S3 struct {
+	Client        *s3.Client
+	Bucket        string
+	RequesterPays bool
+}
+
+func NewS3(client *s3.Client, bucket string) *S3 {
+	return &S3{Client: client, Bucket: bucket}
+}
+
+func (s *S3) requestPayer() types.RequestPayer {
+	if s.RequesterPays {
+		return types.RequestPayerRequester
+	}
+	return ""
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (PutResult, error) {
+	input := &s3.PutObjectInput{
+		Bucket:       &s.Bucket,
+		RequestPayer: s.requestPayer(),
+		Key:          &key,
+		Body:         body,
+	}
+	if opts.IfNoneMatch {
+		input.IfNoneMatch = aws.String("*")
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = aws.String(opts.CacheControl)
+	}
+	if len(opts.Tags) > 0 {
+		tags := url.Values{}
+		for key, value := range opts.Tags {
+			tags.Set(key, value)
+		}
+		input.Tagging = aws.String(tags.Encode())
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+
+	output, err := s.Client.PutObject(ctx, input)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" {
+			return PutResult{}, fmt.Errorf("%s: %w", key, ErrExists)
+		}
+		return PutResult{}, err
+	}
+	return PutResult{ETag: aws.ToString(output.ETag)}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:       &s.Bucket,
+		RequestPayer: s.requestPayer(),
+		Key:          &key,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (int64, error) {
+	output, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       &s.Bucket,
+		RequestPayer: s.requestPayer(),
+		Key:          &key,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey") {
+			return 0, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return 0, err
+	}
+	return aws.ToInt64(output.ContentLength), nil
+}
+
+func (s *S3) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	output, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       &s.Bucket,
+		RequestPayer: s.requestPayer(),
+		Key:          &key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey") {
+			return ObjectInfo{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return ObjectInfo{}, err
+	}
+	info := ObjectInfo{Size: aws.ToInt64(output.ContentLength), ETag: aws.ToString(output.ETag), SHA256: output.Metadata[ChecksumMetadataKey]}
+	if checksum := aws.ToString(output.ChecksumSHA256); checksum != "" && !strings.Contains(checksum, "-") {
+		if sum, err := base64.StdEncoding.DecodeString(checksum); err == nil {
+			info.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+	return info, nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket:       &s.Bucket,
+		RequestPayer: s.requestPayer(),
+		Prefix:       &prefix,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:       &s.Bucket,
+		RequestPayer: s.requestPayer(),
+		Key:          &key,
+	})
+	return err
+}
+
+func (s *S3) String() string {
+	return "s3://" + s.Bucket
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var Backends = []string{"s3", "fs"}
+
+var (
+	ErrExists   = errors.New("object already exists")
+	ErrNotFound = errors.New("object not found")
+)
+
+type PutOptions struct {
+	IfNoneMatch  bool
+	ContentType  string
+	CacheControl string
+	Tags         map[string]string
+	Metadata     map[string]string
+}
+
+const (
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("%d object tags given, at most %d are allowed", len(tags), maxTags)
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > maxTagKeyLength {
+			return fmt.Errorf("object tag key %q must be 1-%d characters", key, maxTagKeyLength)
+		}
+		if len(value) > maxTagValueLength {
+			return fmt.Errorf("object tag %s value must be at most %d characters", key, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
+type PutResult struct {
+	ETag string
+}
+
+const ChecksumMetadataKey = "sha256"
+
+type ObjectInfo struct {
+	Size   int64
+	SHA256 string
+	ETag   string
+}
+
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (PutResult, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (int64, error)
+	Head(ctx context.Context, key string) (ObjectInfo, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+	String() string
+}
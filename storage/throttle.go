@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type Throttled struct {
+	Storage
+	bytesPerSecond float64
+	mu             sync.Mutex
+	tokens         float64
+	last           time.Time
+}
+
+func NewThrottled(s Storage, bytesPerSecond int64) *Throttled {
+	return &Throttled{Storage: s, bytesPerSecond: float64(bytesPerSecond), last: time.Now()}
+}
+
+func (t *Throttled) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (PutResult, error) {
+	reader := &throttledReader{ctx: ctx, r: body, t: t}
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		return t.Storage.Put(ctx, key, &throttledReadSeeker{throttledReader: reader, s: seeker}, opts)
+	}
+	return t.Storage.Put(ctx, key, reader, opts)
+}
+
+func (t *Throttled) wait(ctx context.Context, n int) error {
+	t.mu.Lock()
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * t.bytesPerSecond
+	if t.tokens > t.bytesPerSecond {
+		t.tokens = t.bytesPerSecond
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	delay := time.Duration(-t.tokens / t.bytesPerSecond * float64(time.Second))
+	t.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *Throttled) chunkSize() int {
+	size := int(t.bytesPerSecond / 10)
+	if size < 1024 {
+		return 1024
+	}
+	if size > 256<<10 {
+		return 256 << 10
+	}
+	return size
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	t   *Throttled
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if chunk := r.t.chunkSize(); len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.t.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type throttledReadSeeker struct {
+	*throttledReader
+	s io.Seeker
+}
+
+func (r *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.s.Seek(offset, whence)
+}
@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/ffmpeg"
+)
+
+const (
+	refreshInterval = 250 * time.Millisecond
+	logLines        = 10
+	barWidth        = 30
+	defaultWidth    = 100
+)
+
+type output struct {
+	state   string
+	percent float64
+	speed   string
+	fps     float64
+}
+
+type Dashboard struct {
+	out   io.Writer
+	width int
+
+	mu          sync.Mutex
+	job         string
+	order       []string
+	outputs     map[string]*output
+	uploadFiles int
+	uploadBytes int64
+	uploadStart time.Time
+	uploadPct   float64
+	logs        []string
+	partial     []byte
+	dirty       bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func New(out io.Writer) *Dashboard {
+	width := defaultWidth
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 40 {
+		width = columns
+	}
+	return &Dashboard{
+		out:     out,
+		width:   width,
+		job:     "starting",
+		outputs: map[string]*output{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (d *Dashboard) Start() {
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.redraw(false)
+			case <-d.stop:
+				d.redraw(true)
+				return
+			}
+		}
+	}()
+}
+
+func (d *Dashboard) Stop() {
+	close(d.stop)
+	<-d.done
+}
+
+func (d *Dashboard) Handle(event ffmpeg.ProgressEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dirty = true
+	switch event.Type {
+	case "job":
+		d.job = event.State
+	case "state":
+		if event.Output == "" {
+			d.job = event.State
+			return
+		}
+		o := d.output(event.Output)
+		o.state = event.State
+		if event.State == ffmpeg.StateDone {
+			o.percent = 100
+		}
+	case "progress":
+		o := d.output(event.Output)
+		o.percent = event.Percent
+		o.speed = event.Speed
+		o.fps = event.FPS
+	case "upload":
+		if d.uploadStart.IsZero() {
+			d.uploadStart = time.Now()
+		}
+		d.uploadFiles++
+		d.uploadBytes += event.Bytes
+		d.uploadPct = event.Percent
+	}
+}
+
+func (d *Dashboard) output(name string) *output {
+	o, ok := d.outputs[name]
+	if !ok {
+		o = &output{state: ffmpeg.StateRunning}
+		d.outputs[name] = o
+		d.order = append(d.order, name)
+	}
+	return o
+}
+
+func (d *Dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		d.logs = append(d.logs, string(d.partial[:i]))
+		d.partial = d.partial[i+1:]
+	}
+	if len(d.logs) > logLines {
+		d.logs = d.logs[len(d.logs)-logLines:]
+	}
+	d.dirty = true
+	return len(p), nil
+}
+
+func (d *Dashboard) redraw(force bool) {
+	d.mu.Lock()
+	if !d.dirty && !force {
+		d.mu.Unlock()
+		return
+	}
+	d.dirty = false
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "video-processor  job: %s\n\n", d.job)
+	for _, name := range d.order {
+		o := d.outputs[name]
+		filled := int(o.percent / 100 * barWidth)
+		fmt.Fprintf(&b, "%-12s [%s%s] %5.1f%%  %-9s", d.truncate(name, 12),
+			strings.Repeat("#", filled), strings.Repeat(".", barWidth-filled), o.percent, o.state)
+		if o.state == ffmpeg.StateRunning {
+			if o.fps > 0 {
+				fmt.Fprintf(&b, "  %5.1f fps", o.fps)
+			}
+			if o.speed != "" {
+				fmt.Fprintf(&b, "  %s", o.speed)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if d.uploadFiles > 0 {
+		rate := float64(d.uploadBytes) / max(time.Since(d.uploadStart).Seconds(), 0.001)
+		fmt.Fprintf(&b, "\nupload       %5.1f%%  %d files  %.1f MB  %.2f MB/s\n",
+			d.uploadPct, d.uploadFiles, float64(d.uploadBytes)/1e6, rate/1e6)
+	}
+	b.WriteString("\n" + strings.Repeat("-", d.width) + "\n")
+	for _, line := range d.logs {
+		b.WriteString(d.truncate(line, d.width) + "\n")
+	}
+	d.mu.Unlock()
+	io.WriteString(d.out, b.String())
+}
+
+func (d *Dashboard) truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,67 @@
+package types
+
+import "errors"
+
+const (
+	ExitFailure      = 1
+	ExitMissingTools = 2
+	ExitProbe        = 3
+	ExitEncode       = 4
+	ExitUpload       = 5
+	ExitValidation   = 6
+	ExitVerify       = 7
+	ExitCancelled    = 8
+	ExitTimeout      = 9
+)
+
+var exitClasses = map[int]string{
+	ExitFailure:      "failure",
+	ExitMissingTools: "missing_tools",
+	ExitProbe:        "probe",
+	ExitEncode:       "encode",
+	ExitUpload:       "upload",
+	ExitValidation:   "validation",
+	ExitVerify:       "verify",
+	ExitCancelled:    "cancelled",
+	ExitTimeout:      "timeout",
+}
+
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func NewExitError(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExitError) Class() string {
+	return exitClasses[e.Code]
+}
+
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFailure
+}
+
+func ExitClass(err error) string {
+	return exitClasses[ExitCode(err)]
+}
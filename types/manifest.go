@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+type UploadedFile struct {
+	Path   string `json:"path"`
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	ETag   string `json:"etag,omitempty"`
+}
+
+type UploadManifest struct {
+	Storage        string         `json:"storage"`
+	UploadedAt     time.Time      `json:"uploadedAt"`
+	IdempotencyKey string         `json:"idempotencyKey,omitempty"`
+	Files          []UploadedFile `json:"files"`
+}
@@ -0,0 +1,44 @@
+package types
+
+type ProbeStream struct {
+	Index             int               `json:"index"`
+	CodecName         string            `json:"codec_name"`
+	CodecType         string            `json:"codec_type"`
+	CodecTagString    string            `json:"codec_tag_string"`
+	Profile           string            `json:"profile"`
+	Level             int               `json:"level"`
+	PixFmt            string            `json:"pix_fmt"`
+	Width             int               `json:"width"`
+	Height            int               `json:"height"`
+	SampleAspectRatio string            `json:"sample_aspect_ratio"`
+	AvgFrameRate      string            `json:"avg_frame_rate"`
+	RFrameRate        string            `json:"r_frame_rate"`
+	BitRate           string            `json:"bit_rate"`
+	SampleRate        string            `json:"sample_rate"`
+	Channels          int               `json:"channels"`
+	ChannelLayout     string            `json:"channel_layout"`
+	Duration          string            `json:"duration"`
+	Tags              map[string]string `json:"tags"`
+	Disposition       map[string]int    `json:"disposition"`
+	SideDataList      []ProbeSideData   `json:"side_data_list"`
+}
+
+type ProbeSideData struct {
+	SideDataType              string `json:"side_data_type"`
+	Rotation                  int    `json:"rotation"`
+	DVProfile                 int    `json:"dv_profile"`
+	DVBLSignalCompatibilityID int    `json:"dv_bl_signal_compatibility_id"`
+}
+
+type ProbeFormat struct {
+	FormatName string            `json:"format_name"`
+	Duration   string            `json:"duration"`
+	Size       string            `json:"size"`
+	BitRate    string            `json:"bit_rate"`
+	Tags       map[string]string `json:"tags"`
+}
+
+type ProbeInfo struct {
+	Streams []ProbeStream `json:"streams"`
+	Format  ProbeFormat   `json:"format"`
+}
@@ -0,0 +1,262 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+const (
+	LevelAuto = "auto"
+
+	CodecH264 = "h264"
+	CodecVP9  = "vp9"
+)
+
+var renditionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type Rendition struct {
+	Name         string
+	Width        int
+	Height       int
+	VideoBitrate string
+	MaxRate      string
+	BufSize      string
+	AudioBitrate string
+	AudioCodec   string
+	Profile      string
+	Level        string
+	CRF          int
+	Codec        string
+	PreFilters   []string
+	PostFilters  []string
+}
+
+func NewRendition(name, resolution, videoBitrate, audioBitrate, level string) (Rendition, error) {
+	width, height, err := ParseResolution(resolution)
+	if err != nil {
+		return Rendition{}, err
+	}
+	r := Rendition{
+		Name:         name,
+		Width:        width,
+		Height:       height,
+		VideoBitrate: videoBitrate,
+		AudioBitrate: audioBitrate,
+		Level:        level,
+	}
+	return r, r.Validate()
+}
+
+func RenditionsFromSlices(outputs, resolutions, bitrates, audioRates, levels []string) ([]Rendition, error) {
+	n := len(outputs)
+	if len(resolutions) != n || len(bitrates) != n || len(audioRates) != n || len(levels) != n {
+		return nil, fmt.Errorf("rendition lists differ in length: %d outputs, %d resolutions, %d bitrates, %d audio rates, %d levels",
+			n, len(resolutions), len(bitrates), len(audioRates), len(levels))
+	}
+	renditions := make([]Rendition, 0, n)
+	for i := range outputs {
+		r, err := NewRendition(outputs[i], resolutions[i], bitrates[i], audioRates[i], levels[i])
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, r)
+	}
+	return renditions, nil
+}
+
+func RenditionsFromSizes(resolutions, bitrates []string) ([]Rendition, error) {
+	if len(bitrates) != len(resolutions) {
+		return nil, fmt.Errorf("got %d bitrates for %d resolutions", len(bitrates), len(resolutions))
+	}
+	names := make([]string, len(resolutions))
+	audioRates := make([]string, len(resolutions))
+	levels := make([]string, len(resolutions))
+	for i, resolution := range resolutions {
+		_, height, err := ParseResolution(resolution)
+		if err != nil {
+			return nil, err
+		}
+		names[i], audioRates[i] = strconv.Itoa(height), "128k"
+	}
+	return RenditionsFromSlices(names, resolutions, bitrates, audioRates, levels)
+}
+
+func ParseRenditionSpec(spec string) (Rendition, error) {
+	r := Rendition{AudioBitrate: "128k"}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || value == "" {
+			return Rendition{}, fmt.Errorf("invalid rendition %q: field %q is not key=value", spec, field)
+		}
+		var err error
+		switch strings.ToLower(key) {
+		case "name":
+			r.Name = value
+		case "size":
+			r.Width, r.Height, err = ParseResolution(value)
+		case "vb":
+			r.VideoBitrate = value
+		case "ab":
+			r.AudioBitrate = value
+		case "maxrate":
+			r.MaxRate = value
+		case "bufsize":
+			r.BufSize = value
+		case "ac":
+			r.AudioCodec = value
+		case "profile":
+			r.Profile = value
+		case "level":
+			r.Level = value
+		case "crf":
+			r.CRF, err = strconv.Atoi(value)
+		case "codec":
+			r.Codec = strings.ToLower(value)
+		default:
+			err = fmt.Errorf("unknown key %q (expected name, size, vb, ab, maxrate, bufsize, ac, profile, level, crf or codec)", key)
+		}
+		if err != nil {
+			return Rendition{}, fmt.Errorf("invalid rendition %q: %w", spec, err)
+		}
+	}
+	return r, r.Validate()
+}
+
+func ParseResolution(resolution string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(resolution), "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution %q (expected WIDTHxHEIGHT)", resolution)
+	}
+	return width, height, nil
+}
+
+func (r Rendition) Spec() string {
+	fields := []string{"name=" + r.Name, "size=" + r.Resolution(), "vb=" + r.VideoBitrate, "ab=" + r.AudioBitrate}
+	for _, field := range []struct{ key, value string }{
+		{"maxrate", r.MaxRate},
+		{"bufsize", r.BufSize},
+		{"ac", r.AudioCodec},
+		{"profile", r.Profile},
+		{"level", r.Level},
+		{"codec", r.Codec},
+	} {
+		if field.value != "" {
+			fields = append(fields, field.key+"="+field.value)
+		}
+	}
+	if r.CRF != 0 {
+		fields = append(fields, "crf="+strconv.Itoa(r.CRF))
+	}
+	return strings.Join(fields, ",")
+}
+
+func (r Rendition) Resolution() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+func (r Rendition) EffectiveMaxRate() string {
+	if r.MaxRate != "" {
+		return r.MaxRate
+	}
+	return fmt.Sprintf("%dk", int(float64(r.VideoKbps())*1.2))
+}
+
+func (r Rendition) EffectiveBufSize() string {
+	if r.BufSize != "" {
+		return r.BufSize
+	}
+	return fmt.Sprintf("%dk", r.VideoKbps()*2)
+}
+
+func (r Rendition) WebM() bool {
+	return r.Codec == CodecVP9
+}
+
+func (r Rendition) VideoKbps() int {
+	return kbps(r.VideoBitrate)
+}
+
+func (r Rendition) AudioKbps() int {
+	return kbps(r.AudioBitrate)
+}
+
+func (r Rendition) MaxRateKbps() int {
+	return kbps(r.EffectiveMaxRate())
+}
+
+func (r Rendition) BufSizeKbps() int {
+	return kbps(r.EffectiveBufSize())
+}
+
+func kbps(bitrate string) int {
+	value, err := utils.ParseBitrate(bitrate)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func (r Rendition) Validate() error {
+	var errs []error
+	if !renditionNamePattern.MatchString(r.Name) {
+		errs = append(errs, fmt.Errorf("name %q must be non-empty and contain only letters, digits, '-' and '_'", r.Name))
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		errs = append(errs, fmt.Errorf("invalid size %dx%d", r.Width, r.Height))
+	}
+	for _, field := range []struct {
+		name, value string
+		required    bool
+	}{
+		{"video bitrate", r.VideoBitrate, true},
+		{"audio bitrate", r.AudioBitrate, true},
+		{"max rate", r.MaxRate, false},
+		{"buffer size", r.BufSize, false},
+	} {
+		if !field.required && field.value == "" {
+			continue
+		}
+		if _, err := utils.ParseBitrate(field.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.name, err))
+		}
+	}
+	if r.Level != "" && r.Level != LevelAuto {
+		if _, err := strconv.ParseFloat(r.Level, 64); err != nil {
+			errs = append(errs, fmt.Errorf("invalid level %q", r.Level))
+		}
+	}
+	if r.Codec != "" && r.Codec != CodecH264 && r.Codec != CodecVP9 {
+		errs = append(errs, fmt.Errorf("unknown codec %q (expected %s or %s)", r.Codec, CodecH264, CodecVP9))
+	}
+	if r.CRF < 0 || r.CRF > 51 {
+		errs = append(errs, fmt.Errorf("CRF %d out of range 0-51", r.CRF))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("rendition %q: %w", r.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+func ValidateRenditions(renditions []Rendition) error {
+	if len(renditions) == 0 {
+		return errors.New("no renditions configured")
+	}
+	seen := map[string]bool{}
+	for _, r := range renditions {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate rendition name %q", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package types
+
+import "time"
+
+type JobReport struct {
+	InputFile          string                   `json:"inputFile"`
+	OutputDir          string                   `json:"outputDir"`
+	StartedAt          time.Time                `json:"startedAt"`
+	FinishedAt         time.Time                `json:"finishedAt,omitempty"`
+	Error              string                   `json:"error,omitempty"`
+	Sample             string                   `json:"sample,omitempty"`
+	VideoStream        *int                     `json:"videoStream,omitempty"`
+	TrimmedStart       float64                  `json:"trimmedStart,omitempty"`
+	TrimmedEnd         float64                  `json:"trimmedEnd,omitempty"`
+	IdempotencyKey     string                   `json:"idempotencyKey,omitempty"`
+	Duplicate          bool                     `json:"duplicate,omitempty"`
+	Cost               *CostReport              `json:"cost,omitempty"`
+	EncodingStats      map[string][]StatsSample `json:"encodingStats,omitempty"`
+	Stages             []StageTiming            `json:"stages,omitempty"`
+	StageSeconds       map[string]float64       `json:"stageSeconds,omitempty"`
+	Degraded           bool                     `json:"degraded,omitempty"`
+	FailedRenditions   map[string]string        `json:"failedRenditions,omitempty"`
+	DegradedRenditions map[string][]string      `json:"degradedRenditions,omitempty"`
+	FFmpegPath         string                   `json:"ffmpegPath,omitempty"`
+	FFmpegVersion      string                   `json:"ffmpegVersion,omitempty"`
+	FFmpegConfig       string                   `json:"ffmpegConfiguration,omitempty"`
+	FFprobePath        string                   `json:"ffprobePath,omitempty"`
+	FFprobeVer         string                   `json:"ffprobeVersion,omitempty"`
+	OutputChecksum     string                   `json:"outputChecksum,omitempty"`
+}
+
+type CostReport struct {
+	ComputePerHour    float64 `json:"computePerHour"`
+	StoragePerGBMonth float64 `json:"storagePerGBMonth"`
+	DeliveryPerGB     float64 `json:"deliveryPerGB"`
+
+	EstimatedBytes           int64   `json:"estimatedBytes,omitempty"`
+	EstimatedStoragePerMonth float64 `json:"estimatedStoragePerMonth,omitempty"`
+
+	EncodeSeconds   float64         `json:"encodeSeconds,omitempty"`
+	ComputeCost     float64         `json:"computeCost,omitempty"`
+	OutputBytes     int64           `json:"outputBytes,omitempty"`
+	StoragePerMonth float64         `json:"storagePerMonth,omitempty"`
+	Renditions      []RenditionCost `json:"renditions,omitempty"`
+}
+
+type RenditionCost struct {
+	Name                  string  `json:"name"`
+	Bytes                 int64   `json:"bytes"`
+	Kbps                  int     `json:"kbps,omitempty"`
+	DeliveryPerHourViewed float64 `json:"deliveryPerHourViewed,omitempty"`
+}
+
+type StatsSample struct {
+	Elapsed     float64 `json:"elapsed"`
+	OutTime     float64 `json:"outTime"`
+	FPS         float64 `json:"fps"`
+	BitrateKbps float64 `json:"bitrateKbps"`
+	Q           float64 `json:"q"`
+	Speed       float64 `json:"speed"`
+}
+
+type StageTiming struct {
+	Stage      string    `json:"stage"`
+	Output     string    `json:"output,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Seconds    float64   `json:"seconds"`
+	Failed     bool      `json:"failed,omitempty"`
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sessionDataIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type SessionData struct {
+	ID    string
+	Value string
+	JSON  json.RawMessage
+}
+
+func ParseSessionData(spec string, isJSON bool) (SessionData, error) {
+	id, value, ok := strings.Cut(spec, "=")
+	if !ok || !sessionDataIDPattern.MatchString(id) {
+		return SessionData{}, fmt.Errorf("invalid session data %q (expected DATA-ID=value with a reverse-DNS DATA-ID)", spec)
+	}
+	data := SessionData{ID: id}
+	if isJSON {
+		if !json.Valid([]byte(value)) {
+			return SessionData{}, fmt.Errorf("session data %s is not valid JSON", id)
+		}
+		data.JSON = json.RawMessage(value)
+		return data, nil
+	}
+	if strings.ContainsAny(value, "\"\r\n") {
+		return SessionData{}, fmt.Errorf("session data %s value can't contain quotes or line breaks", id)
+	}
+	data.Value = value
+	return data, nil
+}
+
+func ValidateStartOffset(offset string) error {
+	if offset == "" {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(offset, 64); err != nil {
+		return fmt.Errorf("invalid start offset %q (expected seconds, negative to count from the end)", offset)
+	}
+	return nil
+}
@@ -1,12 +1,205 @@
 package types
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gastrader/go_ffmpeg/utils"
+)
+
+type ExtraArgs struct {
+	ExtraInputArgs  []string
+	ExtraOutputArgs []string
+	ExtraFilterArgs []string
+}
+
+func (e ExtraArgs) clone() ExtraArgs {
+	return ExtraArgs{
+		ExtraInputArgs:  append([]string(nil), e.ExtraInputArgs...),
+		ExtraOutputArgs: append([]string(nil), e.ExtraOutputArgs...),
+		ExtraFilterArgs: append([]string(nil), e.ExtraFilterArgs...),
+	}
+}
+
 type VideoProcessingConfig struct {
-	Outputs     []string
-	Resolutions []string
-	Bitrates    []string
-	AudioRates  []string
-	Levels      []string
+	Renditions  []Rendition
+	VideoCodec  string
 	Preset      string
 	CRF         int
 	SegmentTime int
+	ScalePolicy string
+	DASH        bool
+	SingleFile  bool
+	HLSFlags    []string
+
+	SegmentFormat   string
+	InitSegmentName string
+	SegmentDigits   int
+
+	Reproducible bool
+
+	ProgramDateTime string
+	StrictLevels    bool
+	HDRPolicy       string
+	VFRPolicy       string
+	VideoStream     string
+
+	StillDuration  time.Duration
+	ImageFrameRate string
+	KenBurns       bool
+
+	AudioLadder []string
+	AudioPoster string
+
+	Burnin     []string
+	BurninFont string
+
+	Trim             bool
+	BlackThreshold   float64
+	SilenceThreshold string
+	TrimMinDuration  time.Duration
+
+	ComposeWith   string
+	ComposeLayout string
+	PIPPosition   string
+	PIPScale      float64
+
+	Encrypt        bool
+	KeyURI         string
+	KeyFile        string
+	SegmentBaseURL string
+
+	VariantOrder  string
+	MinResolution string
+	MaxResolution string
+
+	SessionData  []SessionData
+	StartOffset  string
+	StartPrecise bool
+
+	SmartPassthrough     bool
+	PassthroughTolerance float64
+	AudioPassthrough     bool
+	NoAudioPolicy        string
+	Downmix              string
+	DownmixFilter        string
+	LoudnessCompensation bool
+	SurroundAudio        bool
+	SurroundBitrate      string
+	DolbyAudio           string
+	DolbyBitrate         string
+	AudioLanguage        string
+	AudioName            string
+	AudioDescription     string
+	PostFilters          []string
+	ExtraArgs
+	RenditionExtraArgs map[string]ExtraArgs
+}
+
+func (c VideoProcessingConfig) Clone() VideoProcessingConfig {
+	clone := c
+	clone.Renditions = append([]Rendition(nil), c.Renditions...)
+	for i := range clone.Renditions {
+		clone.Renditions[i].PreFilters = append([]string(nil), c.Renditions[i].PreFilters...)
+		clone.Renditions[i].PostFilters = append([]string(nil), c.Renditions[i].PostFilters...)
+	}
+	clone.SessionData = append([]SessionData(nil), c.SessionData...)
+	clone.AudioLadder = append([]string(nil), c.AudioLadder...)
+	clone.Burnin = append([]string(nil), c.Burnin...)
+	clone.HLSFlags = append([]string(nil), c.HLSFlags...)
+	clone.ExtraArgs = c.ExtraArgs.clone()
+	clone.PostFilters = append([]string(nil), c.PostFilters...)
+	if c.RenditionExtraArgs != nil {
+		clone.RenditionExtraArgs = make(map[string]ExtraArgs, len(c.RenditionExtraArgs))
+		for name, extra := range c.RenditionExtraArgs {
+			clone.RenditionExtraArgs[name] = extra.clone()
+		}
+	}
+	return clone
+}
+
+func (c *VideoProcessingConfig) SetRenditionFilters(specs []string, post bool) error {
+	for _, spec := range specs {
+		name, chain, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || chain == "" {
+			return fmt.Errorf("invalid rendition filter %q (expected NAME=FILTERS)", spec)
+		}
+		i := slices.IndexFunc(c.Renditions, func(r Rendition) bool { return r.Name == name })
+		if i < 0 {
+			return fmt.Errorf("rendition filter for unknown rendition %q", name)
+		}
+		if post {
+			c.Renditions[i].PostFilters = append(c.Renditions[i].PostFilters, chain)
+		} else {
+			c.Renditions[i].PreFilters = append(c.Renditions[i].PreFilters, chain)
+		}
+	}
+	return nil
+}
+
+// SetRenditionExtraArgs adds extra ffmpeg input or output arguments for one
+// rendition from NAME=ARGS specs, with ARGS split like a shell command line.
+func (c *VideoProcessingConfig) SetRenditionExtraArgs(specs []string, output bool) error {
+	for _, spec := range specs {
+		name, line, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || line == "" {
+			return fmt.Errorf("invalid rendition extra args %q (expected NAME=ARGS)", spec)
+		}
+		if !slices.ContainsFunc(c.Renditions, func(r Rendition) bool { return r.Name == name }) {
+			return fmt.Errorf("extra args for unknown rendition %q", name)
+		}
+		args, err := utils.SplitArgs(line)
+		if err != nil {
+			return err
+		}
+		if c.RenditionExtraArgs == nil {
+			c.RenditionExtraArgs = map[string]ExtraArgs{}
+		}
+		extra := c.RenditionExtraArgs[name]
+		if output {
+			extra.ExtraOutputArgs = append(extra.ExtraOutputArgs, args...)
+		} else {
+			extra.ExtraInputArgs = append(extra.ExtraInputArgs, args...)
+		}
+		c.RenditionExtraArgs[name] = extra
+	}
+	return nil
+}
+
+func (c *VideoProcessingConfig) SetAudioBitrates(bitrates []string) error {
+	switch len(bitrates) {
+	case 0:
+		return nil
+	case 1:
+		for i := range c.Renditions {
+			c.Renditions[i].AudioBitrate = bitrates[0]
+		}
+		return nil
+	case len(c.Renditions):
+		for i := range c.Renditions {
+			c.Renditions[i].AudioBitrate = bitrates[i]
+		}
+		return nil
+	}
+	return fmt.Errorf("got %d audio bitrates for %d renditions", len(bitrates), len(c.Renditions))
+}
+
+func (c *VideoProcessingConfig) SetAudioCodecs(codecs []string) error {
+	switch len(codecs) {
+	case 0:
+		return nil
+	case 1:
+		for i := range c.Renditions {
+			c.Renditions[i].AudioCodec = codecs[0]
+		}
+		return nil
+	case len(c.Renditions):
+		for i := range c.Renditions {
+			c.Renditions[i].AudioCodec = codecs[i]
+		}
+		return nil
+	}
+	return fmt.Errorf("got %d audio codecs for %d renditions", len(codecs), len(c.Renditions))
 }
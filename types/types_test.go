@@ -0,0 +1,59 @@
+package types
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCloneCopiesRenditionFilters(t *testing.T) {
+	// Spare capacity lets an append on a shared backing array write into the
+	// original's elements.
+	pre := make([]string, 1, 4)
+	pre[0] = "hqdn3d"
+	post := make([]string, 1, 4)
+	post[0] = "unsharp"
+	config := VideoProcessingConfig{
+		Renditions: []Rendition{{Name: "720p", PreFilters: pre, PostFilters: post}},
+	}
+
+	clone := config.Clone()
+	if err := clone.SetRenditionFilters([]string{"720p=eq=contrast=1.1"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := clone.SetRenditionFilters([]string{"720p=drawtext=text=clone"}, true); err != nil {
+		t.Fatal(err)
+	}
+	other := config.Clone()
+	if err := other.SetRenditionFilters([]string{"720p=yadif"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	original := config.Renditions[0]
+	if !slices.Equal(original.PreFilters, []string{"hqdn3d"}) || !slices.Equal(original.PostFilters, []string{"unsharp"}) {
+		t.Errorf("original filters changed: pre %v, post %v", original.PreFilters, original.PostFilters)
+	}
+	if got := clone.Renditions[0].PreFilters; !slices.Equal(got, []string{"hqdn3d", "eq=contrast=1.1"}) {
+		t.Errorf("clone pre filters = %v", got)
+	}
+	if got := other.Renditions[0].PreFilters; !slices.Equal(got, []string{"hqdn3d", "yadif"}) {
+		t.Errorf("second clone pre filters = %v", got)
+	}
+}
+
+func TestSetRenditionExtraArgs(t *testing.T) {
+	config := VideoProcessingConfig{Renditions: []Rendition{{Name: "720"}}}
+	specs := []string{`720=-metadata title='My Movie'`, "720=-tune fastdecode"}
+	if err := config.SetRenditionExtraArgs(specs, true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-metadata", "title=My Movie", "-tune", "fastdecode"}
+	if got := config.RenditionExtraArgs["720"].ExtraOutputArgs; !slices.Equal(got, want) {
+		t.Errorf("output args = %q, want %q", got, want)
+	}
+
+	for _, spec := range []string{"1080=-tune film", "720", "720=", "720='unterminated"} {
+		if err := config.SetRenditionExtraArgs([]string{spec}, false); err == nil {
+			t.Errorf("SetRenditionExtraArgs(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const EnvPrefix = "VIDPROC_"
+
+func EnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+func ApplyConfig(flags, known *pflag.FlagSet, configFile string) error {
+	var file map[string]any
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %v", configFile, err)
+		}
+		for name := range file {
+			if known.Lookup(name) == nil {
+				return fmt.Errorf("unknown setting %q in config file %s", name, configFile)
+			}
+		}
+	}
+
+	var errs []string
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed {
+			return
+		}
+		if value, ok := os.LookupEnv(EnvName(flag.Name)); ok {
+			if err := flags.Set(flag.Name, value); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", EnvName(flag.Name), err))
+			}
+			return
+		}
+		value, ok := file[flag.Name]
+		if !ok {
+			return
+		}
+		if err := setConfigValue(flags, flag, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s in %s: %v", flag.Name, configFile, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func setConfigValue(flags *pflag.FlagSet, flag *pflag.Flag, value any) error {
+	if object, isObject := value.(map[string]any); isObject {
+		pairs := make([]string, 0, len(object))
+		for k, v := range object {
+			pairs = append(pairs, k+"="+fmt.Sprint(v))
+		}
+		sort.Strings(pairs)
+		return flags.Set(flag.Name, strings.Join(pairs, ","))
+	}
+	list, isList := value.([]any)
+	if !isList {
+		return flags.Set(flag.Name, fmt.Sprint(value))
+	}
+	values := make([]string, len(list))
+	for i, v := range list {
+		values[i] = fmt.Sprint(v)
+	}
+	if flag.Value.Type() == "stringArray" {
+		for _, v := range values {
+			if err := flags.Set(flag.Name, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return flags.Set(flag.Name, strings.Join(values, ","))
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func FreeDiskSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("free disk space check is not supported on %s", runtime.GOOS)
+}
@@ -1,54 +1,359 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
-func ParseFrameRate(frameRate string) int {
-	parts := strings.Split(strings.TrimSpace(frameRate), "/")
-	if len(parts) == 2 {
-		numerator, _ := strconv.Atoi(parts[0])
-		denominator, _ := strconv.Atoi(parts[1])
-		if denominator == 0 {
-			denominator = 1
+func ParseFrameRate(frameRate string) (float64, error) {
+	value := strings.TrimSpace(frameRate)
+	numerator, denominator, isRational := strings.Cut(value, "/")
+	fps, err := strconv.ParseFloat(numerator, 64)
+	if err == nil && isRational {
+		var d float64
+		if d, err = strconv.ParseFloat(denominator, 64); err == nil && d != 0 {
+			fps /= d
+		} else {
+			fps = 0
 		}
-		return numerator / denominator
 	}
-	return 30
+	if err != nil || fps <= 0 || math.IsInf(fps, 0) || math.IsNaN(fps) {
+		return 0, fmt.Errorf("invalid frame rate %q (expected e.g. 30, 29.97 or 30000/1001)", value)
+	}
+	return fps, nil
+}
+
+var bitrateUnits = map[string]float64{"": 0.001, "k": 1, "K": 1, "m": 1000, "M": 1000, "g": 1e6, "G": 1e6}
+
+func ParseBitrate(bitrate string) (int, error) {
+	value := strings.TrimSpace(bitrate)
+	number := strings.TrimRight(value, "kKmMgG")
+	scale, ok := bitrateUnits[value[len(number):]]
+	n, err := strconv.ParseFloat(number, 64)
+	kbps := math.Round(n * scale)
+	if !ok || err != nil || kbps < 1 || math.IsInf(kbps, 0) || math.IsNaN(kbps) {
+		return 0, fmt.Errorf("invalid bitrate %q (expected e.g. 4500k, 4.5M or 4500000)", value)
+	}
+	return int(kbps), nil
+}
+
+var byteUnits = []struct {
+	suffix string
+	scale  float64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9},
+	{"B", 1},
+}
+
+func parseBytes(value string) (int64, error) {
+	scale := 1.0
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSuffix(value, unit.suffix), unit.scale
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid number of bytes")
+	}
+	return int64(number * scale), nil
+}
+
+// ParseByteRate parses a rate in bytes per second. A lowercase b, as in
+// 400Mbps or 400Mb/s, means bits, as it does in network speeds.
+func ParseByteRate(rate string) (int64, error) {
+	value, bits := strings.TrimSpace(rate), false
+	for _, suffix := range []string{"bps", "b/s"} {
+		if strings.HasSuffix(value, suffix) {
+			value, bits = strings.TrimSuffix(value, suffix), true
+			break
+		}
+	}
+	value = strings.ToUpper(value)
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "/S"), "PS")
+	bytes, err := parseBytes(value)
+	if bits && err == nil {
+		if bytes /= 8; bytes == 0 {
+			err = fmt.Errorf("rate below one byte per second")
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte rate %q (expected e.g. 50MB/s, 512KiB/s or 400Mbps)", rate)
+	}
+	return bytes, nil
+}
+
+func ParseByteSize(size string) (int64, error) {
+	bytes, err := parseBytes(strings.ToUpper(strings.TrimSpace(size)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 4GiB, 512MB)", size)
+	}
+	return bytes, nil
 }
 
-func ParseBitrate(bitrate string) int {
-	if strings.HasSuffix(bitrate, "k") {
-		value, _ := strconv.Atoi(strings.TrimSuffix(bitrate, "k"))
-		return value
+// SplitArgs splits a command line into arguments the way a POSIX shell
+// does, without expanding anything. Single quotes keep their contents as is,
+// and a backslash escapes the next character, or inside double quotes only
+// ", \, $ and `.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, escaped := false, false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", r) {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", line)
 	}
-	return 1000
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
 }
 
-func CheckRequiredTools(logger *slog.Logger) error {
-	for _, cmd := range []string{"ffmpeg", "ffprobe"} {
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %v", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+	}
+}
+
+func CheckRequiredTools(logger *slog.Logger, tools ...string) error {
+	for _, cmd := range tools {
 		if _, err := exec.LookPath(cmd); err != nil {
-			logger.Error("Required tool not found in PATH")
+			logger.Error("Required tool not found in PATH", "tool", cmd)
 			return fmt.Errorf("%s is not installed or in PATH", cmd)
 		}
 	}
 	return nil
 }
 
-func PrepareOutputDir(outputDir string, logger *slog.Logger) error {
-	if err := os.RemoveAll(outputDir); err != nil {
-		logger.Error("Failed to clear output directory", "outputDir", outputDir, "error", err)
-		return fmt.Errorf("failed to clear output directory: %v", err)
+const (
+	CleanNever  = "never"
+	CleanStale  = "stale"
+	CleanAlways = "always"
+
+	OutputManifest = ".go-ffmpeg-manifest.json"
+)
+
+func PrepareOutputDir(outputDir, clean string, force bool, logger *slog.Logger) error {
+	entries, err := os.ReadDir(outputDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return createOutputDir(outputDir, logger)
+	}
+	if err != nil {
+		logger.Error("Failed to read output directory", "outputDir", outputDir, "error", err)
+		return fmt.Errorf("failed to read output directory: %v", err)
+	}
+	if len(entries) == 0 || clean == CleanNever {
+		return nil
+	}
+
+	owned, err := ReadOutputManifest(outputDir)
+	if err != nil {
+		logger.Error("Failed to read output manifest", "outputDir", outputDir, "error", err)
+		return err
 	}
 
+	switch clean {
+	case CleanStale:
+		return removeOwnedFiles(outputDir, owned, logger)
+	case CleanAlways:
+		if force {
+			if err := checkRemovable(outputDir); err != nil {
+				logger.Error("Refusing to clear output directory", "outputDir", outputDir, "error", err)
+				return err
+			}
+			if err := os.RemoveAll(outputDir); err != nil {
+				logger.Error("Failed to clear output directory", "outputDir", outputDir, "error", err)
+				return fmt.Errorf("failed to clear output directory: %v", err)
+			}
+			return createOutputDir(outputDir, logger)
+		}
+
+		ownedSet := map[string]bool{OutputManifest: true}
+		for _, name := range owned {
+			ownedSet[name] = true
+		}
+		for _, entry := range entries {
+			if !ownedSet[entry.Name()] {
+				logger.Error("Refusing to clear output directory with foreign files", "outputDir", outputDir, "file", entry.Name())
+				return fmt.Errorf("output directory %s contains %s which was not created by this tool; use --force to delete it anyway or --clean=stale", outputDir, entry.Name())
+			}
+		}
+		return removeOwnedFiles(outputDir, owned, logger)
+	default:
+		return fmt.Errorf("unknown clean mode %q (expected %s, %s or %s)", clean, CleanNever, CleanStale, CleanAlways)
+	}
+}
+
+func createOutputDir(outputDir string, logger *slog.Logger) error {
 	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
 		logger.Error("Failed to create output directory", "outputDir", outputDir, "error", err)
 		return fmt.Errorf("failed to create output directory: %v", err)
 	}
 	return nil
 }
+
+func removeOwnedFiles(outputDir string, owned []string, logger *slog.Logger) error {
+	for _, name := range owned {
+		if name != filepath.Base(name) {
+			logger.Warn("Ignoring suspicious manifest entry", "outputDir", outputDir, "entry", name)
+			continue
+		}
+		path := filepath.Join(outputDir, name)
+		remove := os.Remove
+		if info, err := os.Lstat(path); err == nil && info.IsDir() {
+			remove = os.RemoveAll
+		}
+		if err := remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Error("Failed to remove stale output", "file", name, "error", err)
+			return fmt.Errorf("failed to remove %s: %v", name, err)
+		}
+	}
+	if err := os.Remove(filepath.Join(outputDir, OutputManifest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove output manifest: %v", err)
+	}
+	logger.Info("Removed previous outputs", "outputDir", outputDir, "files", len(owned))
+	return nil
+}
+
+func checkRemovable(outputDir string) error {
+	abs, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %v", err)
+	}
+	if abs == filepath.Dir(abs) {
+		return fmt.Errorf("refusing to delete filesystem root %s", abs)
+	}
+	if home, err := os.UserHomeDir(); err == nil && abs == filepath.Clean(home) {
+		return fmt.Errorf("refusing to delete home directory %s", abs)
+	}
+	if wd, err := os.Getwd(); err == nil && strings.HasPrefix(wd+string(filepath.Separator), abs+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to delete %s because it contains the working directory", abs)
+	}
+	return nil
+}
+
+func ReadOutputManifest(outputDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, OutputManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output manifest: %v", err)
+	}
+	var files []string
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse output manifest: %v", err)
+	}
+	return files, nil
+}
+
+func WriteOutputManifest(outputDir string, files []string) error {
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(outputDir, OutputManifest), data, 0644)
+}
+
+func MoveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s to %s: %v", src, dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", dst, err)
+	}
+	return os.Remove(src)
+}
+
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmp.Name(), err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %v", tmp.Name(), err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  -map_metadata   -1 ", []string{"-map_metadata", "-1"}},
+		{`-metadata title='My Movie'`, []string{"-metadata", "title=My Movie"}},
+		{`-metadata "comment=say \"hi\" \n"`, []string{"-metadata", `comment=say "hi" \n`}},
+		{`-vf drawtext=text=a\ b`, []string{"-vf", "drawtext=text=a b"}},
+		{`'' ""`, []string{"", ""}},
+		{`'$HOME' "a'b"`, []string{"$HOME", "a'b"}},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.line)
+		if err != nil {
+			t.Errorf("SplitArgs(%q): %v", tt.line, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{`-metadata 'title`, `"a`, `a\`} {
+		if _, err := SplitArgs(line); err == nil {
+			t.Errorf("SplitArgs(%q) succeeded, want an error", line)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package wizard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gastrader/go_ffmpeg/ffmpeg"
+)
+
+var contentBitrates = map[string]float64{
+	"film":      1,
+	"animation": 0.7,
+	"screen":    0.7,
+	"sports":    1.3,
+}
+
+type Answers struct {
+	Platforms    []string
+	SourceHeight int
+	Content      string
+	Surround     bool
+	Bucket       string
+	KeyPrefix    string
+}
+
+type prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+func (p *prompter) ask(question, def string, valid func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+		if !p.in.Scan() {
+			if err := p.in.Err(); err != nil {
+				return "", err
+			}
+			return "", errors.New("no answer: input closed")
+		}
+		answer := strings.TrimSpace(p.in.Text())
+		if answer == "" {
+			answer = def
+		}
+		if valid == nil {
+			return answer, nil
+		}
+		if err := valid(answer); err != nil {
+			fmt.Fprintf(p.out, "  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+func (p *prompter) confirm(question string) (bool, error) {
+	answer, err := p.ask(question+" (y/N)", "n", func(answer string) error {
+		switch strings.ToLower(answer) {
+		case "y", "yes", "n", "no":
+			return nil
+		}
+		return errors.New("answer y or n")
+	})
+	return strings.HasPrefix(strings.ToLower(answer), "y"), err
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func Ask(in io.Reader, out io.Writer) (*Answers, error) {
+	p := &prompter{in: bufio.NewScanner(in), out: out}
+	answers := &Answers{}
+
+	platforms, err := p.ask("Target platforms ("+strings.Join(ffmpeg.Platforms, ", ")+")", "web", func(answer string) error {
+		items := splitList(answer)
+		if len(items) == 0 {
+			return errors.New("name at least one platform")
+		}
+		for _, item := range items {
+			if !slices.Contains(ffmpeg.Platforms, item) {
+				return fmt.Errorf("unknown platform %q", item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	answers.Platforms = splitList(platforms)
+
+	height, err := p.ask("Typical source resolution (height in pixels, e.g. 2160, 1080, 720)", "1080", func(answer string) error {
+		if n, err := strconv.Atoi(strings.TrimSuffix(answer, "p")); err != nil || n <= 0 {
+			return errors.New("enter a height such as 1080")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	answers.SourceHeight, _ = strconv.Atoi(strings.TrimSuffix(height, "p"))
+
+	answers.Content, err = p.ask("Content type (film, animation, screen, sports)", "film", func(answer string) error {
+		if _, ok := contentBitrates[strings.ToLower(answer)]; !ok {
+			return fmt.Errorf("unknown content type %q", answer)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	answers.Content = strings.ToLower(answers.Content)
+
+	if answers.Surround, err = p.confirm("Do sources have 5.1 surround audio?"); err != nil {
+		return nil, err
+	}
+	if answers.Bucket, err = p.ask("S3 bucket to upload to (empty to keep outputs local)", "", nil); err != nil {
+		return nil, err
+	}
+	if answers.Bucket != "" {
+		if answers.KeyPrefix, err = p.ask("Key prefix in the bucket (empty for the output directory path)", "", nil); err != nil {
+			return nil, err
+		}
+	}
+	return answers, nil
+}
+
+func Config(answers *Answers) (map[string]any, error) {
+	ladder, err := ffmpeg.RecommendLadder(answers.Platforms, answers.SourceHeight, contentBitrates[answers.Content])
+	if err != nil {
+		return nil, err
+	}
+	renditions := make([]string, len(ladder))
+	for i, rendition := range ladder {
+		renditions[i] = rendition.Spec()
+	}
+
+	config := map[string]any{"rendition": renditions}
+	if answers.Surround {
+		config["surround"] = true
+	}
+	if answers.Bucket != "" {
+		config["bucket"] = answers.Bucket
+	}
+	if answers.KeyPrefix != "" {
+		config["key-prefix"] = answers.KeyPrefix
+	}
+	return config, nil
+}